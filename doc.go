@@ -16,7 +16,7 @@
 //          </buffer>
 //      </debug>
 //      <info>
-//          <console output="stderr" color="yellow" />
+//          <console output="stderr" foreground="yellow" />
 //      </info>
 //      <!-- 除了debug和info，其它4个依然输出到ioutil.Discard -->
 //  </logs>
@@ -32,6 +32,10 @@
 //  // 向所有级别的日志输出内容。
 //  logs.All(...)
 //
+//  // 在运行时向 debug 级别添加一个 io.Writer，不再需要时删除。
+//  logs.Attach("debug", "session", w)
+//  logs.Detach("session")
+//
 // 上面的配置文件表示 DEBUG 级别的内容输出前都进被缓存，当量达到 10 条时，
 // 一次性向 rotate 和 stmp 输出。
 // 其中 buffer、rotate、stmp、debug 和 info 都是实现了 io.Writer接口的结
@@ -50,6 +54,11 @@
 // - 二级元素只能为 info、deubg、trace、warn、error 和 critical。
 // 分别对应 INFO、DEBUG、TRACE、WARN、ERROR 和 CRITICAL等日志实例。
 // 可以带上 prefix 和 flag 属性，分别对应 log.New() 中的相应参数。
+// 以及以下控制子元素输出方式的属性：
+//  policy:   某一子元素输出出错时的处理方式，stop 表示中断后续的输出，
+//            continue 表示继续输出后续的子元素，并返回所有的错误，默认为 stop；
+//  parallel: 是否并行向所有子元素输出，默认为 false；
+//  timeout:  并行输出时，等待每个子元素完成的最长时间，如 1s，默认一直等待。
 //
 // - 三级及以下元素可以自己根据需求组合，logs 自带以下 writer，
 // 用户也可以自己向 logs 注册自己的实现。
@@ -65,27 +74,312 @@
 // 2. rotate:
 //
 // 这是一个按文件大写自动分割日志的实例，以第一条记录的产生时间作为文件名。
-// 拥有以下三个参数：
+// 拥有以下参数：
 //  prefix：表示日志文件的前缀，留空表示没有前缀；
 //  dir：	表示的是日志存放的目录；
 //  size：	表示的是每个日志的大概大小，默认单位为 byte，可以带字符单位，
-//          如 5M、10G 等(支持 k、m 和 g 三个后缀，不区分大小写)。
+//          如 5M、10G 等(支持 k、m 和 g 三个后缀，不区分大小写)；
+//  mode、dirMode、uid、gid、sync 和 noFollow 与 file 相同，
+//  但 mode 和 dirMode 默认为 0777 且受 umask 的影响，以兼容之前的版本。
+//
+// 指定了 s3Bucket 之后，分割出来的旧文件以及 logs.Close() 时正在写的文件
+// 会上传到 S3 兼容的对象存储，上传在后台进行，不会阻塞日志的写入，
+// logs.Flush() 和 logs.Close() 会等待上传完成：
+//  s3Endpoint：    对象存储的地址，比如 https://s3.amazonaws.com；
+//  s3Region：      区域，默认为 us-east-1；
+//  s3Bucket：      存储桶；
+//  s3AccessKey：   访问密钥 ID；
+//  s3SecretKey：   访问密钥；
+//  s3SessionToken：临时凭证的 token，可以为空；
+//  s3Key：         对象名称的模板，默认为 {prefix}{host}/{year}/{month}/{day}/{name}，
+//                  可用的变量还有 {date} 和 {hour}，{name} 为必须；
+//  s3Prefix：      对象名称的前缀；
+//  s3PartSize：    超过此大小的文件使用分块上传，默认为 16M，不能小于 5M；
+//  s3Delete：      上传并校验 ETag 成功之后是否删除本地文件，默认为 false；
+//  s3Timeout：     每个请求的超时时间，默认为 5m；
+//  caFile：        自定义的 CA 证书文件。
 //
 // 3. stmp:
 //
 // 将日志内容发送给指定邮件，可定义的属性为：
-//  username: 发送邮件的账号；
-//  password: 账号对应的密码；
+//  username: 发送邮件的账号，auth 为 none 时可以不指定；
+//  password: 账号对应的密码，auth 为 none 时可以不指定；
 //  host:	  stmp的主机；
 //  subject:  邮件的主题；
-//  sendTo:   接收人地址，多个收件地址使用分号分隔。
+//  sendTo:   接收人地址，多个收件地址使用分号分隔；
+//  from:     邮件头中的发送人地址，默认与 username 相同；
+//  sender:   信封发送者(MAIL FROM)的地址，默认与 from 相同；
+//  auth:     认证方式，可以是 plain、login、cram-md5 和 none，默认为 plain；
+//  tls:      TLS 模式，可以是以下值，默认为 auto：
+//            auto 服务器支持时使用 STARTTLS；
+//            starttls 必须使用 STARTTLS；
+//            tls 直接建立 TLS 连接，一般用于 465 端口；
+//            none 不使用 TLS；
+//  caFile:   PEM 格式的 CA 证书文件，用于验证服务器的证书，默认使用系统的证书；
+//  html:     是否同时发送 HTML 格式的正文，默认为 false；
+//  attachment: 附件的文件名，指定之后日志内容会同时以附件的形式发送；
+//  timeout:  连接和发送的超时时间，如 10s，默认为 30s；
+//  queue:    异步发送队列的大小，指定之后邮件由后台发送，不会阻塞日志的输出，
+//            队列已满时，新的邮件将被丢弃；
+//  retries:  异步发送失败之后的重试次数，默认为 3；
+//  backoff:  异步发送第一次重试之前的等待时间，之后每次翻倍，默认为 1s。
 //
 // 4. console:
 //
 // 向控制台输出内容。可定义的属性为：
 //  output：    只能为 "stderr", "stdout" 两个值，表示输出的具体方向，默认值为 "stderr"；
-//  foreground: 表示输出时的前景色，其值在 github.com/issue9/term/colors 中定义。
-//  background: 表示输出时的背景色，其值在 github.com/issue9/term/colors 中定义。
+//  foreground: 表示输出时的前景色，可以是 github.com/issue9/term/colors 中定义的颜色名称，
+//              0-255 的 256 色数值或是 #ff8800 格式的真彩色，默认为 red；
+//  background: 表示输出时的背景色，格式与 foreground 相同，默认为 default；
+//  levels:     为各个级别单独指定颜色，格式为 level:foreground[/background]，
+//              多个级别之间用分号分隔，如 info:green;error:white/red，
+//              可以让同一份配置在不同的级别中使用不同的颜色；
+//  highlight:  着色的范围，可以是 line(整行)、level(仅 prefix 部分)和 time(仅时间部分)，默认为 line；
+//  mode:       输出模式，默认为 raw，即原样输出；dev 表示开发模式，以对齐的格式输出
+//              级别标签、相对时间、缩短的调用位置和消息，消息末尾 key=value 形式的字段
+//              和多行内容会缩进显示在下方，此时仅对级别标签着色；
+//  color:      色彩模式，可以是以下值，默认为 auto：
+//              auto 仅在输出到终端时使用色彩，环境变量 NO_COLOR 不为空或是 TERM=dumb 时不使用色彩；
+//              always 始终使用色彩；
+//              never 从不使用色彩。
+//
+//
+// 5. syslog:
+//
+// 将日志发送到 syslog 服务，日志级别会被转换成对应的 severity。可定义的属性为：
+//  network:  可以是 unix、unixgram、udp 和 tcp，为空表示本地的 syslog 服务；
+//  addr:     syslog 服务的地址，network 为空时，默认会查找 /dev/log 等地址；
+//  format:   消息格式，可以是 rfc3164 和 rfc5424，默认为 rfc3164；
+//  facility: 可以是 kern、user、mail、daemon、auth、syslog、lpr、news、uucp、
+//            cron、authpriv、ftp 和 local0 至 local7，默认为 user；
+//  appName:  应用名称，默认为程序的文件名；
+//  procID:   进程 ID，默认为当前进程的 ID；
+//  timeout:  连接和发送的超时时间，默认为 30s；
+//  tls:      tcp 连接是否使用 TLS 加密，默认为 false；
+//  caFile:   PEM 格式的 CA 证书文件，用于验证服务器的证书，默认使用系统的证书。
+//
+//
+// 6. journald:
+//
+// 通过原生协议将日志发送到 systemd-journald，日志级别会被转换成 PRIORITY 字段，
+// 调用位置会被转换成 CODE_FILE 和 CODE_LINE 字段。内容过大时，会通过 memfd 传递。
+// 可定义的属性为：
+//  path:       journald 的 socket 地址，默认为 /run/systemd/journal/socket；
+//  identifier: SYSLOG_IDENTIFIER 字段的值，默认为程序的文件名；
+//  fields:     自定义字段，格式为 KEY1=value1;KEY2=value2，字段名只能包含大写字母、
+//              数字和下划线，且不能以下划线和数字开头。
+//
+//
+// 7. http:
+//
+// 将日志以 JSON 格式批量 POST 到指定的地址，服务端返回 5xx 和 429 时会进行重试，
+// 重试的等待时间优先使用 Retry-After 报头的值，但最长为 1 分钟。
+// 每条日志的格式为 {"time":"...","level":"...","caller":"...","message":"..."}。
+// 可定义的属性为：
+//  url:      接收日志的地址，必须指定；
+//  format:   请求内容的格式，可以是 json 和 ndjson，默认为 json；
+//  gzip:     是否使用 gzip 压缩请求内容，默认为 false；
+//  headers:  自定义报头，格式为 Name1: value1;Name2: value2；
+//  username: Basic 验证的用户名；
+//  password: Basic 验证的密码；
+//  token:    Bearer 验证的令牌，优先于 username；
+//  timeout:  每次请求的超时时间，默认为 30s；
+//  caFile:   PEM 格式的 CA 证书文件，用于验证服务器的证书，默认使用系统的证书；
+//  queue:    队列的大小，队列已满时新的日志将被丢弃，默认为 1000；
+//  retries:  发送失败之后的重试次数，默认为 3；
+//  backoff:  第一次重试之前的等待时间，之后每次翻倍，默认为 1s；
+//  batch:    每次请求最多包含的日志数量，默认为 100；
+//  interval: 每一批日志最长的等待时间，默认为 1s。
+//
+//
+// 8. robot:
+//
+// 将日志发送到聊天机器人，一般用于 error 和 critical 级别的告警。
+// 一段时间内的日志会被合并成一条消息，超过平台的频率限制时会继续合并，
+// 每条消息最多包含 100 行日志，且不能超过平台限制的字节数(企业微信为 2048，
+// 钉钉为 20000)，超出的部分将被丢弃。可定义的属性为：
+//  platform:  平台，可以是 dingtalk、wecom、feishu 和 slack，必须指定；
+//  webhook:   机器人的地址，必须指定；
+//  secret:    签名的密钥，仅对 dingtalk 和 feishu 有效；
+//  mentions:  需要 @ 的用户，以逗号分隔，all 表示所有人。dingtalk 和 wecom
+//             为手机号，feishu 为 open_id，slack 为用户 ID；
+//  window:    合并消息的时间窗口，默认为 0，即不合并；
+//  rateLimit: 频率限制，格式为 次数/时间，比如 20/1m，默认为各平台的限制；
+//  timeout:   每次请求的超时时间，默认为 10s。
+//
+//
+// 9. net:
+//
+// 将日志输出到网络连接，在第一次写入时才会建立连接，连接失败之后会按 backoff
+// 递增等待时间进行重连，等待期间的内容会被缓存。可定义的属性为：
+//  network:     可以是 tcp、tcp4、tcp6、udp、udp4、udp6、unix 和 unixgram，必须指定；
+//  addr:        连接的地址，必须指定；
+//  timeout:     连接和写入的超时时间，默认为 10s；
+//  backoff:     第一次重连之前的等待时间，之后每次翻倍，默认为 500ms；
+//  maxBackoff:  重连的最长等待时间，默认为 1m；
+//  buffer:      连接断开期间最多缓存的内容，可以带单位，默认为 1m，超出时丢弃最早的内容；
+//  maxDatagram: udp 和 unixgram 每个数据报的最大字节数，超出时会被拆分，默认为 1400；
+//  tls:         tcp 连接是否使用 TLS 加密，默认为 false；
+//  caFile:      PEM 格式的 CA 证书文件，用于验证服务器的证书，默认使用系统的证书。
+//
+//
+// 10. gelf:
+//
+// 以 GELF 1.1 格式将日志发送到 Graylog，日志级别会被转换成对应的 level，
+// 调用位置会被转换成 _file 和 _line 字段。可定义的属性为：
+//  network:   可以是 udp、udp4、udp6、tcp、tcp4 和 tcp6，默认为 udp；
+//  addr:      Graylog 的地址，必须指定；
+//  host:      host 字段的值，默认为当前主机名；
+//  fields:    自定义字段，格式为 name1=value1;name2=value2，字段名不需要带下划线前缀；
+//  compress:  udp 的压缩方式，可以是 none、gzip 和 zlib，默认为 none，tcp 不支持压缩；
+//  chunkSize: udp 每个分块的最大字节数，超出时会被分块发送，默认为 1420；
+//  timeout:   连接和写入的超时时间，默认为 10s；
+//  tls:       tcp 连接是否使用 TLS 加密，默认为 false；
+//  caFile:    PEM 格式的 CA 证书文件，用于验证服务器的证书，默认使用系统的证书。
+//
+//
+// 11. elasticsearch:
+//
+// 通过 _bulk 接口将日志写入 Elasticsearch 或 OpenSearch，文档的格式为
+// {"@timestamp":"...","level":"...","caller":"...","message":"..."}。
+// 部分日志返回 429 和 5xx 时，只对这些日志进行重试。可定义的属性为：
+//  url:      服务地址，比如 http://localhost:9200，必须指定；
+//  index:    索引名称，其中的 %{layout} 会按 UTC 时间以 Go 的时间格式进行格式化，
+//            其它部分保持不变，比如 app-%{2006.01.02}，必须指定；
+//  username: Basic 验证的用户名；
+//  password: Basic 验证的密码；
+//  apiKey:   API key 验证，为创建 API key 时返回的 encoded 值，优先于 username；
+//  timeout:  每次请求的超时时间，默认为 30s；
+//  caFile:   PEM 格式的 CA 证书文件，用于验证服务器的证书，默认使用系统的证书；
+//  queue、retries、backoff、batch 和 interval 与 http 相同。
+//
+//
+// 12. loki:
+//
+// 将日志推送到 Grafana Loki 的 /loki/api/v1/push 接口。日志按标签分成不同的 stream，
+// 默认的标签为 host 和 level，同一 stream 中的日志按时间顺序发送。可定义的属性为：
+//  url:      Loki 的服务地址，比如 http://localhost:3100，必须指定；
+//  format:   请求内容的格式，可以是 json 和 protobuf(snappy 压缩)，默认为 json；
+//  labels:   其它的静态标签，格式为 app=name;env=dev，值为空表示删除该标签；
+//  tenant:   多租户模式下的租户 ID；
+//  username: Basic 验证的用户名；
+//  password: Basic 验证的密码；
+//  timeout:  每次请求的超时时间，默认为 30s；
+//  caFile:   PEM 格式的 CA 证书文件，用于验证服务器的证书，默认使用系统的证书；
+//  queue、retries、backoff、batch 和 interval 与 http 相同。
+//
+//
+// 13. fluent:
+//
+// 通过 Forward 协议将日志发送到 Fluentd 或是 Fluent Bit，每一批日志以 PackedForward
+// 格式发送，每条日志的内容包含 message、level 和 caller 字段。可定义的属性为：
+//  network: 可以是 tcp、tcp4、tcp6 和 unix，默认为 tcp；
+//  addr:    服务的地址，必须指定；
+//  tag:     日志的标签，比如 app.logs，必须指定；
+//  ack:     是否需要服务端确认每一批日志，默认为 false；
+//  fields:  自定义字段，格式为 name1=value1;name2=value2；
+//  timeout: 连接、写入以及等待确认的超时时间，默认为 10s；
+//  queue、retries、backoff、batch 和 interval 与 http 相同。
+//
+//
+// 14. otlp:
+//
+// 通过 OTLP/HTTP 协议将日志导出到 OpenTelemetry Collector 等服务，日志级别会被转换成
+// SeverityNumber，调用位置会被转换成 code.filepath 和 code.lineno 属性。服务端返回
+// 429、502、503 和 504 时会进行重试，并优先使用 Retry-After 指定的等待时间。可定义的属性为：
+//  url:        服务地址，比如 http://localhost:4318，未以 /v1/logs 结尾时会自动加上，必须指定；
+//  format:     请求内容的格式，可以是 protobuf 和 json，默认为 protobuf；
+//  gzip:       是否使用 gzip 压缩请求内容，默认为 false；
+//  headers:    自定义报头，格式为 Name1: value1;Name2: value2；
+//  resource:   资源属性，格式为 service.name=app;deployment.environment=dev，
+//              值为空表示删除该属性，默认包含 service.name 和 host.name；
+//  attributes: 每条日志都会带上的属性，格式与 resource 相同；
+//  timeout:    每次请求的超时时间，默认为 10s；
+//  caFile:     PEM 格式的 CA 证书文件，用于验证服务器的证书，默认使用系统的证书；
+//  queue、retries、backoff、batch 和 interval 与 http 相同。
+//
+//
+// 15. splunk:
+//
+// 通过 HTTP Event Collector 的 /services/collector/event 接口将日志发送到 Splunk，
+// 事件的内容为 {"level":"...","caller":"...","message":"..."}。可定义的属性为：
+//  url:        HEC 的服务地址，比如 https://localhost:8088，必须指定；
+//  token:      HEC 的令牌，必须指定；
+//  host:       事件的 host 字段，默认为当前主机名；
+//  index:      写入的索引，默认使用令牌的默认索引；
+//  source:     事件的 source 字段；
+//  sourcetype: 事件的 sourcetype 字段；
+//  gzip:       是否使用 gzip 压缩请求内容，默认为 false；
+//  ack:        等待索引确认的最长时间，比如 30s，超时之后会进行重试，默认不需要确认；
+//  timeout:    每次请求的超时时间，默认为 30s；
+//  caFile:     PEM 格式的 CA 证书文件，用于验证服务器的证书，默认使用系统的证书；
+//  queue、retries、backoff、batch 和 interval 与 http 相同。
+//
+//
+// 16. redis:
+//
+// 将日志写入 Redis 的列表(RPUSH)或是流(XADD)，列表中的每一项为与 http 相同格式的 JSON，
+// 流中的每一项包含 time、level、caller 和 message 字段。每一批日志通过 pipeline 发送，
+// 连接断开时会重新连接并进行重试。可定义的属性为：
+//  network:  可以是 tcp、tcp4、tcp6 和 unix，默认为 tcp；
+//  addr:     服务的地址，必须指定；
+//  key:      列表或流的键名，必须指定；
+//  mode:     写入方式，可以是 list 和 stream，默认为 list；
+//  maxLen:   列表或流的最大长度，超出时删除最早的内容，默认为 0，即不限制；
+//  username: ACL 验证的用户名，为空时只使用密码验证；
+//  password: 验证的密码；
+//  db:       使用的数据库，默认为 0；
+//  timeout:  连接、写入以及读取的超时时间，默认为 10s；
+//  tls:      是否使用 TLS 加密，默认为 false；
+//  caFile:   PEM 格式的 CA 证书文件，用于验证服务器的证书，默认使用系统的证书；
+//  queue、retries、backoff、batch 和 interval 与 http 相同。
+//
+//
+// 17. db:
+//
+// 通过 database/sql 将日志写入数据表，数据表包含 log_time、log_level、message、caller
+// 和 fields 字段，其中 fields 为自定义字段组成的 JSON 对象。每一批日志以多行 INSERT
+// 的方式写入，需要在代码中通过 import 注册对应的驱动。可定义的属性为：
+//  driver:      驱动名称，比如 mysql、postgres 和 sqlite3，必须指定；
+//  dsn:         连接数据库的 DSN，必须指定；
+//  table:       数据表名称，可以带上 schema，比如 audit.logs，必须指定；
+//  placeholder: 参数的占位符格式，可以是 question(?)、dollar($1)、at(@p1) 和 colon(:1)，
+//               默认根据驱动名称判断；
+//  fields:      自定义字段，格式为 name1=value1;name2=value2；
+//  autoCreate:  是否自动创建数据表，仅适用于支持 CREATE TABLE IF NOT EXISTS 的数据库，默认为 false；
+//  keep:        日志的保留时间，比如 720h，超出的日志会被定时删除，默认不删除；
+//  every:       删除过期日志的间隔，默认为 1h；
+//  queue、retries、backoff、batch 和 interval 与 http 相同。
+//
+//
+// 18. exec:
+//
+// 启动一个外部程序，并将日志写入其标准输入，比如 logger 或是 sh -c "gzip > file"。
+// 进程在第一次写入时启动，退出之后按 backoff 的倍数递增等待时间再重新启动，
+// 等待期间的日志会被缓存；关闭时先关闭进程的标准输入，等待其退出。可定义的属性为：
+//  command:    需要执行的程序，不包含路径时从 PATH 中查找，必须指定；
+//  args:       传递给程序的参数，多个参数使用分号分隔；
+//  dir:        进程的工作目录，默认为当前目录；
+//  env:        额外的环境变量，格式为 name1=value1;name2=value2；
+//  timeout:    写入以及关闭时等待进程退出的超时时间，超时的进程会被强制结束，默认为 10s；
+//  backoff:    进程退出之后第一次重启的等待时间，之后每次翻倍，默认为 500ms；
+//  maxBackoff: 重启等待时间的上限，默认为 1m；
+//  buffer:     进程不可用期间最多缓存的内容，超出时丢弃最早的日志，默认为 1M；
+//  stderr:     是否将进程的 stderr 输出到当前进程的 stderr，默认为 false。
+//
+//
+// 19. file:
+//
+// 将日志追加到指定的文件，文件或是所在的目录不存在时会被创建，
+// 权限和所有者只对新建的文件和目录有效。可定义的属性为：
+//  path:     文件的路径，必须指定；
+//  mode:     新建文件的权限，八进制格式，默认为 0640，不受 umask 的影响；
+//  dirMode:  新建目录的权限，八进制格式，默认为 0750，不受 umask 的影响；
+//  uid:      新建文件和目录的所有者，默认不修改，windows 下不支持；
+//  gid:      新建文件和目录的所属组，默认不修改，windows 下不支持；
+//  sync:     是否以 O_SYNC 打开文件，每次写入都会等待内容写入磁盘，默认为 false；
+//  noFollow: 是否以 O_NOFOLLOW 打开文件，文件为符号链接时将打开失败，默认为 false，
+//            windows 下无效。
 //
 //
 // 自定义
@@ -95,6 +389,7 @@
 // 其中注册的名称将作为配置节点的元素名称，需要唯一，不能与现有的名称相同；
 // 函数则作为节点转换成实例时的转换功能，需要负责解析节点传递过来的属性列表(args 参数)，
 // 若是一个容器节点（如 buffer，可以包含子节点）则返回的实例必须要实现 WriteFlushAdder 接口，
+// 若需要知道所在的日志级别，则可以实现 writers.Leveler 接口，
 // 该函数的原型为：
 //  WriterInitializer
 package logs
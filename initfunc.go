@@ -5,13 +5,18 @@
 package logs
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/issue9/logs/writers"
 	"github.com/issue9/term/colors"
@@ -82,6 +87,68 @@ func argNotFoundErr(wname, argName string) error {
 	return fmt.Errorf("[%v]配置文件中未指定参数:[%v]", wname, argName)
 }
 
+// 根据 caFile 参数加载 PEM 格式的 CA 证书，用于验证服务器的证书。
+// 未指定 caFile 时返回 nil，表示使用系统的证书。
+func loadCAFile(args map[string]string) (*tls.Config, error) {
+	caFile, found := args["caFile"]
+	if !found {
+		return nil, nil
+	}
+
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("[%v]中不包含有效的证书", caFile)
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// 根据 tls 和 caFile 参数生成 TLS 配置，未启用 TLS 时返回 nil。
+func parseTLSConfig(args map[string]string) (*tls.Config, error) {
+	tlsStr, found := args["tls"]
+	if !found {
+		return nil, nil
+	}
+
+	useTLS, err := strconv.ParseBool(tlsStr)
+	if err != nil || !useTLS {
+		return nil, err
+	}
+
+	conf, err := loadCAFile(args)
+	if err != nil {
+		return nil, err
+	}
+	if conf == nil {
+		conf = &tls.Config{}
+	}
+	return conf, nil
+}
+
+// 解析 name1=value1;name2=value2 格式的属性值，并依次调用 set。
+// 属性名会去掉首尾的空格，属性值则保持原样。
+func parseAttributes(name, value string, set func(name, value string) error) error {
+	for _, attr := range strings.Split(value, ";") {
+		attr = strings.TrimSpace(attr)
+		if attr == "" {
+			continue
+		}
+
+		index := strings.IndexByte(attr, '=')
+		if index <= 0 {
+			return fmt.Errorf("无效的%v参数:[%v]", name, attr)
+		}
+		if err := set(strings.TrimSpace(attr[:index]), attr[index+1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // writers.Rotate 的初始化函数。
 func rotateInitializer(args map[string]string) (io.Writer, error) {
 	prefix, found := args["prefix"]
@@ -104,7 +171,169 @@ func rotateInitializer(args map[string]string) (io.Writer, error) {
 		return nil, err
 	}
 
-	return writers.NewRotate(prefix, dir, int(size))
+	w, err := writers.NewRotate(prefix, dir, int(size))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = parseFileOptions(w, args); err != nil {
+		return nil, err
+	}
+
+	// 指定了 s3Bucket 时，将写满的文件上传到对象存储
+	if _, found := args["s3Bucket"]; found {
+		s3, err := newS3Uploader(args)
+		if err != nil {
+			return nil, err
+		}
+		w.SetUploader(s3)
+	}
+
+	return w, nil
+}
+
+// writers.File 的初始化函数
+func fileInitializer(args map[string]string) (io.Writer, error) {
+	path, found := args["path"]
+	if !found {
+		return nil, argNotFoundErr("file", "path")
+	}
+
+	w := writers.NewFile(path)
+	if err := parseFileOptions(w, args); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// 新建文件的 writer 需要实现的接口
+type fileOptionSetter interface {
+	SetMode(mode os.FileMode) error
+	SetDirMode(mode os.FileMode) error
+	SetOwner(uid, gid int) error
+	SetSync(sync bool)
+	SetNoFollow(noFollow bool)
+}
+
+// 解析与新建文件相关的属性：mode、dirMode、uid、gid、sync 和 noFollow
+func parseFileOptions(w fileOptionSetter, args map[string]string) error {
+	if str, found := args["mode"]; found {
+		mode, err := strconv.ParseUint(str, 8, 32)
+		if err != nil {
+			return err
+		}
+		if err = w.SetMode(os.FileMode(mode)); err != nil {
+			return err
+		}
+	}
+
+	if str, found := args["dirMode"]; found {
+		mode, err := strconv.ParseUint(str, 8, 32)
+		if err != nil {
+			return err
+		}
+		if err = w.SetDirMode(os.FileMode(mode)); err != nil {
+			return err
+		}
+	}
+
+	uid, gid := -1, -1
+	var err error
+	if str, found := args["uid"]; found {
+		if uid, err = strconv.Atoi(str); err != nil {
+			return err
+		}
+	}
+	if str, found := args["gid"]; found {
+		if gid, err = strconv.Atoi(str); err != nil {
+			return err
+		}
+	}
+	if err = w.SetOwner(uid, gid); err != nil {
+		return err
+	}
+
+	if str, found := args["sync"]; found {
+		sync, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		w.SetSync(sync)
+	}
+
+	if str, found := args["noFollow"]; found {
+		noFollow, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		w.SetNoFollow(noFollow)
+	}
+
+	return nil
+}
+
+// 根据 rotate 中以 s3 开头的属性声明 writers.S3
+func newS3Uploader(args map[string]string) (*writers.S3, error) {
+	endpoint, found := args["s3Endpoint"]
+	if !found {
+		return nil, argNotFoundErr("rotate", "s3Endpoint")
+	}
+
+	s3, err := writers.NewS3(endpoint, args["s3Region"], args["s3Bucket"], args["s3AccessKey"], args["s3SecretKey"])
+	if err != nil {
+		return nil, err
+	}
+
+	if token, found := args["s3SessionToken"]; found {
+		s3.SetSessionToken(token)
+	}
+
+	if key, found := args["s3Key"]; found {
+		if err = s3.SetKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	if prefix, found := args["s3Prefix"]; found {
+		s3.SetPrefix(prefix)
+	}
+
+	if str, found := args["s3PartSize"]; found {
+		size, err := toByte(str)
+		if err != nil {
+			return nil, err
+		}
+		if err = s3.SetPartSize(size); err != nil {
+			return nil, err
+		}
+	}
+
+	if str, found := args["s3Delete"]; found {
+		del, err := strconv.ParseBool(str)
+		if err != nil {
+			return nil, err
+		}
+		s3.SetDelete(del)
+	}
+
+	if timeout, found := args["s3Timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		s3.SetTimeout(d)
+	}
+
+	conf, err := loadCAFile(args)
+	if err != nil {
+		return nil, err
+	}
+	if conf != nil {
+		s3.SetTransport(&http.Transport{TLSClientConfig: conf})
+	}
+
+	return s3, nil
 }
 
 // writers.Buffer 的初始化函数
@@ -128,16 +357,21 @@ var consoleOutputMap = map[string]*os.File{
 	"stdin":  os.Stdin,
 }
 
-var consoleColorMap = map[string]colors.Color{
-	"default": colors.Default,
-	"black":   colors.Black,
-	"red":     colors.Red,
-	"green":   colors.Green,
-	"yellow":  colors.Yellow,
-	"blue":    colors.Blue,
-	"magenta": colors.Magenta,
-	"cyan":    colors.Cyan,
-	"white":   colors.White,
+var consoleColorModeMap = map[string]int{
+	"auto":   writers.ConsoleColorAuto,
+	"always": writers.ConsoleColorAlways,
+	"never":  writers.ConsoleColorNever,
+}
+
+var consoleHighlightMap = map[string]int{
+	"line":  writers.ConsoleHighlightLine,
+	"level": writers.ConsoleHighlightLevel,
+	"time":  writers.ConsoleHighlightTime,
+}
+
+var consoleModeMap = map[string]int{
+	"raw": writers.ConsoleModeRaw,
+	"dev": writers.ConsoleModeDev,
 }
 
 // writers.Console 的初始化函数
@@ -157,8 +391,8 @@ func consoleInitializer(args map[string]string) (io.Writer, error) {
 		fcIndex = "red"
 	}
 
-	fc, found := consoleColorMap[fcIndex]
-	if !found {
+	fc, err := writers.ParseColor(fcIndex)
+	if err != nil {
 		return nil, fmt.Errorf("无效的前景色[%v]", fcIndex)
 	}
 
@@ -167,23 +401,93 @@ func consoleInitializer(args map[string]string) (io.Writer, error) {
 		bcIndex = "default"
 	}
 
-	bc, found := consoleColorMap[bcIndex]
-	if !found {
+	bc, err := writers.ParseColor(bcIndex)
+	if err != nil {
 		return nil, fmt.Errorf("无效的背景色[%v]", bcIndex)
 	}
 
-	return writers.NewConsole(output, fc, bc), nil
+	w := writers.NewConsole(output, colors.Default, colors.Default)
+	w.SetStyle(writers.ConsoleStyle{Foreground: fc, Background: bc})
+
+	// 格式为 level:foreground[/background]，多个级别之间用分号分隔
+	if levelsStr, found := args["levels"]; found {
+		styles := make(map[string]writers.ConsoleStyle)
+		for _, item := range strings.Split(levelsStr, ";") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+
+			index := strings.IndexByte(item, ':')
+			if index <= 0 {
+				return nil, fmt.Errorf("无效的levels参数[%v]", item)
+			}
+
+			style, err := writers.ParseConsoleStyle(item[index+1:])
+			if err != nil {
+				return nil, err
+			}
+			styles[strings.ToLower(item[:index])] = style
+		}
+		w.SetLevelStyles(styles)
+	}
+
+	if modeStr, found := args["mode"]; found {
+		mode, found := consoleModeMap[strings.ToLower(modeStr)]
+		if !found {
+			return nil, fmt.Errorf("无效的输出模式[%v]", modeStr)
+		}
+		if err := w.SetMode(mode); err != nil {
+			return nil, err
+		}
+	}
+
+	if hlStr, found := args["highlight"]; found {
+		h, found := consoleHighlightMap[strings.ToLower(hlStr)]
+		if !found {
+			return nil, fmt.Errorf("无效的高亮范围[%v]", hlStr)
+		}
+		if err := w.SetHighlight(h); err != nil {
+			return nil, err
+		}
+	}
+
+	if colorStr, found := args["color"]; found {
+		mode, found := consoleColorModeMap[strings.ToLower(colorStr)]
+		if !found {
+			return nil, fmt.Errorf("无效的色彩模式[%v]", colorStr)
+		}
+		if err := w.SetColorMode(mode); err != nil {
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+var stmpTLSMap = map[string]int{
+	"auto":     writers.SmtpTLSAuto,
+	"starttls": writers.SmtpTLSStartTLS,
+	"tls":      writers.SmtpTLSImplicit,
+	"none":     writers.SmtpTLSNone,
 }
 
 // writers.Stmp 的初始化函数
 func stmpInitializer(args map[string]string) (io.Writer, error) {
-	username, found := args["username"]
+	auth, found := args["auth"]
 	if !found {
+		auth = writers.SmtpAuthPlain
+	}
+	auth = strings.ToLower(auth)
+
+	// 不需要认证时，账号和密码都是可选的。
+	username, found := args["username"]
+	if !found && auth != writers.SmtpAuthNone {
 		return nil, argNotFoundErr("stmp", "username")
 	}
 
 	password, found := args["password"]
-	if !found {
+	if !found && auth != writers.SmtpAuthNone {
 		return nil, argNotFoundErr("stmp", "password")
 	}
 
@@ -204,38 +508,1181 @@ func stmpInitializer(args map[string]string) (io.Writer, error) {
 
 	sendTo := strings.Split(sendToStr, ";")
 
-	return writers.NewSmtp(username, password, subject, host, sendTo), nil
+	from := args["from"]
+	if from == "" && username == "" {
+		return nil, argNotFoundErr("stmp", "from")
+	}
+
+	w := writers.NewSmtp(username, password, subject, host, sendTo)
+	if err := w.SetAuth(auth); err != nil {
+		return nil, err
+	}
+	w.SetFrom(from)
+	w.SetSender(args["sender"])
+	w.SetAttachment(args["attachment"])
+
+	if htmlStr, found := args["html"]; found {
+		html, err := strconv.ParseBool(htmlStr)
+		if err != nil {
+			return nil, err
+		}
+		w.SetHTML(html)
+	}
+
+	tlsStr, found := args["tls"]
+	if !found {
+		tlsStr = "auto"
+	}
+	mode, found := stmpTLSMap[strings.ToLower(tlsStr)]
+	if !found {
+		return nil, fmt.Errorf("无效的tls参数:[%v]", tlsStr)
+	}
+
+	conf, err := loadCAFile(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.SetTLS(mode, conf); err != nil {
+		return nil, err
+	}
+
+	if timeout, found := args["timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.SetTimeout(d)
+	}
+
+	// 指定了 queue 才会启用异步发送
+	if queue, found := args["queue"]; found {
+		size, err := strconv.Atoi(queue)
+		if err != nil {
+			return nil, err
+		}
+
+		retries := 3
+		if str, found := args["retries"]; found {
+			if retries, err = strconv.Atoi(str); err != nil {
+				return nil, err
+			}
+		}
+
+		backoff := time.Second
+		if str, found := args["backoff"]; found {
+			if backoff, err = time.ParseDuration(str); err != nil {
+				return nil, err
+			}
+		}
+
+		w.SetAsync(size, retries, backoff)
+	}
+
+	return w, nil
 }
 
-var flagMap = map[string]int{
-	"none":              0,
-	"log.ldate":         log.Ldate,
-	"log.ltime":         log.Ltime,
-	"log.lmicroseconds": log.Lmicroseconds,
-	"log.llongfile":     log.Llongfile,
-	"log.lshortfile":    log.Lshortfile,
-	"log.lstdflags":     log.LstdFlags,
+var syslogFormatMap = map[string]int{
+	"rfc3164": writers.SyslogRFC3164,
+	"rfc5424": writers.SyslogRFC5424,
 }
 
-func logContInitializer(args map[string]string) (io.Writer, error) {
-	return writers.NewContainer(), nil
+var syslogFacilityMap = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
 }
 
-func init() {
-	if !Register("stmp", stmpInitializer) {
-		panic("注册stmp时失败")
+// writers.Syslog 的初始化函数
+func syslogInitializer(args map[string]string) (io.Writer, error) {
+	network := strings.ToLower(args["network"])
+	switch network {
+	case "", "unix", "unixgram", "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("无效的network参数:[%v]", network)
 	}
 
-	if !Register("console", consoleInitializer) {
-		panic("注册console时失败")
+	addr, found := args["addr"]
+	if !found && network != "" {
+		return nil, argNotFoundErr("syslog", "addr")
 	}
 
-	if !Register("buffer", bufferInitializer) {
-		panic("注册buffer时失败")
+	w := writers.NewSyslog(network, addr, args["appName"])
+
+	if formatStr, found := args["format"]; found {
+		format, found := syslogFormatMap[strings.ToLower(formatStr)]
+		if !found {
+			return nil, fmt.Errorf("无效的format参数:[%v]", formatStr)
+		}
+		if err := w.SetFormat(format); err != nil {
+			return nil, err
+		}
 	}
 
-	if !Register("rotate", rotateInitializer) {
-		panic("注册rotate时失败")
+	if facilityStr, found := args["facility"]; found {
+		facility, found := syslogFacilityMap[strings.ToLower(facilityStr)]
+		if !found {
+			return nil, fmt.Errorf("无效的facility参数:[%v]", facilityStr)
+		}
+		if err := w.SetFacility(facility); err != nil {
+			return nil, err
+		}
+	}
+
+	if procID, found := args["procID"]; found {
+		w.SetProcID(procID)
+	}
+
+	if timeout, found := args["timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.SetTimeout(d)
+	}
+
+	conf, err := parseTLSConfig(args)
+	if err != nil {
+		return nil, err
+	}
+	if conf != nil {
+		w.SetTLS(conf)
+	}
+
+	return w, nil
+}
+
+// writers.Journald 的初始化函数
+func journaldInitializer(args map[string]string) (io.Writer, error) {
+	w := writers.NewJournald(args["path"])
+
+	if identifier, found := args["identifier"]; found {
+		w.SetIdentifier(identifier)
+	}
+
+	// fields 的格式为 KEY1=value1;KEY2=value2
+	if err := parseAttributes("fields", args["fields"], w.SetField); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+var httpFormatMap = map[string]int{
+	"json":   writers.HTTPFormatJSON,
+	"ndjson": writers.HTTPFormatNDJSON,
+}
+
+// writers.HTTP 的初始化函数
+func httpInitializer(args map[string]string) (io.Writer, error) {
+	url, found := args["url"]
+	if !found {
+		return nil, argNotFoundErr("http", "url")
+	}
+	w := writers.NewHTTP(url)
+
+	if formatStr, found := args["format"]; found {
+		format, found := httpFormatMap[strings.ToLower(formatStr)]
+		if !found {
+			return nil, fmt.Errorf("无效的format参数:[%v]", formatStr)
+		}
+		if err := w.SetFormat(format); err != nil {
+			return nil, err
+		}
+	}
+
+	if gzipStr, found := args["gzip"]; found {
+		gzip, err := strconv.ParseBool(gzipStr)
+		if err != nil {
+			return nil, err
+		}
+		w.SetGzip(gzip)
+	}
+
+	// headers 的格式为 Name1: value1;Name2: value2
+	if headers, found := args["headers"]; found {
+		for _, header := range strings.Split(headers, ";") {
+			if strings.TrimSpace(header) == "" {
+				continue
+			}
+
+			index := strings.IndexByte(header, ':')
+			if index <= 0 {
+				return nil, fmt.Errorf("无效的headers参数:[%v]", header)
+			}
+			w.SetHeader(strings.TrimSpace(header[:index]), strings.TrimSpace(header[index+1:]))
+		}
+	}
+
+	if username, found := args["username"]; found {
+		w.SetBasicAuth(username, args["password"])
+	}
+
+	if token, found := args["token"]; found {
+		w.SetBearer(token)
+	}
+
+	if timeout, found := args["timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.SetTimeout(d)
+	}
+
+	conf, err := loadCAFile(args)
+	if err != nil {
+		return nil, err
+	}
+	if conf != nil {
+		w.SetTransport(&http.Transport{TLSClientConfig: conf})
+	}
+
+	if err = parseQueue(w, args); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// writers.Elasticsearch 的初始化函数
+func elasticsearchInitializer(args map[string]string) (io.Writer, error) {
+	url, found := args["url"]
+	if !found {
+		return nil, argNotFoundErr("elasticsearch", "url")
+	}
+
+	index, found := args["index"]
+	if !found {
+		return nil, argNotFoundErr("elasticsearch", "index")
+	}
+
+	w := writers.NewElasticsearch(url, index)
+
+	if username, found := args["username"]; found {
+		w.SetBasicAuth(username, args["password"])
+	}
+
+	if apiKey, found := args["apiKey"]; found {
+		w.SetAPIKey(apiKey)
+	}
+
+	if timeout, found := args["timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.SetTimeout(d)
+	}
+
+	conf, err := loadCAFile(args)
+	if err != nil {
+		return nil, err
+	}
+	if conf != nil {
+		w.SetTransport(&http.Transport{TLSClientConfig: conf})
+	}
+
+	if err = parseQueue(w, args); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+var lokiFormatMap = map[string]int{
+	"json":     writers.LokiFormatJSON,
+	"protobuf": writers.LokiFormatProtobuf,
+}
+
+// writers.Loki 的初始化函数
+func lokiInitializer(args map[string]string) (io.Writer, error) {
+	url, found := args["url"]
+	if !found {
+		return nil, argNotFoundErr("loki", "url")
+	}
+	w := writers.NewLoki(url)
+
+	if formatStr, found := args["format"]; found {
+		format, found := lokiFormatMap[strings.ToLower(formatStr)]
+		if !found {
+			return nil, fmt.Errorf("无效的format参数:[%v]", formatStr)
+		}
+		if err := w.SetFormat(format); err != nil {
+			return nil, err
+		}
+	}
+
+	// labels 的格式为 name1=value1;name2=value2
+	if err := parseAttributes("labels", args["labels"], w.SetLabel); err != nil {
+		return nil, err
+	}
+
+	if tenant, found := args["tenant"]; found {
+		w.SetTenant(tenant)
+	}
+
+	if username, found := args["username"]; found {
+		w.SetBasicAuth(username, args["password"])
+	}
+
+	if timeout, found := args["timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.SetTimeout(d)
+	}
+
+	conf, err := loadCAFile(args)
+	if err != nil {
+		return nil, err
+	}
+	if conf != nil {
+		w.SetTransport(&http.Transport{TLSClientConfig: conf})
+	}
+
+	if err = parseQueue(w, args); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// writers.Fluent 的初始化函数
+func fluentInitializer(args map[string]string) (io.Writer, error) {
+	network, found := args["network"]
+	if !found {
+		network = "tcp"
+	}
+
+	addr, found := args["addr"]
+	if !found {
+		return nil, argNotFoundErr("fluent", "addr")
+	}
+
+	tag, found := args["tag"]
+	if !found {
+		return nil, argNotFoundErr("fluent", "tag")
+	}
+
+	w, err := writers.NewFluent(strings.ToLower(network), addr, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	if ackStr, found := args["ack"]; found {
+		ack, err := strconv.ParseBool(ackStr)
+		if err != nil {
+			return nil, err
+		}
+		w.SetAck(ack)
+	}
+
+	// fields 的格式为 name1=value1;name2=value2
+	if err := parseAttributes("fields", args["fields"], w.SetField); err != nil {
+		return nil, err
+	}
+
+	if timeout, found := args["timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.SetTimeout(d)
+	}
+
+	if err = parseQueue(w, args); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+var otlpFormatMap = map[string]int{
+	"protobuf": writers.OTLPFormatProtobuf,
+	"json":     writers.OTLPFormatJSON,
+}
+
+// writers.OTLP 的初始化函数
+func otlpInitializer(args map[string]string) (io.Writer, error) {
+	url, found := args["url"]
+	if !found {
+		return nil, argNotFoundErr("otlp", "url")
+	}
+	w := writers.NewOTLP(url)
+
+	if formatStr, found := args["format"]; found {
+		format, found := otlpFormatMap[strings.ToLower(formatStr)]
+		if !found {
+			return nil, fmt.Errorf("无效的format参数:[%v]", formatStr)
+		}
+		if err := w.SetFormat(format); err != nil {
+			return nil, err
+		}
+	}
+
+	if gzipStr, found := args["gzip"]; found {
+		gzip, err := strconv.ParseBool(gzipStr)
+		if err != nil {
+			return nil, err
+		}
+		w.SetGzip(gzip)
+	}
+
+	// headers 的格式为 Name1: value1;Name2: value2
+	if headers, found := args["headers"]; found {
+		for _, header := range strings.Split(headers, ";") {
+			if strings.TrimSpace(header) == "" {
+				continue
+			}
+
+			index := strings.IndexByte(header, ':')
+			if index <= 0 {
+				return nil, fmt.Errorf("无效的headers参数:[%v]", header)
+			}
+			w.SetHeader(strings.TrimSpace(header[:index]), strings.TrimSpace(header[index+1:]))
+		}
+	}
+
+	// resource 和 attributes 的格式为 name1=value1;name2=value2
+	if err := parseAttributes("resource", args["resource"], w.SetResource); err != nil {
+		return nil, err
+	}
+	if err := parseAttributes("attributes", args["attributes"], w.SetAttribute); err != nil {
+		return nil, err
+	}
+
+	if timeout, found := args["timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.SetTimeout(d)
+	}
+
+	conf, err := loadCAFile(args)
+	if err != nil {
+		return nil, err
+	}
+	if conf != nil {
+		w.SetTransport(&http.Transport{TLSClientConfig: conf})
+	}
+
+	if err = parseQueue(w, args); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// writers.Splunk 的初始化函数
+func splunkInitializer(args map[string]string) (io.Writer, error) {
+	url, found := args["url"]
+	if !found {
+		return nil, argNotFoundErr("splunk", "url")
+	}
+
+	token, found := args["token"]
+	if !found {
+		return nil, argNotFoundErr("splunk", "token")
+	}
+
+	w := writers.NewSplunk(url, token)
+
+	if host, found := args["host"]; found {
+		w.SetHost(host)
+	}
+	w.SetIndex(args["index"])
+	w.SetSource(args["source"])
+	w.SetSourcetype(args["sourcetype"])
+
+	if gzipStr, found := args["gzip"]; found {
+		gzip, err := strconv.ParseBool(gzipStr)
+		if err != nil {
+			return nil, err
+		}
+		w.SetGzip(gzip)
+	}
+
+	if ack, found := args["ack"]; found {
+		d, err := time.ParseDuration(ack)
+		if err != nil {
+			return nil, err
+		}
+		if err = w.SetAck(d); err != nil {
+			return nil, err
+		}
+	}
+
+	if timeout, found := args["timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.SetTimeout(d)
+	}
+
+	conf, err := loadCAFile(args)
+	if err != nil {
+		return nil, err
+	}
+	if conf != nil {
+		w.SetTransport(&http.Transport{TLSClientConfig: conf})
+	}
+
+	if err = parseQueue(w, args); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+var redisModeMap = map[string]int{
+	"list":   writers.RedisList,
+	"stream": writers.RedisStream,
+}
+
+// writers.Redis 的初始化函数
+func redisInitializer(args map[string]string) (io.Writer, error) {
+	network, found := args["network"]
+	if !found {
+		network = "tcp"
+	}
+
+	addr, found := args["addr"]
+	if !found {
+		return nil, argNotFoundErr("redis", "addr")
+	}
+
+	key, found := args["key"]
+	if !found {
+		return nil, argNotFoundErr("redis", "key")
+	}
+
+	w, err := writers.NewRedis(strings.ToLower(network), addr, key)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := writers.RedisList
+	if modeStr, found := args["mode"]; found {
+		if mode, found = redisModeMap[strings.ToLower(modeStr)]; !found {
+			return nil, fmt.Errorf("无效的mode参数:[%v]", modeStr)
+		}
+	}
+	maxLen := 0
+	if str, found := args["maxLen"]; found {
+		if maxLen, err = strconv.Atoi(str); err != nil {
+			return nil, err
+		}
+	}
+	if err = w.SetMode(mode, maxLen); err != nil {
+		return nil, err
+	}
+
+	if password, found := args["password"]; found {
+		w.SetAuth(args["username"], password)
+	}
+
+	if str, found := args["db"]; found {
+		db, err := strconv.Atoi(str)
+		if err != nil {
+			return nil, err
+		}
+		if err = w.SetDB(db); err != nil {
+			return nil, err
+		}
+	}
+
+	if timeout, found := args["timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.SetTimeout(d)
+	}
+
+	conf, err := parseTLSConfig(args)
+	if err != nil {
+		return nil, err
+	}
+	if conf != nil {
+		w.SetTLS(conf)
+	}
+
+	if err = parseQueue(w, args); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+var dbPlaceholderMap = map[string]int{
+	"question": writers.DBPlaceholderQuestion,
+	"dollar":   writers.DBPlaceholderDollar,
+	"at":       writers.DBPlaceholderAt,
+	"colon":    writers.DBPlaceholderColon,
+}
+
+// writers.DB 的初始化函数
+func dbInitializer(args map[string]string) (io.Writer, error) {
+	driver, found := args["driver"]
+	if !found {
+		return nil, argNotFoundErr("db", "driver")
+	}
+
+	dsn, found := args["dsn"]
+	if !found {
+		return nil, argNotFoundErr("db", "dsn")
+	}
+
+	table, found := args["table"]
+	if !found {
+		return nil, argNotFoundErr("db", "table")
+	}
+
+	w, err := writers.NewDB(driver, dsn, table)
+	if err != nil {
+		return nil, err
+	}
+
+	if str, found := args["placeholder"]; found {
+		placeholder, found := dbPlaceholderMap[strings.ToLower(str)]
+		if !found {
+			return nil, fmt.Errorf("无效的placeholder参数:[%v]", str)
+		}
+		if err = w.SetPlaceholder(placeholder); err != nil {
+			return nil, err
+		}
+	}
+
+	// fields 的格式为 name1=value1;name2=value2
+	fields := map[string]string{}
+	err = parseAttributes("fields", args["fields"], func(name, value string) error {
+		fields[name] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = w.SetFields(fields); err != nil {
+		return nil, err
+	}
+
+	if str, found := args["autoCreate"]; found {
+		create, err := strconv.ParseBool(str)
+		if err != nil {
+			return nil, err
+		}
+		w.SetAutoCreate(create)
+	}
+
+	if str, found := args["keep"]; found {
+		keep, err := time.ParseDuration(str)
+		if err != nil {
+			return nil, err
+		}
+
+		every := time.Hour
+		if str, found = args["every"]; found {
+			if every, err = time.ParseDuration(str); err != nil {
+				return nil, err
+			}
+		}
+
+		if err = w.SetRetention(keep, every); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = parseQueue(w, args); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// writers.Exec 的初始化函数
+func execInitializer(args map[string]string) (io.Writer, error) {
+	command, found := args["command"]
+	if !found {
+		return nil, argNotFoundErr("exec", "command")
+	}
+
+	var cmdArgs []string
+	if str, found := args["args"]; found && str != "" {
+		cmdArgs = strings.Split(str, ";")
+	}
+
+	w, err := writers.NewExec(command, cmdArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	if dir, found := args["dir"]; found {
+		w.SetDir(dir)
+	}
+
+	// env 的格式为 name1=value1;name2=value2
+	env := []string{}
+	err = parseAttributes("env", args["env"], func(name, value string) error {
+		env = append(env, name+"="+value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.SetEnv(env)
+
+	if timeout, found := args["timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.SetTimeout(d)
+	}
+
+	backoff, maxBackoff := 500*time.Millisecond, time.Minute
+	if str, found := args["backoff"]; found {
+		if backoff, err = time.ParseDuration(str); err != nil {
+			return nil, err
+		}
+	}
+	if str, found := args["maxBackoff"]; found {
+		if maxBackoff, err = time.ParseDuration(str); err != nil {
+			return nil, err
+		}
+	}
+	w.SetBackoff(backoff, maxBackoff)
+
+	if str, found := args["buffer"]; found {
+		size, err := toByte(str)
+		if err != nil {
+			return nil, err
+		}
+		w.SetBuffer(int(size))
+	}
+
+	if str, found := args["stderr"]; found {
+		show, err := strconv.ParseBool(str)
+		if err != nil {
+			return nil, err
+		}
+		if show {
+			w.SetStderr(os.Stderr)
+		}
+	}
+
+	return w, nil
+}
+
+// 批量发送的 writer 需要实现的接口
+type queueSetter interface {
+	SetQueue(size, retries int, backoff time.Duration) error
+	SetBatch(size int, interval time.Duration) error
+}
+
+// 解析与队列相关的属性：queue、retries、backoff、batch 和 interval
+func parseQueue(w queueSetter, args map[string]string) (err error) {
+	size, retries, backoff := 1000, 3, time.Second
+	if str, found := args["queue"]; found {
+		if size, err = strconv.Atoi(str); err != nil {
+			return err
+		}
+	}
+	if str, found := args["retries"]; found {
+		if retries, err = strconv.Atoi(str); err != nil {
+			return err
+		}
+	}
+	if str, found := args["backoff"]; found {
+		if backoff, err = time.ParseDuration(str); err != nil {
+			return err
+		}
+	}
+	if err = w.SetQueue(size, retries, backoff); err != nil {
+		return err
+	}
+
+	batch, interval := 100, time.Second
+	if str, found := args["batch"]; found {
+		if batch, err = strconv.Atoi(str); err != nil {
+			return err
+		}
+	}
+	if str, found := args["interval"]; found {
+		if interval, err = time.ParseDuration(str); err != nil {
+			return err
+		}
+	}
+	return w.SetBatch(batch, interval)
+}
+
+var robotPlatformMap = map[string]int{
+	"dingtalk": writers.RobotDingTalk,
+	"wecom":    writers.RobotWeCom,
+	"feishu":   writers.RobotFeishu,
+	"slack":    writers.RobotSlack,
+}
+
+// writers.Robot 的初始化函数
+func robotInitializer(args map[string]string) (io.Writer, error) {
+	platformStr, found := args["platform"]
+	if !found {
+		return nil, argNotFoundErr("robot", "platform")
+	}
+	platform, found := robotPlatformMap[strings.ToLower(platformStr)]
+	if !found {
+		return nil, fmt.Errorf("无效的platform参数:[%v]", platformStr)
+	}
+
+	webhook, found := args["webhook"]
+	if !found {
+		return nil, argNotFoundErr("robot", "webhook")
+	}
+
+	w, err := writers.NewRobot(platform, webhook)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret, found := args["secret"]; found {
+		w.SetSecret(secret)
+	}
+
+	// mentions 以逗号分隔，all 表示所有人
+	if mentions, found := args["mentions"]; found {
+		ids := make([]string, 0, 5)
+		all := false
+		for _, id := range strings.Split(mentions, ",") {
+			id = strings.TrimSpace(id)
+			switch {
+			case id == "":
+			case strings.ToLower(id) == "all":
+				all = true
+			default:
+				ids = append(ids, id)
+			}
+		}
+		w.SetMentions(ids, all)
+	}
+
+	if window, found := args["window"]; found {
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			return nil, err
+		}
+		w.SetWindow(d)
+	}
+
+	// rateLimit 的格式为 count/duration，比如 20/1m
+	if rate, found := args["rateLimit"]; found {
+		index := strings.IndexByte(rate, '/')
+		if index <= 0 {
+			return nil, fmt.Errorf("无效的rateLimit参数:[%v]", rate)
+		}
+		count, err := strconv.Atoi(rate[:index])
+		if err != nil {
+			return nil, err
+		}
+		per, err := time.ParseDuration(rate[index+1:])
+		if err != nil {
+			return nil, err
+		}
+		w.SetRateLimit(count, per)
+	}
+
+	if timeout, found := args["timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.SetTimeout(d)
+	}
+
+	return w, nil
+}
+
+// writers.Net 的初始化函数
+func netInitializer(args map[string]string) (io.Writer, error) {
+	network, found := args["network"]
+	if !found {
+		return nil, argNotFoundErr("net", "network")
+	}
+
+	addr, found := args["addr"]
+	if !found {
+		return nil, argNotFoundErr("net", "addr")
+	}
+
+	w, err := writers.NewNet(strings.ToLower(network), addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout, found := args["timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.SetTimeout(d)
+	}
+
+	backoff, maxBackoff := 500*time.Millisecond, time.Minute
+	if str, found := args["backoff"]; found {
+		if backoff, err = time.ParseDuration(str); err != nil {
+			return nil, err
+		}
+	}
+	if str, found := args["maxBackoff"]; found {
+		if maxBackoff, err = time.ParseDuration(str); err != nil {
+			return nil, err
+		}
+	}
+	w.SetBackoff(backoff, maxBackoff)
+
+	if str, found := args["buffer"]; found {
+		size, err := toByte(str)
+		if err != nil {
+			return nil, err
+		}
+		w.SetBuffer(int(size))
+	}
+
+	if str, found := args["maxDatagram"]; found {
+		size, err := toByte(str)
+		if err != nil {
+			return nil, err
+		}
+		if err = w.SetMaxDatagram(int(size)); err != nil {
+			return nil, err
+		}
+	}
+
+	conf, err := parseTLSConfig(args)
+	if err != nil {
+		return nil, err
+	}
+	if conf != nil {
+		w.SetTLS(conf)
+	}
+
+	return w, nil
+}
+
+var gelfCompressMap = map[string]int{
+	"none": writers.GelfCompressNone,
+	"gzip": writers.GelfCompressGzip,
+	"zlib": writers.GelfCompressZlib,
+}
+
+// writers.Gelf 的初始化函数
+func gelfInitializer(args map[string]string) (io.Writer, error) {
+	network, found := args["network"]
+	if !found {
+		network = "udp"
+	}
+
+	addr, found := args["addr"]
+	if !found {
+		return nil, argNotFoundErr("gelf", "addr")
+	}
+
+	w, err := writers.NewGelf(strings.ToLower(network), addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if host, found := args["host"]; found {
+		w.SetHost(host)
+	}
+
+	// fields 的格式为 name1=value1;name2=value2
+	if err := parseAttributes("fields", args["fields"], w.SetField); err != nil {
+		return nil, err
+	}
+
+	if compressStr, found := args["compress"]; found {
+		compress, found := gelfCompressMap[strings.ToLower(compressStr)]
+		if !found {
+			return nil, fmt.Errorf("无效的compress参数:[%v]", compressStr)
+		}
+		if err := w.SetCompress(compress); err != nil {
+			return nil, err
+		}
+	}
+
+	if str, found := args["chunkSize"]; found {
+		size, err := toByte(str)
+		if err != nil {
+			return nil, err
+		}
+		if err = w.SetChunkSize(int(size)); err != nil {
+			return nil, err
+		}
+	}
+
+	if timeout, found := args["timeout"]; found {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		w.SetTimeout(d)
+	}
+
+	conf, err := parseTLSConfig(args)
+	if err != nil {
+		return nil, err
+	}
+	if conf != nil {
+		w.SetTLS(conf)
+	}
+
+	return w, nil
+}
+
+var flagMap = map[string]int{
+	"none":              0,
+	"log.ldate":         log.Ldate,
+	"log.ltime":         log.Ltime,
+	"log.lmicroseconds": log.Lmicroseconds,
+	"log.llongfile":     log.Llongfile,
+	"log.lshortfile":    log.Lshortfile,
+	"log.lstdflags":     log.LstdFlags,
+}
+
+var containerPolicyMap = map[string]int{
+	"stop":     writers.ContainerStopOnError,
+	"continue": writers.ContainerContinueOnError,
+}
+
+// info、debug 等日志级别的初始化函数，
+// 除了 prefix 和 flag 之外，还可以指定子项的输出方式。
+func logContInitializer(args map[string]string) (io.Writer, error) {
+	c := writers.NewContainer()
+
+	if policyStr, found := args["policy"]; found {
+		policy, found := containerPolicyMap[strings.ToLower(policyStr)]
+		if !found {
+			return nil, fmt.Errorf("无效的policy参数:[%v]", policyStr)
+		}
+		if err := c.SetPolicy(policy); err != nil {
+			return nil, err
+		}
+	}
+
+	if parallelStr, found := args["parallel"]; found {
+		parallel, err := strconv.ParseBool(parallelStr)
+		if err != nil {
+			return nil, err
+		}
+
+		var timeout time.Duration
+		if timeoutStr, found := args["timeout"]; found {
+			if timeout, err = time.ParseDuration(timeoutStr); err != nil {
+				return nil, err
+			}
+		}
+
+		c.SetParallel(parallel, timeout)
+	}
+
+	return c, nil
+}
+
+func init() {
+	if !Register("stmp", stmpInitializer) {
+		panic("注册stmp时失败")
+	}
+
+	if !Register("console", consoleInitializer) {
+		panic("注册console时失败")
+	}
+
+	if !Register("buffer", bufferInitializer) {
+		panic("注册buffer时失败")
+	}
+
+	if !Register("rotate", rotateInitializer) {
+		panic("注册rotate时失败")
+	}
+
+	if !Register("syslog", syslogInitializer) {
+		panic("注册syslog时失败")
+	}
+
+	if !Register("journald", journaldInitializer) {
+		panic("注册journald时失败")
+	}
+
+	if !Register("http", httpInitializer) {
+		panic("注册http时失败")
+	}
+
+	if !Register("robot", robotInitializer) {
+		panic("注册robot时失败")
+	}
+
+	if !Register("net", netInitializer) {
+		panic("注册net时失败")
+	}
+
+	if !Register("gelf", gelfInitializer) {
+		panic("注册gelf时失败")
+	}
+
+	if !Register("elasticsearch", elasticsearchInitializer) {
+		panic("注册elasticsearch时失败")
+	}
+
+	if !Register("loki", lokiInitializer) {
+		panic("注册loki时失败")
+	}
+
+	if !Register("fluent", fluentInitializer) {
+		panic("注册fluent时失败")
+	}
+
+	if !Register("otlp", otlpInitializer) {
+		panic("注册otlp时失败")
+	}
+
+	if !Register("splunk", splunkInitializer) {
+		panic("注册splunk时失败")
+	}
+
+	if !Register("redis", redisInitializer) {
+		panic("注册redis时失败")
+	}
+
+	if !Register("db", dbInitializer) {
+		panic("注册db时失败")
+	}
+
+	if !Register("exec", execInitializer) {
+		panic("注册exec时失败")
+	}
+
+	if !Register("file", fileInitializer) {
+		panic("注册file时失败")
 	}
 
 	// logWriter
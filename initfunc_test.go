@@ -5,10 +5,15 @@
 package logs
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
 	"testing"
 
 	"github.com/issue9/assert"
 	"github.com/issue9/logs/writers"
+	"github.com/issue9/logs/writers/smtptest"
 )
 
 func TestToByte(t *testing.T) {
@@ -37,6 +42,22 @@ func TestToByte(t *testing.T) {
 	e("10MB")
 }
 
+func TestParseAttributes(t *testing.T) {
+	a := assert.New(t)
+
+	attrs := map[string]string{}
+	set := func(name, value string) error {
+		attrs[name] = value
+		return nil
+	}
+
+	a.NotError(parseAttributes("fields", "k1= v1;; k2 =v 2 ;", set))
+	a.Equal(attrs, map[string]string{"k1": " v1", "k2": "v 2"})
+
+	a.Error(parseAttributes("fields", "k1=v1;=v2", set))
+	a.Error(parseAttributes("fields", "k1", set))
+}
+
 func TestRotateInitializer(t *testing.T) {
 	a := assert.New(t)
 	args := map[string]string{}
@@ -61,6 +82,31 @@ func TestRotateInitializer(t *testing.T) {
 
 	_, ok := w.(*writers.Rotate)
 	a.True(ok)
+
+	// 缺少s3Endpoint
+	args["s3Bucket"] = "logs"
+	w, err = rotateInitializer(args)
+	a.Error(err).Nil(w)
+
+	// 错误的s3PartSize参数
+	args["s3Endpoint"] = "http://localhost:9000"
+	args["s3PartSize"] = "1k"
+	w, err = rotateInitializer(args)
+	a.Error(err).Nil(w)
+
+	args["s3PartSize"] = "5m"
+	args["s3Delete"] = "true"
+	w, err = rotateInitializer(args)
+	a.NotError(err).NotNil(w)
+
+	// 错误的mode参数
+	args["mode"] = "0999"
+	w, err = rotateInitializer(args)
+	a.Error(err).Nil(w)
+	args["mode"] = "0600"
+	args["dirMode"] = "0700"
+	w, err = rotateInitializer(args)
+	a.NotError(err).NotNil(w)
 }
 
 func TestBufferInitializer(t *testing.T) {
@@ -111,6 +157,48 @@ func TestConsoleInitializer(t *testing.T) {
 	w, err = consoleInitializer(args)
 	a.NotError(err).NotNil(w)
 
+	// 无效的 color
+	args["color"] = "yes"
+	w, err = consoleInitializer(args)
+	a.Error(err).Nil(w)
+
+	args["color"] = "Never"
+	w, err = consoleInitializer(args)
+	a.NotError(err).NotNil(w)
+
+	// 256 色和真彩色
+	args["foreground"] = "208"
+	args["background"] = "#000000"
+	w, err = consoleInitializer(args)
+	a.NotError(err).NotNil(w)
+
+	// 无效的 levels
+	args["levels"] = "info"
+	w, err = consoleInitializer(args)
+	a.Error(err).Nil(w)
+	args["levels"] = "info:green1"
+	w, err = consoleInitializer(args)
+	a.Error(err).Nil(w)
+	args["levels"] = "info:green;error:white/red;"
+	w, err = consoleInitializer(args)
+	a.NotError(err).NotNil(w)
+
+	// 无效的 highlight
+	args["highlight"] = "message"
+	w, err = consoleInitializer(args)
+	a.Error(err).Nil(w)
+	args["highlight"] = "level"
+	w, err = consoleInitializer(args)
+	a.NotError(err).NotNil(w)
+
+	// 无效的 mode
+	args["mode"] = "prod"
+	w, err = consoleInitializer(args)
+	a.Error(err).Nil(w)
+	args["mode"] = "dev"
+	w, err = consoleInitializer(args)
+	a.NotError(err).NotNil(w)
+
 	_, ok := w.(*writers.Console)
 	a.True(ok)
 }
@@ -131,4 +219,651 @@ func TestStmpInitializer(t *testing.T) {
 
 	_, ok := w.(*writers.Smtp)
 	a.True(ok)
+
+	// 无效的 tls 参数
+	args["tls"] = "ssl"
+	w, err = stmpInitializer(args)
+	a.Error(err).Nil(w)
+	args["tls"] = "STARTTLS"
+	w, err = stmpInitializer(args)
+	a.NotError(err).NotNil(w)
+
+	// 无效的 html 参数
+	args["html"] = "yes"
+	w, err = stmpInitializer(args)
+	a.Error(err).Nil(w)
+	args["html"] = "true"
+	args["attachment"] = "error.log"
+	w, err = stmpInitializer(args)
+	a.NotError(err).NotNil(w)
+
+	// 无效的 auth 参数
+	args["auth"] = "xoauth"
+	w, err = stmpInitializer(args)
+	a.Error(err).Nil(w)
+
+	// 不存在的 caFile
+	args["auth"] = "login"
+	args["caFile"] = "./testdata/not-exists.pem"
+	w, err = stmpInitializer(args)
+	a.Error(err).Nil(w)
+	delete(args, "caFile")
+
+	// 无效的 timeout 和 queue 参数
+	args["timeout"] = "10"
+	w, err = stmpInitializer(args)
+	a.Error(err).Nil(w)
+	args["timeout"] = "10s"
+	args["queue"] = "x"
+	w, err = stmpInitializer(args)
+	a.Error(err).Nil(w)
+	args["queue"] = "10"
+	args["backoff"] = "1x"
+	w, err = stmpInitializer(args)
+	a.Error(err).Nil(w)
+	args["backoff"] = "100ms"
+	w, err = stmpInitializer(args)
+	a.NotError(err).NotNil(w)
+	a.NotError(w.(*writers.Smtp).Close())
+
+	// auth=none 时，不需要 username 和 password，但需要 from
+	args = map[string]string{
+		"auth":    "none",
+		"subject": "subject",
+		"host":    "localhost:25",
+		"sendTo":  "sendTo",
+	}
+	w, err = stmpInitializer(args)
+	a.Error(err).Nil(w)
+	args["from"] = "logs@example.com"
+	w, err = stmpInitializer(args)
+	a.NotError(err).NotNil(w)
+
+	// 发送到本地的测试服务器
+	srv := smtptest.NewServer()
+	defer srv.Close()
+	args["host"] = srv.Addr
+	w, err = stmpInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, err = w.Write([]byte("error"))
+	a.NotError(err)
+	msgs := srv.Messages()
+	a.Equal(len(msgs), 1).Equal(msgs[0].From, "logs@example.com")
+}
+
+func TestLogContInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{"prefix": "[INFO]"}
+
+	w, err := logContInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.Container)
+	a.True(ok)
+
+	// 无效的 policy
+	args["policy"] = "ignore"
+	w, err = logContInitializer(args)
+	a.Error(err).Nil(w)
+	args["policy"] = "Continue"
+
+	// 无效的 parallel 和 timeout
+	args["parallel"] = "yes"
+	w, err = logContInitializer(args)
+	a.Error(err).Nil(w)
+	args["parallel"] = "true"
+	args["timeout"] = "1"
+	w, err = logContInitializer(args)
+	a.Error(err).Nil(w)
+
+	args["timeout"] = "1s"
+	w, err = logContInitializer(args)
+	a.NotError(err).NotNil(w)
+}
+
+func TestSyslogInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 本地服务，不需要任何参数
+	w, err := syslogInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.Syslog)
+	a.True(ok)
+
+	// 无效的 network
+	args["network"] = "http"
+	w, err = syslogInitializer(args)
+	a.Error(err).Nil(w)
+
+	// 缺少 addr
+	args["network"] = "udp"
+	w, err = syslogInitializer(args)
+	a.Error(err).Nil(w)
+	args["addr"] = "127.0.0.1:514"
+
+	// 无效的 format
+	args["format"] = "rfc1"
+	w, err = syslogInitializer(args)
+	a.Error(err).Nil(w)
+	args["format"] = "RFC5424"
+
+	// 无效的 facility
+	args["facility"] = "local8"
+	w, err = syslogInitializer(args)
+	a.Error(err).Nil(w)
+	args["facility"] = "local0"
+
+	// 无效的 tls
+	args["tls"] = "yes"
+	w, err = syslogInitializer(args)
+	a.Error(err).Nil(w)
+	args["tls"] = "true"
+	args["caFile"] = "./testdata/not-exists.pem"
+	w, err = syslogInitializer(args)
+	a.Error(err).Nil(w)
+	delete(args, "caFile")
+
+	args["timeout"] = "5s"
+	args["appName"] = "app"
+	w, err = syslogInitializer(args)
+	a.NotError(err).NotNil(w)
+}
+
+func TestJournaldInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	w, err := journaldInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.Journald)
+	a.True(ok)
+
+	// 无效的字段名
+	args["fields"] = "_PID=1"
+	w, err = journaldInitializer(args)
+	a.Error(err).Nil(w)
+
+	// 格式错误
+	args["fields"] = "APP"
+	w, err = journaldInitializer(args)
+	a.Error(err).Nil(w)
+
+	args["fields"] = "APP=logs; VERSION=1;"
+	args["identifier"] = "app"
+	w, err = journaldInitializer(args)
+	a.NotError(err).NotNil(w)
+}
+
+func TestHTTPInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 缺少 url
+	w, err := httpInitializer(args)
+	a.Error(err).Nil(w)
+	args["url"] = "http://localhost/logs"
+
+	args["format"] = "xml"
+	w, err = httpInitializer(args)
+	a.Error(err).Nil(w)
+	args["format"] = "NDJSON"
+
+	args["headers"] = "X-App"
+	w, err = httpInitializer(args)
+	a.Error(err).Nil(w)
+	args["headers"] = "X-App: logs; X-Env: dev"
+
+	args["queue"] = "0"
+	w, err = httpInitializer(args)
+	a.Error(err).Nil(w)
+	args["queue"] = "100"
+
+	args["interval"] = "1x"
+	w, err = httpInitializer(args)
+	a.Error(err).Nil(w)
+	args["interval"] = "500ms"
+
+	args["gzip"] = "true"
+	args["token"] = "token"
+	args["batch"] = "50"
+	w, err = httpInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.HTTP)
+	a.True(ok)
+}
+
+func TestRobotInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 缺少 platform
+	w, err := robotInitializer(args)
+	a.Error(err).Nil(w)
+
+	args["platform"] = "qq"
+	w, err = robotInitializer(args)
+	a.Error(err).Nil(w)
+	args["platform"] = "DingTalk"
+
+	// 缺少 webhook
+	w, err = robotInitializer(args)
+	a.Error(err).Nil(w)
+	args["webhook"] = "https://oapi.dingtalk.com/robot/send?access_token=token"
+
+	args["rateLimit"] = "20"
+	w, err = robotInitializer(args)
+	a.Error(err).Nil(w)
+	args["rateLimit"] = "20/1x"
+	w, err = robotInitializer(args)
+	a.Error(err).Nil(w)
+	args["rateLimit"] = "20/1m"
+
+	args["window"] = "10s"
+	args["secret"] = "secret"
+	args["mentions"] = "13800000000, all"
+	w, err = robotInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.Robot)
+	a.True(ok)
+}
+
+func TestNetInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 缺少 network
+	w, err := netInitializer(args)
+	a.Error(err).Nil(w)
+	args["network"] = "http"
+
+	// 缺少 addr
+	w, err = netInitializer(args)
+	a.Error(err).Nil(w)
+	args["addr"] = "127.0.0.1:5140"
+
+	// 无效的 network
+	w, err = netInitializer(args)
+	a.Error(err).Nil(w)
+	args["network"] = "UDP"
+
+	args["buffer"] = "1x"
+	w, err = netInitializer(args)
+	a.Error(err).Nil(w)
+	args["buffer"] = "64k"
+
+	args["maxBackoff"] = "1x"
+	w, err = netInitializer(args)
+	a.Error(err).Nil(w)
+	args["maxBackoff"] = "30s"
+
+	args["maxDatagram"] = "8k"
+	args["backoff"] = "1s"
+	args["timeout"] = "5s"
+	w, err = netInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.Net)
+	a.True(ok)
+}
+
+func TestGelfInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 缺少 addr
+	w, err := gelfInitializer(args)
+	a.Error(err).Nil(w)
+	args["addr"] = "127.0.0.1:12201"
+
+	args["fields"] = "id=1"
+	w, err = gelfInitializer(args)
+	a.Error(err).Nil(w)
+	args["fields"] = "app=logs;env=dev"
+
+	args["compress"] = "lz4"
+	w, err = gelfInitializer(args)
+	a.Error(err).Nil(w)
+	args["compress"] = "gzip"
+
+	args["chunkSize"] = "8"
+	w, err = gelfInitializer(args)
+	a.Error(err).Nil(w)
+	args["chunkSize"] = "8k"
+
+	// tcp 不支持压缩
+	args["network"] = "tcp"
+	w, err = gelfInitializer(args)
+	a.Error(err).Nil(w)
+	args["network"] = "udp"
+
+	args["host"] = "example.com"
+	w, err = gelfInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.Gelf)
+	a.True(ok)
+}
+
+func TestElasticsearchInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 缺少 url
+	w, err := elasticsearchInitializer(args)
+	a.Error(err).Nil(w)
+	args["url"] = "http://localhost:9200"
+
+	// 缺少 index
+	w, err = elasticsearchInitializer(args)
+	a.Error(err).Nil(w)
+	args["index"] = "app-%{2006.01.02}"
+
+	args["batch"] = "0"
+	w, err = elasticsearchInitializer(args)
+	a.Error(err).Nil(w)
+	args["batch"] = "500"
+
+	args["apiKey"] = "key"
+	args["timeout"] = "5s"
+	w, err = elasticsearchInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.Elasticsearch)
+	a.True(ok)
+}
+
+func TestLokiInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 缺少 url
+	w, err := lokiInitializer(args)
+	a.Error(err).Nil(w)
+	args["url"] = "http://localhost:3100"
+
+	args["format"] = "xml"
+	w, err = lokiInitializer(args)
+	a.Error(err).Nil(w)
+	args["format"] = "protobuf"
+
+	args["labels"] = "1app=logs"
+	w, err = lokiInitializer(args)
+	a.Error(err).Nil(w)
+	args["labels"] = "app=logs;host="
+
+	args["tenant"] = "team-a"
+	w, err = lokiInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.Loki)
+	a.True(ok)
+}
+
+func TestFluentInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 缺少 addr
+	w, err := fluentInitializer(args)
+	a.Error(err).Nil(w)
+	args["addr"] = "127.0.0.1:24224"
+
+	// 缺少 tag
+	w, err = fluentInitializer(args)
+	a.Error(err).Nil(w)
+	args["tag"] = "app.logs"
+
+	args["network"] = "udp"
+	w, err = fluentInitializer(args)
+	a.Error(err).Nil(w)
+	args["network"] = "tcp"
+
+	args["ack"] = "yes"
+	w, err = fluentInitializer(args)
+	a.Error(err).Nil(w)
+	args["ack"] = "true"
+
+	args["fields"] = "message=x"
+	w, err = fluentInitializer(args)
+	a.Error(err).Nil(w)
+	args["fields"] = "app=logs"
+
+	w, err = fluentInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.Fluent)
+	a.True(ok)
+}
+
+func TestOTLPInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 缺少 url
+	w, err := otlpInitializer(args)
+	a.Error(err).Nil(w)
+	args["url"] = "http://localhost:4318"
+
+	args["format"] = "xml"
+	w, err = otlpInitializer(args)
+	a.Error(err).Nil(w)
+	args["format"] = "json"
+
+	args["resource"] = "service.name"
+	w, err = otlpInitializer(args)
+	a.Error(err).Nil(w)
+	args["resource"] = "service.name=app;host.name="
+
+	args["attributes"] = "=dev"
+	w, err = otlpInitializer(args)
+	a.Error(err).Nil(w)
+	args["attributes"] = "env=dev"
+
+	args["headers"] = "Authorization: token"
+	args["gzip"] = "true"
+	w, err = otlpInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.OTLP)
+	a.True(ok)
+}
+
+func TestSplunkInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 缺少 url
+	w, err := splunkInitializer(args)
+	a.Error(err).Nil(w)
+	args["url"] = "https://localhost:8088"
+
+	// 缺少 token
+	w, err = splunkInitializer(args)
+	a.Error(err).Nil(w)
+	args["token"] = "token"
+
+	args["ack"] = "30"
+	w, err = splunkInitializer(args)
+	a.Error(err).Nil(w)
+	args["ack"] = "30s"
+
+	args["gzip"] = "yes"
+	w, err = splunkInitializer(args)
+	a.Error(err).Nil(w)
+	args["gzip"] = "true"
+
+	args["index"] = "main"
+	args["sourcetype"] = "_json"
+	w, err = splunkInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.Splunk)
+	a.True(ok)
+}
+
+func TestRedisInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 缺少 addr
+	w, err := redisInitializer(args)
+	a.Error(err).Nil(w)
+	args["addr"] = "127.0.0.1:6379"
+
+	// 缺少 key
+	w, err = redisInitializer(args)
+	a.Error(err).Nil(w)
+	args["key"] = "logs"
+
+	args["network"] = "udp"
+	w, err = redisInitializer(args)
+	a.Error(err).Nil(w)
+	args["network"] = "tcp"
+
+	args["mode"] = "set"
+	w, err = redisInitializer(args)
+	a.Error(err).Nil(w)
+	args["mode"] = "stream"
+
+	args["maxLen"] = "-1"
+	w, err = redisInitializer(args)
+	a.Error(err).Nil(w)
+	args["maxLen"] = "1000"
+
+	args["db"] = "x"
+	w, err = redisInitializer(args)
+	a.Error(err).Nil(w)
+	args["db"] = "1"
+
+	args["password"] = "pass"
+	args["tls"] = "true"
+	w, err = redisInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.Redis)
+	a.True(ok)
+}
+
+func TestDBInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 缺少 driver、dsn 和 table
+	w, err := dbInitializer(args)
+	a.Error(err).Nil(w)
+	args["driver"] = "not-exists"
+	w, err = dbInitializer(args)
+	a.Error(err).Nil(w)
+	args["dsn"] = "logs.db"
+	w, err = dbInitializer(args)
+	a.Error(err).Nil(w)
+	args["table"] = "logs"
+
+	// 未注册的驱动
+	w, err = dbInitializer(args)
+	a.Error(err).Nil(w)
+	args["driver"] = "logs-init"
+
+	args["placeholder"] = "percent"
+	w, err = dbInitializer(args)
+	a.Error(err).Nil(w)
+	args["placeholder"] = "dollar"
+
+	args["fields"] = "app"
+	w, err = dbInitializer(args)
+	a.Error(err).Nil(w)
+	args["fields"] = "app=logs"
+
+	args["keep"] = "720h"
+	args["every"] = "0s"
+	w, err = dbInitializer(args)
+	a.Error(err).Nil(w)
+	args["every"] = "1h"
+
+	args["autoCreate"] = "true"
+	w, err = dbInitializer(args)
+	a.NotError(err).NotNil(w)
+	d, ok := w.(*writers.DB)
+	a.True(ok)
+	a.NotError(d.Close())
+}
+
+func TestExecInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 缺少 command
+	w, err := execInitializer(args)
+	a.Error(err).Nil(w)
+
+	// 不存在的程序
+	args["command"] = "logs-not-exists-command"
+	w, err = execInitializer(args)
+	a.Error(err).Nil(w)
+	args["command"] = os.Args[0]
+	args["args"] = "-test.run=XXX;-test.v"
+
+	args["env"] = "LOGS"
+	w, err = execInitializer(args)
+	a.Error(err).Nil(w)
+	args["env"] = "LOGS=1;LOGS_LEVEL=info"
+
+	args["backoff"] = "1x"
+	w, err = execInitializer(args)
+	a.Error(err).Nil(w)
+	args["backoff"] = "1s"
+
+	args["buffer"] = "1P"
+	w, err = execInitializer(args)
+	a.Error(err).Nil(w)
+	args["buffer"] = "64k"
+
+	args["stderr"] = "true"
+	args["timeout"] = "5s"
+	w, err = execInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.Exec)
+	a.True(ok)
+}
+
+func TestFileInitializer(t *testing.T) {
+	a := assert.New(t)
+	args := map[string]string{}
+
+	// 缺少 path
+	w, err := fileInitializer(args)
+	a.Error(err).Nil(w)
+	args["path"] = "./testdata/file.log"
+
+	args["mode"] = "rw"
+	w, err = fileInitializer(args)
+	a.Error(err).Nil(w)
+	args["mode"] = "0640"
+
+	args["dirMode"] = "10000"
+	w, err = fileInitializer(args)
+	a.Error(err).Nil(w)
+	args["dirMode"] = "750"
+
+	args["uid"] = "root"
+	w, err = fileInitializer(args)
+	a.Error(err).Nil(w)
+	args["uid"] = "-2"
+	w, err = fileInitializer(args)
+	a.Error(err).Nil(w)
+	delete(args, "uid")
+
+	args["sync"] = "yes"
+	w, err = fileInitializer(args)
+	a.Error(err).Nil(w)
+	args["sync"] = "true"
+
+	args["noFollow"] = "true"
+	w, err = fileInitializer(args)
+	a.NotError(err).NotNil(w)
+	_, ok := w.(*writers.File)
+	a.True(ok)
+}
+
+// 仅用于测试 dbInitializer，sql.Open 并不会真正建立连接
+type initDriver struct{}
+
+func (initDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("not supported")
+}
+
+func init() {
+	sql.Register("logs-init", initDriver{})
 }
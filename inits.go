@@ -30,6 +30,12 @@ func toWriter(c *config.Config) (io.Writer, error) {
 		return nil, err
 	}
 
+	if l, ok := w.(writers.Leveler); ok {
+		if err = setLevel(l, c); err != nil {
+			return nil, err
+		}
+	}
+
 	if len(c.Items) == 0 { // 没有子项
 		return w, err
 	}
@@ -50,6 +56,26 @@ func toWriter(c *config.Config) (io.Writer, error) {
 	return w, nil
 }
 
+// 将 c 所在的日志级别信息传递给 l。
+// c 不在任何日志级别之下时，不作任何操作。
+func setLevel(l writers.Leveler, c *config.Config) error {
+	// 日志级别为顶级元素 logs 的直接子元素
+	for ; c != nil; c = c.Parent {
+		if c.Parent == nil || c.Parent.Parent != nil {
+			continue
+		}
+
+		flag, err := parseFlag(c.Attrs["flag"])
+		if err != nil {
+			return err
+		}
+		l.SetLevel(c.Name, c.Attrs["prefix"], flag)
+		return nil
+	}
+
+	return nil
+}
+
 // writer 的初始化函数。
 // args 参数为对应的 XML 节点的属性列表。
 type WriterInitializer func(args map[string]string) (io.Writer, error)
@@ -6,6 +6,7 @@ package logs
 
 import (
 	"io"
+	"log"
 	"testing"
 
 	"github.com/issue9/assert"
@@ -108,3 +109,56 @@ func TestInits(t *testing.T) {
 	clearInitializer()
 	a.Equal(0, len(funs))
 }
+
+// 实现 writers.Leveler 的 writer
+type levelTestWriter struct {
+	configTestWriter
+	level, prefix string
+	flag          int
+}
+
+func (w *levelTestWriter) SetLevel(level, prefix string, flag int) {
+	w.level = level
+	w.prefix = prefix
+	w.flag = flag
+}
+
+func TestToWriter_Leveler(t *testing.T) {
+	a := assert.New(t)
+	clearInitializer()
+
+	var lw *levelTestWriter
+	a.True(Register("logs", logsInit))
+	a.True(Register("leveler", func(args map[string]string) (io.Writer, error) {
+		lw = &levelTestWriter{}
+		return lw, nil
+	}))
+
+	cfg, err := config.ParseXMLString(`
+<?xml version="1.0" encoding="utf-8" ?>
+<logs>
+	<error prefix="[ERROR]" flag="log.lstdflags">
+		<logs>
+			<leveler />
+		</logs>
+	</error>
+</logs>
+`)
+	a.NotError(err).NotNil(cfg)
+
+	w, err := toWriter(cfg.Items["error"].Items["logs"])
+	a.NotError(err).NotNil(w)
+	a.Equal(lw.level, "error").
+		Equal(lw.prefix, "[ERROR]").
+		Equal(lw.flag, log.LstdFlags)
+
+	// 不在日志级别之下
+	w, err = toWriter(&config.Config{Name: "leveler"})
+	a.NotError(err).NotNil(w)
+	a.Equal(lw.level, "")
+
+	// 无效的 flag
+	cfg.Items["error"].Attrs["flag"] = "log.lxx"
+	w, err = toWriter(cfg.Items["error"].Items["logs"])
+	a.Error(err).Nil(w)
+}
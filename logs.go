@@ -6,9 +6,11 @@ package logs
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/issue9/logs/internal/config"
 	"github.com/issue9/logs/writers"
@@ -23,8 +25,14 @@ var (
 	info, warn, erro, debug, trace, critical *log.Logger
 )
 
+// 各个日志级别对应的容器，供 Attach 和 Detach 使用。
+var (
+	levels   = map[string]*writers.Container{}
+	levelsMu sync.Mutex
+)
+
 // 从一个 XML 文件中初始化日志系统。
-// 再次调用该函数，将会根据新的配置文件重新初始化日志系统。
+// 再次调用该函数，将会关闭之前的 writer，并根据新的配置文件重新初始化日志系统。
 func InitFromXMLFile(path string) error {
 	cfg, err := config.ParseXMLFile(path)
 	if err != nil {
@@ -34,7 +42,7 @@ func InitFromXMLFile(path string) error {
 }
 
 // 从一个 XML 字符串初始化日志系统。
-// 再次调用该函数，将会根据新的配置文件重新初始化日志系统。
+// 再次调用该函数，将会关闭之前的 writer，并根据新的配置文件重新初始化日志系统。
 func InitFromXMLString(xml string) error {
 	cfg, err := config.ParseXMLString(xml)
 	if err != nil {
@@ -45,10 +53,15 @@ func InitFromXMLString(xml string) error {
 
 // 从 config.Config 中初始化整个 logs 系统
 func initFromConfig(cfg *config.Config) error {
-	if conts.Len() > 0 { // 加载新配置文件。先输出旧的内容。
+	if conts.Len() > 0 { // 加载新配置文件。先输出旧的内容，再关闭旧的 writer。
 		Flush()
+		conts.Close()
 		conts.Clear()
 
+		levelsMu.Lock()
+		levels = map[string]*writers.Container{}
+		levelsMu.Unlock()
+
 		// 重置为空值
 		info = nil
 		critical = nil
@@ -59,13 +72,9 @@ func initFromConfig(cfg *config.Config) error {
 	}
 
 	for name, c := range cfg.Items {
-		flag := 0
-		flagStr, found := c.Attrs["flag"]
-		if found && (flagStr != "") {
-			flag, found = flagMap[strings.ToLower(flagStr)]
-			if !found {
-				return fmt.Errorf("未知的Flag参数:[%v]", flagStr)
-			}
+		flag, err := parseFlag(c.Attrs["flag"])
+		if err != nil {
+			return err
 		}
 
 		cont, err := toWriter(c)
@@ -89,11 +98,62 @@ func initFromConfig(cfg *config.Config) error {
 			critical = l
 		}
 		conts.Add(cont)
+
+		if c, ok := cont.(*writers.Container); ok {
+			levelsMu.Lock()
+			levels[name] = c
+			levelsMu.Unlock()
+		}
 	}
 
 	return nil
 }
 
+// 在运行时向指定的日志级别添加一个 io.Writer，之后可以通过 Detach 删除。
+//
+// level 为日志级别的名称，如 info、error 等，该级别必须已经在配置文件中配置；
+// name 为 w 的名称，同一日志级别中不能重复，不同的日志级别可以使用相同的名称。
+// 可以在输出日志的同时调用，但重新加载配置文件之后，所有添加的 io.Writer 都会被清除。
+func Attach(level, name string, w io.Writer) error {
+	levelsMu.Lock()
+	c, found := levels[level]
+	levelsMu.Unlock()
+
+	if !found {
+		return fmt.Errorf("未配置的日志级别:[%v]", level)
+	}
+
+	return c.AddNamed(name, w)
+}
+
+// 从所有日志级别中删除名称为 name 的 io.Writer，
+// 返回值表示是否有被删除的内容。
+func Detach(name string) bool {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+
+	found := false
+	for _, c := range levels {
+		if c.Remove(name) {
+			found = true
+		}
+	}
+	return found
+}
+
+// 将 flag 属性的值转换成 log.Logger 的 flag 参数，空值表示 0。
+func parseFlag(flagStr string) (int, error) {
+	if flagStr == "" {
+		return 0, nil
+	}
+
+	flag, found := flagMap[strings.ToLower(flagStr)]
+	if !found {
+		return 0, fmt.Errorf("未知的Flag参数:[%v]", flagStr)
+	}
+	return flag, nil
+}
+
 // 输出所有的缓存内容。
 // 若是通过 os.Exit() 退出程序的，在执行之前，
 // 一定记得调用 Flush() 输出可能缓存的日志内容。
@@ -101,6 +161,14 @@ func Flush() {
 	conts.Flush()
 }
 
+// 输出所有的缓存内容，并关闭所有实现了 io.Closer 的 writer。
+// 比如 rotate 会关闭当前的文件，并等待其上传到 S3 完成。
+// 一般在程序退出之前调用，关闭之后部分 writer 将无法再使用。
+func Close() error {
+	conts.Flush()
+	return conts.Close()
+}
+
 // 获取 INFO 级别的 log.Logger 实例，在未指定 info 级别的日志时，该实例返回一个 nil。
 func INFO() *log.Logger {
 	return info
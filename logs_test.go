@@ -6,8 +6,16 @@ package logs
 
 import (
 	"bytes"
+	"crypto/md5"
+	"encoding/hex"
 	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/issue9/assert"
@@ -109,3 +117,140 @@ func TestInitFormXMLString(t *testing.T) {
 	Flush()
 	a.True(debugW.Len() > 0)
 }
+
+// 记录 Close 调用次数的 writer
+type closeWriter struct {
+	bytes.Buffer
+	closed int
+}
+
+func (w *closeWriter) Close() error {
+	w.closed++
+	return nil
+}
+
+// 重新加载配置时会关闭旧的 writer
+func TestInitFromXMLString_reload(t *testing.T) {
+	a := assert.New(t)
+
+	ws := []*closeWriter{}
+	clearInitializer()
+	a.True(Register("debug", logContInitializer), "注册debug时失败")
+	a.True(Register("closeW", func(args map[string]string) (io.Writer, error) {
+		w := &closeWriter{}
+		ws = append(ws, w)
+		return w, nil
+	}), "注册closeW时失败")
+
+	xml := `
+<?xml version="1.0" encoding="utf-8" ?>
+<logs>
+	<debug prefix="[DEBUG]">
+		<closeW />
+	</debug>
+</logs>
+`
+	a.NotError(InitFromXMLString(xml))
+	a.Equal(len(ws), 1).Equal(ws[0].closed, 0)
+
+	Debug("abc")
+	a.NotError(InitFromXMLString(xml))
+	a.Equal(len(ws), 2)
+	a.Equal(ws[0].closed, 1).Equal(ws[1].closed, 0)
+	a.True(strings.Contains(ws[0].String(), "abc"))
+}
+
+// Close 时 rotate 正在写的文件也会上传到 S3
+func TestClose(t *testing.T) {
+	a := assert.New(t)
+
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sum := md5.Sum(body)
+
+		mu.Lock()
+		objects[r.URL.Path] = body
+		mu.Unlock()
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "logs-close")
+	a.NotError(err)
+	defer os.RemoveAll(dir)
+
+	clearInitializer()
+	a.True(Register("debug", logContInitializer), "注册debug时失败")
+	a.True(Register("rotate", rotateInitializer), "注册rotate时失败")
+
+	xml := `
+<?xml version="1.0" encoding="utf-8" ?>
+<logs>
+	<debug prefix="[DEBUG]">
+		<rotate dir="` + dir + `" size="1M" s3Endpoint="` + srv.URL + `" s3Bucket="logs"
+			s3AccessKey="ak" s3SecretKey="sk" s3Key="{name}" />
+	</debug>
+</logs>
+`
+	a.NotError(InitFromXMLString(xml))
+
+	Debug("abc")
+	a.NotError(Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	a.Equal(len(objects), 1)
+	for key, body := range objects {
+		a.True(strings.HasPrefix(key, "/logs/"))
+		a.True(strings.Contains(string(body), "[DEBUG]abc"))
+	}
+}
+
+func TestAttachDetach(t *testing.T) {
+	a := assert.New(t)
+
+	clearInitializer()
+	a.True(Register("debug", logContInitializer), "注册debug时失败")
+	a.True(Register("info", logContInitializer), "注册info时失败")
+	a.True(Register("debugW", debugWInit), "注册debugW时失败")
+
+	xml := `
+<?xml version="1.0" encoding="utf-8" ?>
+<logs>
+	<debug prefix="[DEBUG]">
+		<debugW />
+	</debug>
+	<info prefix="[INFO]">
+		<debugW />
+	</info>
+</logs>
+`
+	a.NotError(InitFromXMLString(xml))
+
+	w := new(bytes.Buffer)
+	a.Error(Attach("error", "session", w)) // 未配置的级别
+	a.NotError(Attach("debug", "session", w))
+	a.NotError(Attach("info", "session", w))
+	a.Error(Attach("info", "session", w)) // 重名
+
+	Debug("abc")
+	Info("def")
+	a.True(strings.Contains(w.String(), "abc")).
+		True(strings.Contains(w.String(), "def"))
+
+	a.True(Detach("session"))
+	a.False(Detach("session"))
+
+	w.Reset()
+	Debug("abc")
+	a.Equal(w.Len(), 0)
+
+	// 重新加载配置文件之后，添加的内容被清除
+	a.NotError(Attach("debug", "session", w))
+	a.NotError(InitFromXMLString(xml))
+	Debug("abc")
+	a.Equal(w.Len(), 0)
+	a.False(Detach("session"))
+}
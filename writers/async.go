@@ -0,0 +1,410 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 每次重试之前最长的等待时间，包括服务端通过 Retry-After 指定的时间。
+var maxRetryWait = time.Minute
+
+// 异步发送队列。
+//
+// 由后台的 goroutine 负责调用 send 发送内容，发送失败时按 backoff
+// 的倍数递增等待时间进行重试，每次最多等待 maxRetryWait。
+// 队列已满、重试次数用完或是关闭时依然未能发送的内容都将被丢弃，
+// 丢弃的数量可以通过 dropped() 获取。
+//
+// 指定了 batch 之后，会将多条内容合并之后一起发送，
+// 达到 batch 条或是距第一条内容超过 interval 时发送。
+type async struct {
+	queue    chan []byte
+	send     func([][]byte) error
+	batch    int
+	interval time.Duration
+	retries  int
+	backoff  time.Duration
+	flushNow chan struct{} // 通知后台 goroutine 立即发送当前的批次
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending int    // 队列中以及正在发送的数量
+	dropCnt uint64 // 被丢弃的数量
+	closed  bool
+	stop    chan struct{} // 关闭时通知后台 goroutine 不再重试
+	done    chan struct{}
+}
+
+// 声明一个 async 实例并启动后台的发送 goroutine。
+// size 为队列的大小；retries 为发送失败之后的重试次数；
+// backoff 为第一次重试之前的等待时间，之后每次翻倍。
+func newAsync(size, retries int, backoff time.Duration, send func([]byte) error) *async {
+	return newBatchAsync(size, 1, 0, retries, backoff, func(items [][]byte) error {
+		return send(items[0])
+	})
+}
+
+// 声明一个批量发送的 async 实例。
+// batch 为每一批的最大数量；interval 为每一批最长的等待时间。
+func newBatchAsync(size, batch int, interval time.Duration, retries int, backoff time.Duration, send func([][]byte) error) *async {
+	if batch < 1 {
+		batch = 1
+	}
+
+	a := &async{
+		queue:    make(chan []byte, size),
+		send:     send,
+		batch:    batch,
+		interval: interval,
+		retries:  retries,
+		backoff:  backoff,
+		flushNow: make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	a.cond = sync.NewCond(&a.mu)
+
+	go a.serve()
+
+	return a
+}
+
+// 将 data 放入队列，不会阻塞。队列已满时丢弃并返回 false。
+// data 在放入队列之后不能再被修改。
+func (a *async) push(data []byte) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		a.dropCnt++
+		return false
+	}
+
+	select {
+	case a.queue <- data:
+		a.pending++
+		return true
+	default:
+		a.dropCnt++
+		return false
+	}
+}
+
+func (a *async) serve() {
+	for data := range a.queue {
+		items := a.collect([][]byte{data})
+		dropped := a.deliver(items)
+
+		a.mu.Lock()
+		a.dropCnt += uint64(dropped)
+		a.pending -= len(items)
+		a.cond.Broadcast()
+		a.mu.Unlock()
+	}
+
+	close(a.done)
+}
+
+// 从队列中继续读取内容，直到达到 batch 条、超过 interval 或是需要立即发送。
+func (a *async) collect(items [][]byte) [][]byte {
+	if a.batch <= 1 {
+		return items
+	}
+
+	// 未指定 interval，只读取队列中已有的内容
+	if a.interval <= 0 {
+		return a.drain(items)
+	}
+
+	timer := time.NewTimer(a.interval)
+	defer timer.Stop()
+
+	for len(items) < a.batch {
+		select {
+		case data, ok := <-a.queue:
+			if !ok {
+				return items
+			}
+			items = append(items, data)
+		case <-a.flushNow:
+			return a.drain(items)
+		case <-timer.C:
+			return items
+		}
+	}
+
+	return items
+}
+
+// 读取队列中已有的内容，直到达到 batch 条。
+func (a *async) drain(items [][]byte) [][]byte {
+	for len(items) < a.batch {
+		select {
+		case data, ok := <-a.queue:
+			if !ok {
+				return items
+			}
+			items = append(items, data)
+		default:
+			return items
+		}
+	}
+	return items
+}
+
+// 发送 items，返回最终未能发送的数量。
+func (a *async) deliver(items [][]byte) int {
+	wait := a.backoff
+	for i := 0; ; i++ {
+		err := a.send(items)
+		if err == nil {
+			return 0
+		}
+
+		// 只需要重试其中的部分内容
+		var pe *partialError
+		if errors.As(err, &pe) {
+			items = pe.items
+		}
+
+		if i >= a.retries || errors.As(err, new(*noRetryError)) {
+			return len(items)
+		}
+
+		// 服务端指定了等待时间，优先使用该值
+		d := wait
+		var ra *retryAfterError
+		if errors.As(err, &ra) && ra.wait > 0 {
+			d = ra.wait
+		}
+		if d > maxRetryWait {
+			d = maxRetryWait
+		}
+
+		if !a.sleep(d) { // 已经关闭，不再重试
+			return len(items)
+		}
+		wait *= 2
+	}
+}
+
+// 等待 d，期间被关闭时返回 false。
+func (a *async) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-a.stop:
+		return false
+	}
+}
+
+// 等待队列中的内容全部发送完成。
+func (a *async) flush() {
+	a.mu.Lock()
+	for a.pending > 0 {
+		// 每发送完一批都需要重新通知，防止剩余的内容还在等待 interval
+		select {
+		case a.flushNow <- struct{}{}:
+		default:
+		}
+		a.cond.Wait()
+	}
+	a.mu.Unlock()
+}
+
+// 发送完队列中的内容之后，关闭后台 goroutine。
+// 发送失败的内容不再重试，关闭之后再调用 push 的内容都将被丢弃。
+func (a *async) close() {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return
+	}
+	a.closed = true
+	close(a.queue)
+	close(a.stop)
+	a.mu.Unlock()
+
+	<-a.done
+}
+
+// 被丢弃的数量
+func (a *async) dropped() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.dropCnt
+}
+
+// 批量发送日志的 writer 的公共部分，由 HTTP、Elasticsearch 等嵌入使用。
+//
+// 包含队列的配置以及 Dropped、Flush 和 Close 等方法，
+// 嵌入者只需要提供 send，并在 Write 中调用 push 即可。
+type batchWriter struct {
+	size     int
+	batch    int
+	interval time.Duration
+	retries  int
+	backoff  time.Duration
+
+	level  string
+	prefix string
+	flag   int
+
+	send    func([][]byte) error
+	mu      sync.Mutex
+	async   *async
+	dropCnt uint64 // 已经关闭的 async 丢弃的数量
+}
+
+// 声明 batchWriter 实例，队列的配置均为默认值。
+func newBatchWriter(send func([][]byte) error) batchWriter {
+	return batchWriter{
+		size:     defaultHTTPQueue,
+		batch:    defaultHTTPBatch,
+		interval: defaultHTTPInterval,
+		retries:  defaultHTTPRetries,
+		backoff:  defaultHTTPBackoff,
+		send:     send,
+	}
+}
+
+// 设置队列的大小以及发送失败之后的重试次数。
+// 第一次重试之前等待 backoff，之后每次翻倍。
+// 只能在第一次调用 Write 之前设置。
+func (b *batchWriter) SetQueue(size, retries int, backoff time.Duration) error {
+	if size < 1 {
+		return fmt.Errorf("无效的size值:[%v]", size)
+	}
+
+	b.size = size
+	b.retries = retries
+	b.backoff = backoff
+	return nil
+}
+
+// 设置每一批次的最大数量以及等待时间。
+// 只能在第一次调用 Write 之前设置。
+func (b *batchWriter) SetBatch(size int, interval time.Duration) error {
+	if size < 1 {
+		return fmt.Errorf("无效的size值:[%v]", size)
+	}
+
+	b.batch = size
+	b.interval = interval
+	return nil
+}
+
+// Leveler.SetLevel()
+func (b *batchWriter) SetLevel(level, prefix string, flag int) {
+	b.level = level
+	b.prefix = prefix
+	b.flag = flag
+}
+
+// 将 data 放入队列，第一次调用时启动后台的 goroutine。
+// 队列已满时直接丢弃，由 Dropped() 记录丢弃的数量。
+func (b *batchWriter) push(data []byte) {
+	b.mu.Lock()
+	if b.async == nil {
+		b.async = newBatchAsync(b.size, b.batch, b.interval, b.retries, b.backoff, b.send)
+	}
+	a := b.async
+	b.mu.Unlock()
+
+	a.push(data)
+}
+
+// 被丢弃的日志数量，包括队列已满和重试之后依然发送失败的日志。
+func (b *batchWriter) Dropped() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cnt := b.dropCnt
+	if b.async != nil {
+		cnt += b.async.dropped()
+	}
+	return cnt
+}
+
+// Flusher.Flush()
+// 等待队列中的日志全部发送完成。
+func (b *batchWriter) Flush() (int, error) {
+	b.mu.Lock()
+	a := b.async
+	b.mu.Unlock()
+
+	if a != nil {
+		a.flush()
+	}
+	return 0, nil
+}
+
+// io.Closer.Close()
+// 发送完队列中的日志之后关闭后台 goroutine，之后可以通过 Write 重新启动。
+func (b *batchWriter) Close() error {
+	b.mu.Lock()
+	a := b.async
+	b.async = nil
+	b.mu.Unlock()
+
+	if a == nil {
+		return nil
+	}
+	a.close()
+
+	b.mu.Lock()
+	b.dropCnt += a.dropped()
+	b.mu.Unlock()
+	return nil
+}
+
+// 表示不需要重试的错误
+type noRetryError struct {
+	err error
+}
+
+func (e *noRetryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *noRetryError) Unwrap() error {
+	return e.err
+}
+
+// 表示只需要重试其中部分内容的错误，比如批量接口中返回 429 的部分。
+type partialError struct {
+	err   error
+	items [][]byte
+}
+
+func (e *partialError) Error() string {
+	return e.err.Error()
+}
+
+func (e *partialError) Unwrap() error {
+	return e.err
+}
+
+// 表示需要等待指定的时间之后再重试的错误，比如服务端返回的 Retry-After 报头。
+type retryAfterError struct {
+	err  error
+	wait time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
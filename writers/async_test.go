@@ -0,0 +1,209 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+func TestAsync(t *testing.T) {
+	a := assert.New(t)
+
+	var mu sync.Mutex
+	sent := [][]byte{}
+	fails := 2 // 前两次发送失败
+	block := make(chan struct{})
+
+	as := newAsync(2, 2, time.Millisecond, func(data []byte) error {
+		<-block
+
+		mu.Lock()
+		defer mu.Unlock()
+		if fails > 0 {
+			fails--
+			return errors.New("fail")
+		}
+		sent = append(sent, data)
+		return nil
+	})
+
+	// 第一条被后台 goroutine 取走之后阻塞在 send 中，
+	// 队列中还能容纳 2 条，第 4 条被丢弃。
+	a.True(as.push([]byte("1")))
+	time.Sleep(10 * time.Millisecond)
+	a.True(as.push([]byte("2")))
+	a.True(as.push([]byte("3")))
+	a.False(as.push([]byte("4")))
+	a.Equal(as.dropped(), 1)
+
+	close(block)
+	as.flush()
+	a.Equal(sent, [][]byte{[]byte("1"), []byte("2"), []byte("3")})
+
+	// 重试次数用完
+	mu.Lock()
+	fails = 3
+	mu.Unlock()
+	a.True(as.push([]byte("5")))
+	as.flush()
+	a.Equal(as.dropped(), 2)
+
+	as.close()
+	as.close()
+	a.False(as.push([]byte("6")))
+	a.Equal(as.dropped(), 3)
+}
+
+func TestAsync_batch(t *testing.T) {
+	a := assert.New(t)
+
+	var mu sync.Mutex
+	batches := [][][]byte{}
+	as := newBatchAsync(10, 3, time.Hour, 0, 0, func(items [][]byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, items)
+		return nil
+	})
+
+	for _, s := range []string{"1", "2", "3", "4"} {
+		a.True(as.push([]byte(s)))
+	}
+	as.flush() // 不需要等待 interval
+	a.Equal(len(batches), 2).
+		Equal(batches[0], [][]byte{[]byte("1"), []byte("2"), []byte("3")}).
+		Equal(batches[1], [][]byte{[]byte("4")})
+
+	as.close()
+}
+
+func TestAsync_noRetry(t *testing.T) {
+	a := assert.New(t)
+
+	count := 0
+	as := newAsync(10, 3, time.Millisecond, func(data []byte) error {
+		count++
+		return &noRetryError{err: errors.New("fail")}
+	})
+
+	a.True(as.push([]byte("1")))
+	as.flush()
+	a.Equal(count, 1).Equal(as.dropped(), 1)
+	as.close()
+}
+
+func TestAsync_retryAfter(t *testing.T) {
+	a := assert.New(t)
+
+	sent := []time.Time{}
+	as := newAsync(10, 1, time.Hour, func(data []byte) error {
+		sent = append(sent, time.Now())
+		if len(sent) == 1 {
+			return &retryAfterError{err: errors.New("fail"), wait: 50 * time.Millisecond}
+		}
+		return nil
+	})
+
+	// 使用 wait 代替 backoff
+	a.True(as.push([]byte("1")))
+	as.flush()
+	a.Equal(len(sent), 2).Equal(as.dropped(), 0)
+	d := sent[1].Sub(sent[0])
+	a.True(d >= 50*time.Millisecond && d < time.Hour)
+	as.close()
+}
+
+func TestAsync_partial(t *testing.T) {
+	a := assert.New(t)
+
+	sent := [][][]byte{}
+	as := newBatchAsync(10, 10, time.Hour, 1, time.Millisecond, func(items [][]byte) error {
+		sent = append(sent, items)
+		return &partialError{err: errors.New("fail"), items: items[1:]}
+	})
+
+	// 只重试失败的部分，重试次数用完之后只丢弃这部分
+	a.True(as.push([]byte("1")))
+	a.True(as.push([]byte("2")))
+	a.True(as.push([]byte("3")))
+	as.flush()
+	a.Equal(as.dropped(), 1)
+	a.Equal(len(sent), 2).
+		Equal(sent[1], [][]byte{[]byte("2"), []byte("3")})
+	as.close()
+}
+
+func TestAsync_maxRetryWait(t *testing.T) {
+	a := assert.New(t)
+
+	old := maxRetryWait
+	maxRetryWait = 10 * time.Millisecond
+	defer func() { maxRetryWait = old }()
+
+	count := 0
+	as := newAsync(10, 2, time.Hour, func(data []byte) error {
+		count++
+		if count == 1 {
+			return &retryAfterError{err: errors.New("fail"), wait: time.Hour}
+		}
+		if count == 2 {
+			return errors.New("fail")
+		}
+		return nil
+	})
+
+	// Retry-After 和 backoff 都被限制在 maxRetryWait 之内
+	start := time.Now()
+	a.True(as.push([]byte("1")))
+	as.flush()
+	a.Equal(count, 3).Equal(as.dropped(), 0)
+	a.True(time.Since(start) < time.Second)
+	as.close()
+}
+
+func TestBatchWriter(t *testing.T) {
+	a := assert.New(t)
+
+	var mu sync.Mutex
+	sent := [][]byte{}
+	b := newBatchWriter(func(items [][]byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if string(items[0]) == "fail" {
+			return &noRetryError{err: errors.New("fail")}
+		}
+		sent = append(sent, items...)
+		return nil
+	})
+	a.Error(b.SetQueue(0, 0, 0))
+	a.Error(b.SetBatch(0, 0))
+	a.NotError(b.SetBatch(10, 0))
+	a.Equal(b.Dropped(), 0)
+
+	b.push([]byte("1"))
+	b.Flush()
+	b.push([]byte("fail"))
+	b.Flush()
+	a.NotNil(b.async)
+
+	// 关闭之后依然保留丢弃的数量，再次 push 会重新启动
+	a.NotError(b.Close())
+	a.Nil(b.async)
+	a.Equal(b.Dropped(), 1)
+
+	b.push([]byte("2"))
+	a.NotError(b.Close())
+	a.Equal(b.Dropped(), 1)
+	a.NotError(b.Close())
+
+	mu.Lock()
+	a.Equal(sent, [][]byte{[]byte("1"), []byte("2")})
+	mu.Unlock()
+}
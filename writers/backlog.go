@@ -0,0 +1,79 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import "time"
+
+// 目标不可用期间积压的内容，以及重试的等待时间，由 Net 和 Exec 共用。
+//
+// 所有方法都需要在调用方的锁中调用。
+type backlog struct {
+	items      [][]byte
+	size       int // items 的总字节数
+	bufSize    int // 最多缓存的字节数
+	backoff    time.Duration
+	maxBackoff time.Duration
+	failures   int       // 连续失败的次数
+	next       time.Time // 下一次重试的时间
+	dropCnt    uint64    // 被丢弃以及只写入了一部分的内容数量
+}
+
+// 缓存 msg 的副本。
+func (b *backlog) push(msg []byte) {
+	data := make([]byte, len(msg))
+	copy(data, msg)
+	b.items = append(b.items, data)
+	b.size += len(data)
+}
+
+// 是否已经过了重试的等待时间
+func (b *backlog) ready() bool {
+	return !time.Now().Before(b.next)
+}
+
+// 缓存超出大小时丢弃最早的内容。
+func (b *backlog) trim() {
+	for b.size > b.bufSize && len(b.items) > 0 {
+		b.shift()
+		b.dropCnt++
+	}
+}
+
+// 依次调用 write 写入缓存的内容，write 返回已经写入的字节数。
+//
+// 写入失败时，已经写入了一部分的内容无论是重写整条还是只写剩余部分，
+// 对方收到的都是不完整的内容，所以直接丢弃；未写入的内容则继续保留。
+func (b *backlog) flush(write func([]byte) (int, error)) error {
+	for len(b.items) > 0 {
+		written, err := write(b.items[0])
+		if err != nil {
+			if written > 0 {
+				b.shift()
+				b.dropCnt++
+			}
+			return err
+		}
+		b.shift()
+	}
+
+	b.items = nil
+	return nil
+}
+
+// 连接或是写入失败之后，计算下一次重试的时间。
+func (b *backlog) fail() {
+	wait := b.backoff << uint(b.failures)
+	if wait > b.maxBackoff || wait <= 0 {
+		wait = b.maxBackoff
+	}
+	b.failures++
+	b.next = time.Now().Add(wait)
+}
+
+func (b *backlog) shift() {
+	b.size -= len(b.items[0])
+	b.items[0] = nil
+	b.items = b.items[1:]
+}
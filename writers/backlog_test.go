@@ -0,0 +1,57 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+func TestBacklog(t *testing.T) {
+	a := assert.New(t)
+	b := &backlog{bufSize: 5, backoff: time.Second, maxBackoff: 3 * time.Second}
+
+	msg := []byte("12\n")
+	b.push(msg)
+	msg[0] = '0' // 缓存的是副本
+	b.push([]byte("34\n"))
+	b.trim()
+	a.Equal(b.items, [][]byte{[]byte("34\n")}).
+		Equal(b.size, 3).
+		Equal(b.dropCnt, 1)
+
+	// 只写入一部分的内容被丢弃，未写入的继续保留
+	b.push([]byte("5\n"))
+	b.push([]byte("6\n"))
+	written := 0
+	err := b.flush(func(data []byte) (int, error) {
+		written++
+		switch written {
+		case 1:
+			return len(data), nil
+		case 2:
+			return 1, errors.New("broken pipe")
+		}
+		return 0, errors.New("broken pipe")
+	})
+	a.Error(err)
+	a.Equal(b.items, [][]byte{[]byte("6\n")}).
+		Equal(b.size, 2).
+		Equal(b.dropCnt, 2)
+
+	a.NotError(b.flush(func(data []byte) (int, error) { return len(data), nil }))
+	a.Empty(b.items).Equal(b.size, 0)
+
+	// 等待时间按倍数递增，但不超过 maxBackoff
+	a.True(b.ready())
+	b.fail()
+	a.False(b.ready()).True(b.next.Before(time.Now().Add(time.Second + time.Millisecond)))
+	b.fail()
+	b.fail()
+	a.Equal(b.failures, 3).True(b.next.After(time.Now().Add(2 * time.Second)))
+}
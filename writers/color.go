@@ -0,0 +1,168 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/issue9/term/colors"
+)
+
+// 颜色的类型
+const (
+	colorBasic = iota // colors 包中预定义的颜色
+	color256          // 256 色
+	colorRGB          // 真彩色
+)
+
+// 控制台中的颜色。
+//
+// 除了 github.com/issue9/term/colors 中预定义的颜色之外，
+// 还支持 256 色和真彩色，后两者通过 ANSI 控制码输出，需要终端的支持。
+type Color struct {
+	kind  int
+	value uint32
+}
+
+var basicColors = map[string]colors.Color{
+	"default": colors.Default,
+	"black":   colors.Black,
+	"red":     colors.Red,
+	"green":   colors.Green,
+	"yellow":  colors.Yellow,
+	"blue":    colors.Blue,
+	"magenta": colors.Magenta,
+	"cyan":    colors.Cyan,
+	"white":   colors.White,
+}
+
+// colors.Color 对应的 ANSI 前景色代码，背景色在此基础上加 10。
+var basicColorCodes = map[colors.Color]int{
+	colors.Default: 39,
+	colors.Black:   30,
+	colors.Red:     31,
+	colors.Green:   32,
+	colors.Yellow:  33,
+	colors.Blue:    34,
+	colors.Magenta: 35,
+	colors.Cyan:    36,
+	colors.White:   37,
+}
+
+// 将 colors 包中预定义的颜色转换成 Color
+func BasicColor(c colors.Color) Color {
+	return Color{kind: colorBasic, value: uint32(c)}
+}
+
+// 256 色中的颜色
+func Color256(index uint8) Color {
+	return Color{kind: color256, value: uint32(index)}
+}
+
+// 真彩色
+func RGB(r, g, b uint8) Color {
+	return Color{kind: colorRGB, value: uint32(r)<<16 | uint32(g)<<8 | uint32(b)}
+}
+
+// 从字符串中解析颜色，不区分大小写。可以是以下格式：
+//  red     colors 包中预定义的颜色名称，如 default、red、blue 等；
+//  208     0-255 的数值，表示 256 色中的颜色；
+//  #ff8800 表示真彩色。
+func ParseColor(str string) (Color, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+
+	if c, found := basicColors[str]; found {
+		return BasicColor(c), nil
+	}
+
+	if strings.HasPrefix(str, "#") && len(str) == 7 {
+		v, err := strconv.ParseUint(str[1:], 16, 32)
+		if err != nil {
+			return Color{}, fmt.Errorf("无效的颜色值:[%v]", str)
+		}
+		return RGB(uint8(v>>16), uint8(v>>8), uint8(v)), nil
+	}
+
+	v, err := strconv.ParseUint(str, 10, 8)
+	if err != nil {
+		return Color{}, fmt.Errorf("无效的颜色值:[%v]", str)
+	}
+	return Color256(uint8(v)), nil
+}
+
+// 是否为 colors 包中预定义的颜色
+func (c Color) isBasic() bool {
+	return c.kind == colorBasic
+}
+
+// 转换成 ANSI 的 SGR 参数，background 表示是否为背景色。
+func (c Color) sgr(background bool) string {
+	switch c.kind {
+	case color256:
+		if background {
+			return "48;5;" + strconv.Itoa(int(c.value))
+		}
+		return "38;5;" + strconv.Itoa(int(c.value))
+	case colorRGB:
+		rgb := fmt.Sprintf("%d;%d;%d", uint8(c.value>>16), uint8(c.value>>8), uint8(c.value))
+		if background {
+			return "48;2;" + rgb
+		}
+		return "38;2;" + rgb
+	default:
+		code, found := basicColorCodes[colors.Color(c.value)]
+		if !found {
+			code = 39
+		}
+		if background {
+			code += 10
+		}
+		return strconv.Itoa(code)
+	}
+}
+
+// 前景色和背景色的组合
+type ConsoleStyle struct {
+	Foreground Color
+	Background Color
+}
+
+// 从字符串中解析 ConsoleStyle，格式为 foreground[/background]，
+// 比如 red、white/red 和 208/#000000 等，省略背景色时使用默认背景色。
+func ParseConsoleStyle(str string) (ConsoleStyle, error) {
+	style := ConsoleStyle{Background: BasicColor(colors.Default)}
+
+	fg, bg := str, ""
+	if index := strings.IndexByte(str, '/'); index >= 0 {
+		fg, bg = str[:index], str[index+1:]
+	}
+
+	var err error
+	if style.Foreground, err = ParseColor(fg); err != nil {
+		return style, err
+	}
+
+	if bg != "" {
+		if style.Background, err = ParseColor(bg); err != nil {
+			return style, err
+		}
+	}
+
+	return style, nil
+}
+
+// ANSI 控制码的开始部分
+func (s ConsoleStyle) ansi() string {
+	return "\033[" + s.Foreground.sgr(false) + ";" + s.Background.sgr(true) + "m"
+}
+
+// 是否能用 colors 包输出
+func (s ConsoleStyle) isBasic() bool {
+	return s.Foreground.isBasic() && s.Background.isBasic()
+}
+
+const ansiReset = "\033[0m"
@@ -0,0 +1,59 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"testing"
+
+	"github.com/issue9/assert"
+	"github.com/issue9/term/colors"
+)
+
+func TestParseColor(t *testing.T) {
+	a := assert.New(t)
+
+	c, err := ParseColor("Red")
+	a.NotError(err).Equal(c, BasicColor(colors.Red))
+	a.Equal(c.sgr(false), "31").Equal(c.sgr(true), "41")
+
+	c, err = ParseColor("208")
+	a.NotError(err).Equal(c, Color256(208))
+	a.Equal(c.sgr(false), "38;5;208").Equal(c.sgr(true), "48;5;208")
+
+	c, err = ParseColor("#FF8800")
+	a.NotError(err).Equal(c, RGB(0xff, 0x88, 0))
+	a.Equal(c.sgr(false), "38;2;255;136;0").Equal(c.sgr(true), "48;2;255;136;0")
+
+	_, err = ParseColor("red1")
+	a.Error(err)
+	_, err = ParseColor("256")
+	a.Error(err)
+	_, err = ParseColor("#ff88")
+	a.Error(err)
+	_, err = ParseColor("#gg8800")
+	a.Error(err)
+}
+
+func TestParseConsoleStyle(t *testing.T) {
+	a := assert.New(t)
+
+	s, err := ParseConsoleStyle("red")
+	a.NotError(err).
+		Equal(s.Foreground, BasicColor(colors.Red)).
+		Equal(s.Background, BasicColor(colors.Default)).
+		True(s.isBasic())
+
+	s, err = ParseConsoleStyle("white/#ff0000")
+	a.NotError(err).
+		Equal(s.Foreground, BasicColor(colors.White)).
+		Equal(s.Background, RGB(0xff, 0, 0)).
+		False(s.isBasic())
+	a.Equal(s.ansi(), "\033[37;48;2;255;0;0m")
+
+	_, err = ParseConsoleStyle("red/red1")
+	a.Error(err)
+	_, err = ParseConsoleStyle("red1/red")
+	a.Error(err)
+}
@@ -5,35 +5,211 @@
 package writers
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/issue9/term/colors"
 )
 
+// Console 的色彩模式
+const (
+	ConsoleColorAuto   = iota // 输出到终端时才使用色彩，同时遵守 NO_COLOR 和 TERM=dumb 的约定
+	ConsoleColorAlways        // 始终使用色彩
+	ConsoleColorNever         // 从不使用色彩
+)
+
+// Console 的高亮范围
+const (
+	ConsoleHighlightLine  = iota // 整行
+	ConsoleHighlightLevel        // 仅级别标签，即 log.Logger 的 prefix 部分
+	ConsoleHighlightTime         // 仅日期和时间部分
+)
+
+// Console 的输出模式
+const (
+	ConsoleModeRaw = iota // 原样输出 log.Logger 的内容
+	ConsoleModeDev        // 开发模式，以对齐的、易读的格式输出
+)
+
 // 带色彩输出的控制台。
 type Console struct {
-	out *os.File
-	c   colors.Colorize
+	out     *os.File
+	style   ConsoleStyle
+	colored bool // 是否输出色彩，由色彩模式决定
+
+	highlight   int
+	levelStyles map[string]ConsoleStyle
+
+	mode        int
+	start       time.Time // 创建时间，开发模式下的相对时间以此为基准
+	callerWidth int       // 开发模式下调用位置的宽度
+
+	// 由 SetLevel 设置
+	level  string
+	prefix string
+	flag   int
 }
 
 // 新建Console实例
 //
 // out为输出方向，可以是colors.Stderr和colors.Stdout两个值。
 // foreground,background 为输出文字的前景色和背景色。
+// 默认的色彩模式为 ConsoleColorAuto。
 func NewConsole(out *os.File, foreground, background colors.Color) *Console {
-	return &Console{
-		out: out,
-		c:   colors.New(foreground, background),
-	}
+	c := &Console{out: out, start: time.Now()}
+	c.SetColor(foreground, background)
+	c.SetColorMode(ConsoleColorAuto)
+
+	return c
 }
 
 // 更改输出颜色
 func (c *Console) SetColor(foreground, background colors.Color) {
-	c.c.Foreground = foreground
-	c.c.Background = background
+	c.SetStyle(ConsoleStyle{
+		Foreground: BasicColor(foreground),
+		Background: BasicColor(background),
+	})
+}
+
+// 更改输出颜色，与 SetColor 相同，但可以使用 256 色和真彩色。
+func (c *Console) SetStyle(style ConsoleStyle) {
+	c.style = style
+}
+
+// 设置各个级别的颜色，键名为级别名称，如 info、error 等。
+// 当前级别在 styles 中不存在时，使用 SetStyle 设置的颜色。
+//
+// 当同一个配置需要在多个级别中使用时，可以通过此方法为每个级别指定不同的颜色。
+func (c *Console) SetLevelStyles(styles map[string]ConsoleStyle) {
+	c.levelStyles = styles
+}
+
+// 设置高亮的范围，h 的值为 ConsoleHighlightLine 等常量。
+//
+// ConsoleHighlightLevel 和 ConsoleHighlightTime 需要知道 log.Logger
+// 的 prefix 和 flag 参数，这些通过 SetLevel 设置，未设置时将不会输出色彩。
+func (c *Console) SetHighlight(h int) error {
+	if h < ConsoleHighlightLine || h > ConsoleHighlightTime {
+		return fmt.Errorf("无效的高亮范围:[%v]", h)
+	}
+
+	c.highlight = h
+	return nil
+}
+
+// Leveler.SetLevel()
+func (c *Console) SetLevel(level, prefix string, flag int) {
+	c.level = level
+	c.prefix = prefix
+	c.flag = flag
+}
+
+// 更改输出模式，mode 的值为 ConsoleModeRaw 等常量。
+//
+// ConsoleModeDev 需要知道 log.Logger 的 prefix 和 flag 参数，
+// 这些通过 SetLevel 设置，否则只能将整行内容当作消息处理。
+func (c *Console) SetMode(mode int) error {
+	if mode < ConsoleModeRaw || mode > ConsoleModeDev {
+		return fmt.Errorf("无效的输出模式:[%v]", mode)
+	}
+
+	c.mode = mode
+	return nil
+}
+
+// 更改色彩模式，mode 的值为 ConsoleColorAuto 等常量。
+func (c *Console) SetColorMode(mode int) error {
+	switch mode {
+	case ConsoleColorAuto:
+		c.colored = isTerminal(c.out) && !noColor()
+	case ConsoleColorAlways:
+		c.colored = true
+	case ConsoleColorNever:
+		c.colored = false
+	default:
+		return fmt.Errorf("无效的色彩模式:[%v]", mode)
+	}
+
+	return nil
 }
 
 // io.Writer
 func (c *Console) Write(b []byte) (size int, err error) {
-	return c.c.Fprint(c.out, string(b))
+	if c.mode == ConsoleModeDev {
+		if _, err = c.out.Write(c.renderDev(b)); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if !c.colored {
+		return c.out.Write(b)
+	}
+
+	style := c.style
+	if s, found := c.levelStyles[c.level]; found {
+		style = s
+	}
+
+	if c.highlight == ConsoleHighlightLine && style.isBasic() {
+		colorize := colors.New(colors.Color(style.Foreground.value), colors.Color(style.Background.value))
+		if _, err := colorize.Fprint(c.out, string(b)); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	return c.writeANSI(b, style)
+}
+
+// 直接通过 ANSI 控制码输出色彩，可以只对部分内容着色。
+func (c *Console) writeANSI(b []byte, style ConsoleStyle) (int, error) {
+	var s span
+	switch c.highlight {
+	case ConsoleHighlightLevel:
+		s = parseRecord(b, c.prefix, c.flag).prefix
+	case ConsoleHighlightTime:
+		s = parseRecord(b, c.prefix, c.flag).time
+	default: // 整行，但不包含最后的换行符
+		s = span{0, len(b)}
+		if s.end > 0 && b[s.end-1] == '\n' {
+			s.end--
+		}
+	}
+
+	if s.empty() {
+		return c.out.Write(b)
+	}
+
+	ansi := style.ansi()
+	buf := make([]byte, 0, len(b)+len(ansi)+len(ansiReset))
+	buf = append(buf, b[:s.start]...)
+	buf = append(buf, ansi...)
+	buf = append(buf, b[s.start:s.end]...)
+	buf = append(buf, ansiReset...)
+	buf = append(buf, b[s.end:]...)
+
+	if _, err := c.out.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// 判断 f 是否为一个终端设备
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+// 环境变量是否要求不输出色彩。
+//
+// NO_COLOR 不为空或是 TERM 为 dumb 时，表示不需要色彩，
+// 具体可参考 https://no-color.org
+func noColor() bool {
+	return os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb"
 }
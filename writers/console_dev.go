@@ -0,0 +1,201 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 开发模式下，级别标签的宽度
+const devBadgeWidth = 5
+
+// 开发模式下，多行内容及字段的缩进
+const devIndent = "    "
+
+// 各级别在开发模式下显示的标签，超过 devBadgeWidth 的会被截断。
+var devBadges = map[string]string{
+	"info":     "INFO",
+	"debug":    "DEBUG",
+	"trace":    "TRACE",
+	"warn":     "WARN",
+	"error":    "ERROR",
+	"critical": "CRIT",
+}
+
+// 以开发模式渲染一条日志，输出格式如下：
+//  ERROR  +1.203s  logs/main.go:12  connect failed
+//      addr = "127.0.0.1:80"
+//      retry = 3
+//      goroutine 1 [running]:
+//      ...
+//
+// 依次为固定宽度的级别标签、相对于 Console 创建时的时间、缩短为 包名/文件名:行号
+// 的调用位置以及消息内容；消息第一行末尾 key=value 形式的字段会被提取出来，
+// 与多行内容一起缩进显示在其下方。
+func (c *Console) renderDev(b []byte) []byte {
+	r := parseRecord(b, c.prefix, c.flag)
+	buf := new(bytes.Buffer)
+	buf.Grow(len(b) + 64)
+
+	// 级别标签
+	badge := fmt.Sprintf("%-*s", devBadgeWidth, c.badge())
+	if c.colored {
+		style := c.style
+		if s, found := c.levelStyles[c.level]; found {
+			style = s
+		}
+		buf.WriteString(style.ansi())
+		buf.WriteString(badge)
+		buf.WriteString(ansiReset)
+	} else {
+		buf.WriteString(badge)
+	}
+
+	// 相对时间
+	t, ok := r.parseTime()
+	if !ok || r.flag&log.Lmicroseconds == 0 { // 精度不到微秒时，使用当前时间
+		t = time.Now()
+	}
+	fmt.Fprintf(buf, " %9s ", fmt.Sprintf("+%.3fs", t.Sub(c.start).Seconds()))
+
+	// 调用位置，宽度只增不减，以保证多条记录之间尽量对齐。
+	if !r.caller.empty() {
+		caller := shortCaller(string(r.bytes(r.caller)))
+		if len(caller) > c.callerWidth {
+			c.callerWidth = len(caller)
+		}
+		fmt.Fprintf(buf, " %-*s ", c.callerWidth, caller)
+	}
+
+	lines := strings.Split(string(r.bytes(r.message)), "\n")
+	msg, fields := splitFields(lines[0])
+	buf.WriteByte(' ')
+	buf.WriteString(msg)
+	buf.WriteByte('\n')
+
+	keyWidth := 0
+	for _, f := range fields {
+		if len(f[0]) > keyWidth {
+			keyWidth = len(f[0])
+		}
+	}
+	for _, f := range fields {
+		fmt.Fprintf(buf, "%s%-*s = %s\n", devIndent, keyWidth, f[0], f[1])
+	}
+
+	for _, line := range lines[1:] {
+		buf.WriteString(devIndent)
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+// 当前级别的标签
+func (c *Console) badge() string {
+	badge, found := devBadges[c.level]
+	if !found {
+		badge = c.level
+		if badge == "" {
+			badge = strings.Trim(c.prefix, "[]: ")
+		}
+		badge = strings.ToUpper(badge)
+	}
+
+	if len(badge) > devBadgeWidth {
+		badge = badge[:devBadgeWidth]
+	}
+	return badge
+}
+
+// 将调用位置缩短为 包名/文件名:行号 的形式
+func shortCaller(caller string) string {
+	dir, file := path.Split(strings.Replace(caller, "\\", "/", -1))
+	if dir == "" {
+		return file
+	}
+
+	return path.Base(dir) + "/" + file
+}
+
+// 从消息末尾提取 key=value 形式的字段，value 可以是带引号的字符串。
+// 返回去掉字段之后的消息及字段列表，字段按在消息中出现的顺序排列。
+func splitFields(msg string) (string, [][2]string) {
+	var fields [][2]string
+
+	rest := strings.TrimRightFunc(msg, unicode.IsSpace)
+	for {
+		key, value, start := lastField(rest)
+		if start < 0 {
+			break
+		}
+		fields = append([][2]string{{key, value}}, fields...)
+		rest = strings.TrimRightFunc(rest[:start], unicode.IsSpace)
+	}
+
+	if rest == "" { // 全部都是字段，则不作拆分
+		return msg, nil
+	}
+	return rest, fields
+}
+
+// 解析 s 末尾的一个字段，返回字段名、值以及字段的起始位置，
+// 不存在字段时，起始位置返回 -1。
+func lastField(s string) (key, value string, start int) {
+	if s == "" {
+		return "", "", -1
+	}
+
+	var valStart int
+	if s[len(s)-1] == '"' { // 带引号的值，允许包含空格
+		index := len(s) - 2
+		for ; index >= 0; index-- {
+			if s[index] == '"' && (index == 0 || s[index-1] != '\\') {
+				break
+			}
+		}
+		if index <= 0 || s[index-1] != '=' {
+			return "", "", -1
+		}
+		valStart = index
+	} else {
+		valStart = strings.LastIndexAny(s, " \t") + 1
+		index := strings.IndexByte(s[valStart:], '=')
+		if index <= 0 {
+			return "", "", -1
+		}
+		valStart += index + 1
+	}
+
+	keyEnd := valStart - 1
+	keyStart := strings.LastIndexAny(s[:keyEnd], " \t") + 1
+	key = s[keyStart:keyEnd]
+	if !isFieldKey(key) {
+		return "", "", -1
+	}
+
+	return key, s[valStart:], keyStart
+}
+
+func isFieldKey(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	for i, r := range key {
+		if r == '_' || unicode.IsLetter(r) || (i > 0 && (unicode.IsDigit(r) || r == '.' || r == '-')) {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/issue9/assert"
+	"github.com/issue9/term/colors"
+)
+
+func TestConsole_renderDev(t *testing.T) {
+	a := assert.New(t)
+	buf := new(bytes.Buffer)
+
+	c := NewConsole(nil, colors.Red, colors.Default)
+	a.NotError(c.SetMode(ConsoleModeDev))
+	a.Error(c.SetMode(-1))
+	c.SetLevel("critical", "[CRITICAL]", log.Ltime|log.Lmicroseconds|log.Llongfile)
+
+	render := func(msg string) []string {
+		buf.Reset()
+		log.New(buf, c.prefix, c.flag).Output(1, msg)
+		out := string(c.renderDev(buf.Bytes()))
+		a.True(strings.HasSuffix(out, "\n"))
+		return strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	}
+
+	lines := render(`connect failed addr="127.0.0.1:80 x" retry=3` + "\ngoroutine 1 [running]:\nmain.main()")
+	a.Equal(len(lines), 5)
+	header := strings.Fields(lines[0])
+	a.Equal(header[0], "CRIT").
+		True(strings.HasPrefix(header[1], "+")).
+		True(strings.HasPrefix(header[2], "writers/console_dev_test.go:")).
+		True(strings.HasSuffix(lines[0], "  connect failed"))
+	a.Equal(lines[1], devIndent+`addr  = "127.0.0.1:80 x"`).
+		Equal(lines[2], devIndent+"retry = 3").
+		Equal(lines[3], devIndent+"goroutine 1 [running]:").
+		Equal(lines[4], devIndent+"main.main()")
+
+	// 未设置级别，使用 prefix 作为标签
+	c.SetLevel("", "[notice]", 0)
+	lines = render("hello")
+	a.Equal(len(lines), 1)
+	header = strings.Fields(lines[0])
+	a.Equal(header[0], "NOTIC").
+		True(strings.HasSuffix(header[1], "s")).
+		Equal(header[2], "hello")
+}
+
+func TestSplitFields(t *testing.T) {
+	a := assert.New(t)
+
+	msg, fields := splitFields(`login uid=12 name="a b" ok`)
+	a.Equal(msg, `login uid=12 name="a b" ok`).Equal(len(fields), 0)
+
+	msg, fields = splitFields(`login uid=12 name="a \"b\"" `)
+	a.Equal(msg, "login").
+		Equal(fields, [][2]string{{"uid", "12"}, {"name", `"a \"b\""`}})
+
+	msg, fields = splitFields(`a=b=c`)
+	a.Equal(msg, "a=b=c").Equal(len(fields), 0)
+
+	msg, fields = splitFields(`x 1=2`)
+	a.Equal(msg, "x 1=2").Equal(len(fields), 0)
+}
+
+func TestShortCaller(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal(shortCaller("main.go:12"), "main.go:12")
+	a.Equal(shortCaller("/home/user/logs/writers/console.go:12"), "writers/console.go:12")
+	a.Equal(shortCaller(`C:\logs\writers\console.go:12`), "writers/console.go:12")
+}
@@ -6,16 +6,23 @@ package writers
 
 import (
 	"io"
+	"io/ioutil"
+	"log"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/issue9/assert"
 	"github.com/issue9/term/colors"
 )
 
 var _ io.Writer = &Console{}
 
+var _ Leveler = &Console{}
+
 func TestConsole(t *testing.T) {
 	c := NewConsole(os.Stderr, colors.Cyan, colors.Default)
+	c.SetColorMode(ConsoleColorAlways)
 	c.Write([]byte("is cyan\n"))
 
 	c.SetColor(colors.Blue, colors.Default)
@@ -23,3 +30,107 @@ func TestConsole(t *testing.T) {
 
 	os.Stderr.WriteString("Reset\n")
 }
+
+func TestConsole_SetColorMode(t *testing.T) {
+	a := assert.New(t)
+
+	f, err := ioutil.TempFile("", "logs-console")
+	a.NotError(err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	content := func() string {
+		bs, err := ioutil.ReadFile(f.Name())
+		a.NotError(err)
+		a.NotError(f.Truncate(0))
+		_, err = f.Seek(0, 0)
+		a.NotError(err)
+		return string(bs)
+	}
+
+	// 输出到文件，auto 模式下不输出色彩
+	c := NewConsole(f, colors.Red, colors.Default)
+	c.Write([]byte("abc"))
+	a.Equal(content(), "abc")
+
+	a.NotError(c.SetColorMode(ConsoleColorAlways))
+	c.Write([]byte("abc"))
+	s := content()
+	a.True(strings.Contains(s, "abc")).NotEqual(s, "abc")
+
+	a.NotError(c.SetColorMode(ConsoleColorNever))
+	c.Write([]byte("abc"))
+	a.Equal(content(), "abc")
+
+	a.Error(c.SetColorMode(-1))
+}
+
+func TestConsole_SetHighlight(t *testing.T) {
+	a := assert.New(t)
+
+	f, err := ioutil.TempFile("", "logs-console")
+	a.NotError(err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	c := NewConsole(f, colors.Red, colors.Default)
+	a.NotError(c.SetColorMode(ConsoleColorAlways))
+	c.SetLevel("error", "[ERROR]", log.Ltime)
+	c.SetLevelStyles(map[string]ConsoleStyle{
+		"info":  {Foreground: BasicColor(colors.Green), Background: BasicColor(colors.Default)},
+		"error": {Foreground: Color256(196), Background: BasicColor(colors.Default)},
+	})
+
+	write := func(msg string) string {
+		a.NotError(f.Truncate(0))
+		_, err := f.Seek(0, 0)
+		a.NotError(err)
+
+		size, err := c.Write([]byte(msg))
+		a.NotError(err).Equal(size, len(msg))
+
+		bs, err := ioutil.ReadFile(f.Name())
+		a.NotError(err)
+		return string(bs)
+	}
+
+	ansi := "\033[38;5;196;49m"
+
+	// 整行，使用 error 级别的颜色
+	a.Equal(write("[ERROR]12:00:00 abc\n"), ansi+"[ERROR]12:00:00 abc"+ansiReset+"\n")
+
+	a.NotError(c.SetHighlight(ConsoleHighlightLevel))
+	a.Equal(write("[ERROR]12:00:00 abc\n"), ansi+"[ERROR]"+ansiReset+"12:00:00 abc\n")
+
+	a.NotError(c.SetHighlight(ConsoleHighlightTime))
+	a.Equal(write("[ERROR]12:00:00 abc\n"), "[ERROR]"+ansi+"12:00:00"+ansiReset+" abc\n")
+
+	// 不存在的级别，使用默认的基本颜色
+	c.SetLevel("debug", "", 0)
+	a.Equal(write("abc\n"), "abc\n")
+	a.NotError(c.SetHighlight(ConsoleHighlightLine))
+	a.NotEqual(write("abc\n"), "abc\n")
+
+	a.Error(c.SetHighlight(-1))
+}
+
+func TestNoColor(t *testing.T) {
+	a := assert.New(t)
+
+	noColorEnv, termEnv := os.Getenv("NO_COLOR"), os.Getenv("TERM")
+	defer func() {
+		os.Setenv("NO_COLOR", noColorEnv)
+		os.Setenv("TERM", termEnv)
+	}()
+
+	os.Setenv("NO_COLOR", "")
+	os.Setenv("TERM", "xterm")
+	a.False(noColor())
+
+	os.Setenv("NO_COLOR", "1")
+	a.True(noColor())
+
+	os.Setenv("NO_COLOR", "")
+	os.Setenv("TERM", "dumb")
+	a.True(noColor())
+}
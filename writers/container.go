@@ -6,29 +6,203 @@ package writers
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"sync"
+	"time"
 )
 
+// Container 的错误处理策略
+const (
+	ContainerStopOnError     = iota // 某一项出错时，直接返回其错误信息，后续的都将中断
+	ContainerContinueOnError        // 某一项出错时，继续输出后续项，最后返回所有的错误信息
+)
+
+// 并行输出时，每个子项最多可以积压的日志数量，超出时新的日志将被丢弃。
+const containerQueueSize = 100
+
+// 多个错误信息的集合
+type Errors []error
+
+// error.Error()
+func (errs Errors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// 供 errors.Is 和 errors.As 使用
+func (errs Errors) Unwrap() []error {
+	return errs
+}
+
+// 容器中的一项
+type containerItem struct {
+	name string // 名称，通过 Add 添加的项为空
+	w    io.Writer
+	mu   sync.Mutex // 保证同一个 writer 不会被同时写入
+
+	// 并行输出时的队列，由唯一的 goroutine 按顺序输出，
+	// 第一次并行输出时才创建。
+	once  sync.Once
+	queue chan *containerJob
+}
+
+// 并行输出时，需要子项输出的一条日志
+type containerJob struct {
+	data    []byte
+	results chan<- error
+}
+
 // io.Writer的容器。
+//
+// 可以在输出的同时添加或是删除子项。
 type Container struct {
-	ws []io.Writer
+	mu       sync.RWMutex
+	ws       []*containerItem
+	policy   int
+	parallel bool
+	timeout  time.Duration
 }
 
 // 构造Container实例
 func NewContainer() *Container {
-	return &Container{ws: make([]io.Writer, 0, 1)}
+	return &Container{ws: make([]*containerItem, 0, 1)}
 }
 
-// 当某一项出错时，将直接返回其信息，后续的都将中断。
+// 设置错误处理策略，policy 的值为 ContainerStopOnError 等常量，
+// 默认为 ContainerStopOnError。
+func (c *Container) SetPolicy(policy int) error {
+	if policy != ContainerStopOnError && policy != ContainerContinueOnError {
+		return fmt.Errorf("无效的错误处理策略:[%v]", policy)
+	}
+
+	c.mu.Lock()
+	c.policy = policy
+	c.mu.Unlock()
+	return nil
+}
+
+// 设置是否并行向所有子项输出。
+//
+// timeout 为等待每个子项完成的最长时间，为 0 表示一直等待。
+// 超时的子项会在后台继续按顺序输出，但 Write 会返回超时的错误信息；
+// 每个子项最多积压 100 条日志，超出时该子项会丢弃新的日志，并返回错误信息。
+// 并行时，所有的子项都会被输出，错误处理策略仅决定返回第一个错误还是所有的错误。
+func (c *Container) SetParallel(parallel bool, timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.parallel = parallel
+	c.timeout = timeout
+}
+
+// io.Writer.Write()
+//
+// 所有子项都输出成功时，返回 len(bs)；否则返回 0 及错误信息，
+// 根据错误处理策略的不同，错误信息可能是 Errors 类型。
 // 若容器为空时，则相当于不作任何动作。
 func (c *Container) Write(bs []byte) (size int, err error) {
-	for _, w := range c.ws {
-		if size, err = w.Write(bs); err != nil {
-			return
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var errs Errors
+	if c.parallel {
+		errs = c.writeParallel(bs)
+	} else {
+		for _, item := range c.ws {
+			if _, err = item.write(bs); err != nil {
+				if c.policy == ContainerStopOnError {
+					return 0, err
+				}
+				errs = append(errs, err)
+			}
 		}
 	}
 
-	return
+	if len(errs) == 0 {
+		return len(bs), nil
+	}
+
+	if c.policy == ContainerStopOnError {
+		return 0, errs[0]
+	}
+	return 0, errs
+}
+
+func (c *Container) writeParallel(bs []byte) Errors {
+	if len(c.ws) == 0 {
+		return nil
+	}
+
+	// 超时的子项可能在 Write 返回之后依然在使用该内容，
+	// 而 bs 会被 log.Logger 重复使用，所以需要复制一份。
+	cp := make([]byte, len(bs))
+	copy(cp, bs)
+
+	var errs Errors
+	results := make(chan error, len(c.ws))
+	size := 0 // 成功放入队列的数量
+	for _, item := range c.ws {
+		item.once.Do(item.start)
+
+		select {
+		case item.queue <- &containerJob{data: cp, results: results}:
+			size++
+		default:
+			errs = append(errs, errors.New("子项的上一次输出尚未完成，且积压的日志过多，已丢弃该日志"))
+		}
+	}
+
+	var timeout <-chan time.Time
+	if c.timeout > 0 {
+		timer := time.NewTimer(c.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for i := 0; i < size; i++ {
+		select {
+		case err := <-results:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-timeout:
+			return append(errs, fmt.Errorf("%d 个子项输出超时", size-i))
+		}
+	}
+
+	return errs
+}
+
+// 启动并行输出的 goroutine
+func (item *containerItem) start() {
+	item.queue = make(chan *containerJob, containerQueueSize)
+	go func() {
+		for job := range item.queue {
+			_, err := item.write(job.data)
+			job.results <- err // results 有足够的缓存，不会阻塞
+		}
+	}()
+}
+
+// 结束并行输出的 goroutine，已经在队列中的日志依然会被输出。
+// 需要在 Container.mu 的写锁中调用，保证此时不会再有新的日志。
+func (item *containerItem) stop() {
+	item.once.Do(func() {}) // 未启动的，之后也不再启动
+	if item.queue != nil {
+		close(item.queue)
+	}
+}
+
+func (item *containerItem) write(bs []byte) (int, error) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	return item.w.Write(bs)
 }
 
 // 添加一个io.Writer实例
@@ -37,31 +211,128 @@ func (c *Container) Add(w io.Writer) error {
 		return errors.New("参数w不能为一个空值")
 	}
 
-	c.ws = append(c.ws, w)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ws = append(c.ws, &containerItem{w: w})
 	return nil
 }
 
+// 添加一个带名称的io.Writer实例，之后可以通过 Remove 删除。
+// 名称不能为空，且不能与已有的名称相同。
+func (c *Container) AddNamed(name string, w io.Writer) error {
+	if name == "" {
+		return errors.New("参数name不能为空")
+	}
+
+	if w == nil {
+		return errors.New("参数w不能为一个空值")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, item := range c.ws {
+		if item.name == name {
+			return fmt.Errorf("已经存在同名的子项:[%v]", name)
+		}
+	}
+
+	c.ws = append(c.ws, &containerItem{name: name, w: w})
+	return nil
+}
+
+// 删除通过 AddNamed 添加的子项，返回值表示是否存在该子项。
+func (c *Container) Remove(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, item := range c.ws {
+		if item.name != name {
+			continue
+		}
+
+		item.stop()
+		c.ws = append(c.ws[:i], c.ws[i+1:]...)
+		return true
+	}
+
+	return false
+}
+
 // 调用所有子项的Flush函数。
+// 错误处理策略与 Write 相同。
 func (c *Container) Flush() (size int, err error) {
-	for _, w := range c.ws {
-		b, ok := w.(Flusher)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var errs Errors
+	for _, item := range c.ws {
+		b, ok := item.w.(Flusher)
 		if !ok {
 			continue
 		}
 
 		if size, err = b.Flush(); err != nil {
-			return size, err
+			if c.policy == ContainerStopOnError {
+				return size, err
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return 0, errs
+	}
+	return size, nil
+}
+
+// io.Closer.Close()
+// 调用所有实现了 io.Closer 的子项的 Close 函数，错误处理策略与 Write 相同。
+func (c *Container) Close() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var errs Errors
+	for _, item := range c.ws {
+		cl, ok := item.w.(io.Closer)
+		if !ok {
+			continue
+		}
+
+		if err := cl.Close(); err != nil {
+			if c.policy == ContainerStopOnError {
+				return err
+			}
+			errs = append(errs, err)
 		}
 	}
-	return size, err
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
 // 包含的元素
 func (c *Container) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	return len(c.ws)
 }
 
 // 清除所有的writer
 func (c *Container) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, item := range c.ws {
+		item.stop()
+	}
 	c.ws = c.ws[:0]
 }
@@ -6,7 +6,12 @@ package writers
 
 import (
 	"bytes"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/issue9/assert"
 )
@@ -57,3 +62,227 @@ func TestContainer(t *testing.T) {
 	a.Equal(" worldhello", b2.String())
 	a.Equal(1, c.Len())
 }
+
+// 可以指定错误或是延时的 writer
+type containerTestWriter struct {
+	err   error
+	delay time.Duration
+	mu    sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (w *containerTestWriter) Write(bs []byte) (int, error) {
+	time.Sleep(w.delay)
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(bs)
+}
+
+func (w *containerTestWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestContainer_SetPolicy(t *testing.T) {
+	a := assert.New(t)
+
+	err1 := errors.New("err1")
+	err2 := errors.New("err2")
+	w1 := &containerTestWriter{err: err1}
+	w2 := &containerTestWriter{}
+	w3 := &containerTestWriter{err: err2}
+
+	c := NewContainer()
+	a.NotError(c.Add(w1)).NotError(c.Add(w2)).NotError(c.Add(w3))
+
+	// 默认为 ContainerStopOnError，w2 不会有内容
+	size, err := c.Write([]byte("abc"))
+	a.Equal(err, err1).Equal(size, 0)
+	a.Equal(w2.String(), "")
+
+	a.Error(c.SetPolicy(-1))
+	a.NotError(c.SetPolicy(ContainerContinueOnError))
+	size, err = c.Write([]byte("abc"))
+	a.Equal(size, 0).Equal(w2.String(), "abc")
+	errs, ok := err.(Errors)
+	a.True(ok).Equal(len(errs), 2)
+	a.True(errors.Is(err, err1)).True(errors.Is(err, err2))
+	a.Equal(err.Error(), "err1; err2")
+
+	// 全部正确时，返回 len(bs)
+	w1.err = nil
+	w3.err = nil
+	size, err = c.Write([]byte("abc"))
+	a.NotError(err).Equal(size, 3)
+
+	// 空容器
+	c.Clear()
+	size, err = c.Write([]byte("abc"))
+	a.NotError(err).Equal(size, 3)
+}
+
+func TestContainer_SetParallel(t *testing.T) {
+	a := assert.New(t)
+
+	w1 := &containerTestWriter{delay: 100 * time.Millisecond}
+	w2 := &containerTestWriter{delay: 100 * time.Millisecond}
+	w3 := &containerTestWriter{delay: 500 * time.Millisecond}
+
+	c := NewContainer()
+	a.NotError(c.Add(w1)).NotError(c.Add(w2))
+	c.SetParallel(true, 0)
+
+	// 并行输出，总耗时与单个子项相近
+	start := time.Now()
+	size, err := c.Write([]byte("abc"))
+	a.NotError(err).Equal(size, 3)
+	a.True(time.Since(start) < 190*time.Millisecond)
+	a.Equal(w1.String(), "abc").Equal(w2.String(), "abc")
+
+	// w3 超时
+	a.NotError(c.Add(w3))
+	c.SetParallel(true, 200*time.Millisecond)
+	bs := []byte("def")
+	size, err = c.Write(bs)
+	a.Error(err).Equal(size, 0)
+	a.Equal(w1.String(), "abcdef").Equal(w3.String(), "")
+
+	// 超时的子项依然会在后台完成输出，且不受 bs 被修改的影响。
+	bs[0] = 'x'
+	time.Sleep(500 * time.Millisecond)
+	a.Equal(w3.String(), "def")
+}
+
+// 在 gate 关闭之前一直阻塞的 writer
+type containerBlockWriter struct {
+	gate    chan struct{}
+	mu      sync.Mutex
+	records []string
+}
+
+func (w *containerBlockWriter) Write(bs []byte) (int, error) {
+	<-w.gate
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.records = append(w.records, string(bs))
+	return len(bs), nil
+}
+
+func (w *containerBlockWriter) len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.records)
+}
+
+func TestContainer_SetParallel_blocking(t *testing.T) {
+	a := assert.New(t)
+
+	w := &containerBlockWriter{gate: make(chan struct{})}
+	c := NewContainer()
+	a.NotError(c.Add(w))
+	c.SetParallel(true, time.Millisecond)
+
+	before := runtime.NumGoroutine()
+	total := containerQueueSize + 50
+	for i := 0; i < total; i++ {
+		size, err := c.Write([]byte(strconv.Itoa(i)))
+		a.Error(err).Equal(size, 0)
+	}
+
+	// 阻塞的子项只有一个 goroutine
+	a.True(runtime.NumGoroutine()-before <= 1)
+
+	// 正在输出的一条加上队列中的日志，按顺序输出，其它的被丢弃
+	close(w.gate)
+	for i := 0; i < 100 && w.len() < containerQueueSize+1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	a.Equal(w.len(), containerQueueSize+1)
+	for i, record := range w.records {
+		a.Equal(record, strconv.Itoa(i))
+	}
+
+	// 删除之后结束 goroutine
+	c.Clear()
+	time.Sleep(20 * time.Millisecond)
+	a.True(runtime.NumGoroutine() <= before)
+}
+
+func TestContainer_AddNamed(t *testing.T) {
+	a := assert.New(t)
+	b1 := new(containerTestWriter)
+	b2 := new(containerTestWriter)
+
+	c := NewContainer()
+	a.Error(c.AddNamed("", b1))
+	a.Error(c.AddNamed("b1", nil))
+	a.NotError(c.AddNamed("b1", b1))
+	a.Error(c.AddNamed("b1", b2)) // 重名
+	a.NotError(c.Add(b2))
+	a.Equal(c.Len(), 2)
+
+	c.Write([]byte("abc"))
+	a.Equal(b1.String(), "abc").Equal(b2.String(), "abc")
+
+	a.False(c.Remove("b2"))
+	a.False(c.Remove(""))
+	a.True(c.Remove("b1"))
+	a.Equal(c.Len(), 1)
+
+	c.Write([]byte("def"))
+	a.Equal(b1.String(), "abc").Equal(b2.String(), "abcdef")
+
+	// 在输出的同时添加和删除
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			c.Write([]byte("x"))
+		}
+		close(done)
+	}()
+	for i := 0; i < 100; i++ {
+		a.NotError(c.AddNamed("b1", b1))
+		a.True(c.Remove("b1"))
+	}
+	<-done
+	a.Equal(c.Len(), 1)
+}
+
+// 记录 Close 调用次数的 writer
+type containerCloseWriter struct {
+	containerTestWriter
+	closed int
+}
+
+func (w *containerCloseWriter) Close() error {
+	w.closed++
+	return w.err
+}
+
+func TestContainer_Close(t *testing.T) {
+	a := assert.New(t)
+	b1 := &containerCloseWriter{}
+	b2 := &containerCloseWriter{containerTestWriter: containerTestWriter{err: errors.New("b2")}}
+	b3 := &containerCloseWriter{}
+
+	c := NewContainer()
+	a.NotError(c.Add(b1)).NotError(c.Add(new(containerTestWriter)))
+	a.NotError(c.Add(b2)).NotError(c.Add(b3))
+
+	// 出错时中断
+	a.Error(c.Close())
+	a.Equal(b1.closed, 1).Equal(b2.closed, 1).Equal(b3.closed, 0)
+
+	a.NotError(c.SetPolicy(ContainerContinueOnError))
+	err := c.Close()
+	a.Error(err)
+	a.Equal(b1.closed, 2).Equal(b2.closed, 2).Equal(b3.closed, 1)
+	a.Equal(len(err.(Errors)), 1)
+}
@@ -0,0 +1,330 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SQL 语句中参数的占位符格式
+const (
+	DBPlaceholderQuestion = iota // ?，mysql 和 sqlite3 等
+	DBPlaceholderDollar          // $1，postgres
+	DBPlaceholderAt              // @p1，sqlserver
+	DBPlaceholderColon           // :1，oracle
+)
+
+// 每条 INSERT 语句最多包含的记录数，防止参数数量超过数据库的限制。
+const dbMaxRows = 200
+
+// 各驱动默认的占位符格式，未列出的均为 DBPlaceholderQuestion。
+var dbPlaceholders = map[string]int{
+	"postgres":  DBPlaceholderDollar,
+	"pgx":       DBPlaceholderDollar,
+	"sqlserver": DBPlaceholderAt,
+	"mssql":     DBPlaceholderAt,
+	"oracle":    DBPlaceholderColon,
+	"godror":    DBPlaceholderColon,
+	"oci8":      DBPlaceholderColon,
+}
+
+// 通过 database/sql 将日志写入数据表。
+//
+// 数据表包含 log_time、log_level、message、caller 和 fields 字段，
+// 其中 fields 为自定义字段组成的 JSON 对象。Write 只是将日志放入队列，
+// 由后台的 goroutine 以多行 INSERT 的方式批量写入，失败时按 backoff 进行重试。
+// 指定了 retention 之后，会定时删除超过保留时间的日志。
+type DB struct {
+	db          *sql.DB
+	table       string
+	placeholder int
+	fields      string // JSON 格式
+	autoCreate  bool
+	created     bool // 仅在后台的 goroutine 中使用
+
+	keep  time.Duration // 日志的保留时间，为 0 表示不删除
+	every time.Duration // 删除过期日志的间隔
+
+	batchWriter
+	stop chan struct{} // 通知 retention 的 goroutine 退出
+	done chan struct{}
+
+	closeMu sync.RWMutex // 防止 Close 的同时有 Write 重新启动队列
+	closed  bool
+}
+
+// 队列中的每一条日志
+type dbEntry struct {
+	Time    time.Time `json:"t"`
+	Level   string    `json:"l,omitempty"`
+	Message string    `json:"m"`
+	Caller  string    `json:"c,omitempty"`
+}
+
+// 新建 DB 实例。
+// driver 和 dsn 为 sql.Open 的参数，driver 需要事先通过 import 注册；
+// table 为数据表的名称，可以带上 schema，比如 audit.logs。
+func NewDB(driver, dsn, table string) (*DB, error) {
+	if !isDBIdentifier(table) {
+		return nil, fmt.Errorf("无效的table值:[%v]", table)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DB{
+		db:          db,
+		table:       table,
+		placeholder: dbPlaceholders[driver],
+		fields:      "{}",
+	}
+	d.batchWriter = newBatchWriter(d.send)
+	return d, nil
+}
+
+// 设置占位符的格式，默认根据驱动名称判断。
+func (d *DB) SetPlaceholder(placeholder int) error {
+	if placeholder < DBPlaceholderQuestion || placeholder > DBPlaceholderColon {
+		return fmt.Errorf("无效的placeholder值:[%v]", placeholder)
+	}
+
+	d.placeholder = placeholder
+	return nil
+}
+
+// 设置自定义字段，以 JSON 对象的形式保存在 fields 字段中。
+// 只能在第一次调用 Write 之前设置。
+func (d *DB) SetFields(fields map[string]string) error {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	d.fields = string(data)
+	return nil
+}
+
+// 是否在第一次写入之前自动创建数据表。
+//
+// 使用 CREATE TABLE IF NOT EXISTS 语句，适用于 mysql、postgres 和 sqlite3 等，
+// 其它数据库需要事先创建数据表。
+func (d *DB) SetAutoCreate(create bool) {
+	d.autoCreate = create
+}
+
+// 设置日志的保留时间，每隔 every 删除一次 log_time 早于 keep 之前的日志。
+// keep 为 0 表示不删除。只能在第一次调用 Write 之前设置。
+func (d *DB) SetRetention(keep, every time.Duration) error {
+	if keep < 0 {
+		return fmt.Errorf("无效的keep值:[%v]", keep)
+	}
+
+	if keep > 0 && every <= 0 {
+		return fmt.Errorf("无效的every值:[%v]", every)
+	}
+
+	d.keep = keep
+	d.every = every
+	return nil
+}
+
+// io.Writer
+func (d *DB) Write(msg []byte) (int, error) {
+	r := parseRecord(msg, d.prefix, d.flag)
+
+	t, ok := r.parseTime()
+	if !ok {
+		t = time.Now()
+	}
+
+	data, err := json.Marshal(&dbEntry{
+		Time:    t.UTC(),
+		Level:   d.level,
+		Message: string(r.bytes(r.message)),
+		Caller:  string(r.bytes(r.caller)),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	d.closeMu.RLock()
+	defer d.closeMu.RUnlock()
+	if d.closed {
+		return 0, errors.New("数据库连接已经关闭")
+	}
+
+	d.mu.Lock()
+	if d.keep > 0 && d.stop == nil {
+		d.stop = make(chan struct{})
+		d.done = make(chan struct{})
+		go d.retention(d.stop, d.done)
+	}
+	d.mu.Unlock()
+
+	d.push(data)
+	return len(msg), nil
+}
+
+// io.Closer.Close()
+// 写入完队列中的日志之后关闭数据库连接，关闭之后再调用 Write 将返回错误。
+func (d *DB) Close() error {
+	d.closeMu.Lock()
+	defer d.closeMu.Unlock()
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+
+	d.batchWriter.Close()
+
+	d.mu.Lock()
+	stop, done := d.stop, d.done
+	d.stop, d.done = nil, nil
+	d.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	return d.db.Close()
+}
+
+func (d *DB) send(items [][]byte) error {
+	entries := make([]*dbEntry, 0, len(items))
+	for _, item := range items {
+		entry := &dbEntry{}
+		if err := json.Unmarshal(item, entry); err != nil {
+			return &noRetryError{err: err}
+		}
+		entries = append(entries, entry)
+	}
+
+	if d.autoCreate && !d.created {
+		if _, err := d.db.Exec(d.createSQL()); err != nil {
+			return err
+		}
+		d.created = true
+	}
+
+	if len(entries) <= dbMaxRows {
+		query, args := d.insertSQL(entries)
+		_, err := d.db.Exec(query, args...)
+		return err
+	}
+
+	// 需要拆分成多条语句时，使用事务保证要么全部写入要么全部失败
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	for len(entries) > 0 {
+		n := len(entries)
+		if n > dbMaxRows {
+			n = dbMaxRows
+		}
+
+		query, args := d.insertSQL(entries[:n])
+		if _, err = tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+		entries = entries[n:]
+	}
+	return tx.Commit()
+}
+
+func (d *DB) createSQL() string {
+	return "CREATE TABLE IF NOT EXISTS " + d.table + " (" +
+		"log_time TIMESTAMP NOT NULL, " +
+		"log_level VARCHAR(16), " +
+		"message TEXT, " +
+		"caller VARCHAR(255), " +
+		"fields TEXT)"
+}
+
+// 生成多行的 INSERT 语句以及对应的参数
+func (d *DB) insertSQL(entries []*dbEntry) (string, []interface{}) {
+	args := make([]interface{}, 0, len(entries)*5)
+
+	buf := new(strings.Builder)
+	buf.WriteString("INSERT INTO ")
+	buf.WriteString(d.table)
+	buf.WriteString(" (log_time, log_level, message, caller, fields) VALUES ")
+	for i, entry := range entries {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteByte('(')
+		for j := 1; j <= 5; j++ {
+			if j > 1 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(d.param(i*5 + j))
+		}
+		buf.WriteByte(')')
+
+		args = append(args, entry.Time, entry.Level, entry.Message, entry.Caller, d.fields)
+	}
+
+	return buf.String(), args
+}
+
+// 第 n 个参数的占位符，从 1 开始。
+func (d *DB) param(n int) string {
+	switch d.placeholder {
+	case DBPlaceholderDollar:
+		return "$" + strconv.Itoa(n)
+	case DBPlaceholderAt:
+		return "@p" + strconv.Itoa(n)
+	case DBPlaceholderColon:
+		return ":" + strconv.Itoa(n)
+	default:
+		return "?"
+	}
+}
+
+// 定时删除过期的日志，启动时会先执行一次。
+// 删除失败时等待下一次执行，不会影响日志的写入。
+func (d *DB) retention(stop, done chan struct{}) {
+	defer close(done)
+
+	query := "DELETE FROM " + d.table + " WHERE log_time < " + d.param(1)
+	ticker := time.NewTicker(d.every)
+	defer ticker.Stop()
+
+	for {
+		d.db.Exec(query, time.Now().Add(-d.keep).UTC())
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// 表名只能包含字母、数字、下划线和点，且不能以数字开头。
+func isDBIdentifier(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,289 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Leveler        = &DB{}
+	_ Flusher        = &DB{}
+	_ io.WriteCloser = &DB{}
+)
+
+// 模拟的数据库驱动，以 dsn 区分不同的 fakeDB 实例
+type fakeDriver struct{}
+
+type fakeDB struct {
+	mu      sync.Mutex
+	queries []string
+	args    [][]driver.Value
+	fails   int // 之后的 fails 次 INSERT 返回错误
+	commits int
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+var (
+	fakeDBsMu sync.Mutex
+	fakeDBs   = map[string]*fakeDB{}
+)
+
+func init() {
+	sql.Register("logs-fake", fakeDriver{})
+}
+
+func newFakeDB(dsn string) *fakeDB {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+
+	db := &fakeDB{}
+	fakeDBs[dsn] = db
+	return db
+}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+
+	db, found := fakeDBs[dsn]
+	if !found {
+		return nil, errors.New("not found")
+	}
+	return &fakeConn{db: db}, nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+
+func (c *fakeConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.commits++
+	return nil
+}
+
+func (c *fakeConn) Rollback() error { return nil }
+
+func (s *fakeStmt) Close() error { return nil }
+
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if strings.HasPrefix(s.query, "INSERT") && s.db.fails > 0 {
+		s.db.fails--
+		return nil, errors.New("fail")
+	}
+
+	s.db.queries = append(s.db.queries, s.query)
+	s.db.args = append(s.db.args, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+// 返回所有以 prefix 开头的语句的索引
+func (db *fakeDB) find(prefix string) []int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	indexes := []int{}
+	for i, q := range db.queries {
+		if strings.HasPrefix(q, prefix) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func TestNewDB(t *testing.T) {
+	a := assert.New(t)
+	newFakeDB("new")
+
+	d, err := NewDB("logs-fake", "new", "1logs")
+	a.Error(err).Nil(d)
+	d, err = NewDB("logs-fake", "new", "logs;drop")
+	a.Error(err).Nil(d)
+	d, err = NewDB("not-exists", "new", "logs")
+	a.Error(err).Nil(d)
+
+	d, err = NewDB("logs-fake", "new", "audit.logs")
+	a.NotError(err).NotNil(d)
+	a.Equal(d.placeholder, DBPlaceholderQuestion)
+	a.NotError(d.SetPlaceholder(DBPlaceholderAt)).Error(d.SetPlaceholder(10))
+	a.Error(d.SetRetention(-1, time.Hour)).
+		Error(d.SetRetention(time.Hour, 0)).
+		NotError(d.SetRetention(0, 0))
+	a.NotError(d.Close())
+}
+
+func TestDB_insertSQL(t *testing.T) {
+	a := assert.New(t)
+	newFakeDB("sql")
+
+	d, err := NewDB("logs-fake", "sql", "logs")
+	a.NotError(err)
+	defer d.Close()
+	entries := []*dbEntry{{Message: "m1"}, {Message: "m2"}}
+
+	query, args := d.insertSQL(entries)
+	a.Equal(query, "INSERT INTO logs (log_time, log_level, message, caller, fields) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)")
+	a.Equal(len(args), 10).Equal(args[2], "m1").Equal(args[7], "m2")
+
+	a.NotError(d.SetPlaceholder(DBPlaceholderDollar))
+	query, _ = d.insertSQL(entries)
+	a.True(strings.HasSuffix(query, "($1, $2, $3, $4, $5), ($6, $7, $8, $9, $10)"))
+
+	a.NotError(d.SetPlaceholder(DBPlaceholderAt))
+	query, _ = d.insertSQL(entries[:1])
+	a.True(strings.HasSuffix(query, "(@p1, @p2, @p3, @p4, @p5)"))
+
+	a.NotError(d.SetPlaceholder(DBPlaceholderColon))
+	query, _ = d.insertSQL(entries[:1])
+	a.True(strings.HasSuffix(query, "(:1, :2, :3, :4, :5)"))
+}
+
+func TestDB(t *testing.T) {
+	a := assert.New(t)
+	db := newFakeDB("write")
+
+	d, err := NewDB("logs-fake", "write", "logs")
+	a.NotError(err)
+	a.NotError(d.SetBatch(10, time.Hour))
+	a.NotError(d.SetFields(map[string]string{"app": "logs"}))
+	d.SetAutoCreate(true)
+
+	d.SetLevel("error", "[ERROR]", 0)
+	d.Write([]byte("[ERROR]m1\n"))
+	d.Write([]byte("[ERROR]m2\n"))
+	d.Flush()
+
+	a.Equal(db.find("CREATE TABLE IF NOT EXISTS logs "), []int{0})
+	inserts := db.find("INSERT INTO logs ")
+	a.Equal(inserts, []int{1})
+
+	args := db.args[1]
+	a.Equal(len(args), 10)
+	tm, ok := args[0].(time.Time)
+	a.True(ok).False(tm.IsZero())
+	a.Equal(args[1], "error").
+		Equal(args[2], "m1").
+		Equal(args[3], "").
+		Equal(args[4], `{"app":"logs"}`).
+		Equal(args[7], "m2")
+
+	// 只创建一次
+	d.Write([]byte("[ERROR]m3\n"))
+	d.Flush()
+	a.Equal(len(db.find("CREATE")), 1).Equal(len(db.find("INSERT")), 2)
+
+	// 关闭之后不能再写入
+	a.NotError(d.Close()).NotError(d.Close())
+	size, err := d.Write([]byte("[ERROR]m4\n"))
+	a.Error(err).Equal(size, 0)
+	a.Equal(len(db.find("INSERT")), 2)
+}
+
+func TestDB_transaction(t *testing.T) {
+	a := assert.New(t)
+	db := newFakeDB("tx")
+
+	d, err := NewDB("logs-fake", "tx", "logs")
+	a.NotError(err)
+	a.NotError(d.SetBatch(dbMaxRows+10, time.Hour))
+
+	for i := 0; i < dbMaxRows+10; i++ {
+		d.Write([]byte("m\n"))
+	}
+	d.Flush()
+
+	inserts := db.find("INSERT")
+	a.Equal(len(inserts), 2).Equal(db.commits, 1)
+	a.Equal(len(db.args[inserts[0]]), dbMaxRows*5).
+		Equal(len(db.args[inserts[1]]), 10*5)
+
+	a.NotError(d.Close())
+}
+
+func TestDB_retry(t *testing.T) {
+	a := assert.New(t)
+	db := newFakeDB("retry")
+
+	d, err := NewDB("logs-fake", "retry", "logs")
+	a.NotError(err)
+	a.NotError(d.SetQueue(10, 2, time.Millisecond))
+
+	db.mu.Lock()
+	db.fails = 2
+	db.mu.Unlock()
+	d.Write([]byte("m1\n"))
+	d.Flush()
+	a.Equal(len(db.find("INSERT")), 1).Equal(d.Dropped(), 0)
+
+	db.mu.Lock()
+	db.fails = 3
+	db.mu.Unlock()
+	d.Write([]byte("m2\n"))
+	d.Flush()
+	a.Equal(len(db.find("INSERT")), 1).Equal(d.Dropped(), 1)
+
+	a.NotError(d.Close())
+}
+
+func TestDB_retention(t *testing.T) {
+	a := assert.New(t)
+	db := newFakeDB("retention")
+
+	d, err := NewDB("logs-fake", "retention", "logs")
+	a.NotError(err)
+	a.NotError(d.SetPlaceholder(DBPlaceholderDollar))
+	a.NotError(d.SetRetention(time.Hour, 20*time.Millisecond))
+
+	start := time.Now()
+	d.Write([]byte("m1\n"))
+	d.Flush()
+	time.Sleep(50 * time.Millisecond)
+	a.NotError(d.Close())
+
+	deletes := db.find("DELETE FROM logs WHERE log_time < $1")
+	a.True(len(deletes) >= 2)
+
+	before, ok := db.args[deletes[0]][0].(time.Time)
+	a.True(ok)
+	a.True(before.Before(start.Add(-59 * time.Minute))).
+		True(before.After(start.Add(-61 * time.Minute)))
+
+	// 关闭之后不再执行
+	n := len(db.find("DELETE"))
+	time.Sleep(50 * time.Millisecond)
+	a.Equal(len(db.find("DELETE")), n)
+}
@@ -0,0 +1,230 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 通过 _bulk 接口将日志写入 Elasticsearch 或 OpenSearch。
+//
+// Write 只是将日志放入队列，由后台的 goroutine 负责合并发送。
+// 整个请求失败或是部分日志返回 429 和 5xx 时，会对失败的日志进行重试；
+// 其它的错误（比如字段映射冲突）则直接丢弃。
+type Elasticsearch struct {
+	url      string
+	index    string // 索引名称，可以包含 %{layout} 格式的日期
+	username string
+	password string
+	apiKey   string
+	client   *http.Client
+
+	batchWriter
+	failCnt uint64 // 被服务端拒绝的数量
+}
+
+// 每一条日志的文档格式
+type esDocument struct {
+	Timestamp string `json:"@timestamp"`
+	Level     string `json:"level,omitempty"`
+	Caller    string `json:"caller,omitempty"`
+	Message   string `json:"message"`
+}
+
+// _bulk 接口的返回内容
+type esBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+	} `json:"items"`
+}
+
+// 新建 Elasticsearch 实例。
+//
+// url 为服务地址，比如 http://localhost:9200；
+// index 为索引名称，其中的 %{layout} 会按 UTC 时间以 time.Format 格式化，
+// 其它部分保持不变，比如 app-%{2006.01.02}。
+func NewElasticsearch(url, index string) *Elasticsearch {
+	e := &Elasticsearch{
+		url:    strings.TrimRight(url, "/"),
+		index:  index,
+		client: &http.Client{Timeout: defaultHTTPTimeout},
+	}
+	e.batchWriter = newBatchWriter(e.send)
+	return e
+}
+
+// 使用 Basic 验证。
+func (e *Elasticsearch) SetBasicAuth(username, password string) {
+	e.username = username
+	e.password = password
+}
+
+// 使用 API key 验证，优先于 SetBasicAuth()。
+// key 为创建 API key 时返回的 encoded 值。
+func (e *Elasticsearch) SetAPIKey(key string) {
+	e.apiKey = key
+}
+
+// 设置每次请求的超时时间，默认为 30 秒。
+func (e *Elasticsearch) SetTimeout(timeout time.Duration) {
+	e.client.Timeout = timeout
+}
+
+// 设置 http.Client 使用的 Transport，可用于指定 TLS 等配置。
+func (e *Elasticsearch) SetTransport(transport http.RoundTripper) {
+	e.client.Transport = transport
+}
+
+// io.Writer
+func (e *Elasticsearch) Write(msg []byte) (int, error) {
+	r := parseRecord(msg, e.prefix, e.flag)
+
+	t, ok := r.parseTime()
+	if !ok {
+		t = time.Now()
+	}
+	t = t.UTC()
+
+	action, err := json.Marshal(map[string]map[string]string{
+		"index": {"_index": e.indexName(t)},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	doc, err := json.Marshal(&esDocument{
+		Timestamp: t.Format(time.RFC3339Nano),
+		Level:     e.level,
+		Caller:    string(r.bytes(r.caller)),
+		Message:   string(r.bytes(r.message)),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// 每一条日志由操作和文档两行组成
+	data := make([]byte, 0, len(action)+len(doc)+2)
+	data = append(append(data, action...), '\n')
+	data = append(append(data, doc...), '\n')
+
+	e.push(data)
+	return len(msg), nil
+}
+
+// 被丢弃的日志数量，包括队列已满、被服务端拒绝和重试之后依然失败的日志。
+func (e *Elasticsearch) Dropped() uint64 {
+	return atomic.LoadUint64(&e.failCnt) + e.batchWriter.Dropped()
+}
+
+// 发送一批日志，部分日志失败时只对这些日志进行重试。
+// 被服务端拒绝的数量记录在 failCnt 中。
+func (e *Elasticsearch) send(items [][]byte) error {
+	failed, rejected, err := e.bulk(items)
+	atomic.AddUint64(&e.failCnt, uint64(rejected))
+
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return &partialError{err: fmt.Errorf("有[%v]条日志需要重试", len(failed)), items: failed}
+	}
+	return nil
+}
+
+// 调用 _bulk 接口。
+//
+// 返回需要重试的日志以及被拒绝的数量；
+// 整个请求失败时返回 error，不需要重试的错误为 *noRetryError。
+func (e *Elasticsearch) bulk(items [][]byte) (failed [][]byte, rejected int, err error) {
+	req, err := http.NewRequest(http.MethodPost, e.url+"/_bulk", bytes.NewReader(bytes.Join(items, nil)))
+	if err != nil {
+		return nil, 0, &noRetryError{err: err}
+	}
+
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	switch {
+	case e.apiKey != "":
+		req.Header.Set("Authorization", "ApiKey "+e.apiKey)
+	case e.username != "":
+		req.SetBasicAuth(e.username, e.password)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode >= 300 {
+		err = fmt.Errorf("服务端返回错误的状态码:[%v]", resp.StatusCode)
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return nil, 0, err
+		}
+		return nil, 0, &noRetryError{err: err}
+	}
+
+	ret := &esBulkResponse{}
+	if err = json.Unmarshal(data, ret); err != nil {
+		return nil, 0, &noRetryError{err: err}
+	}
+	if !ret.Errors {
+		return nil, 0, nil
+	}
+
+	if len(ret.Items) != len(items) {
+		return nil, 0, &noRetryError{err: fmt.Errorf("返回的数量[%v]与发送的数量[%v]不相同", len(ret.Items), len(items))}
+	}
+
+	for i, item := range ret.Items {
+		for _, result := range item { // 只有一个元素，键名为操作类型
+			switch {
+			case result.Status < 300:
+			case result.Status >= 500 || result.Status == http.StatusTooManyRequests:
+				failed = append(failed, items[i])
+			default:
+				rejected++
+			}
+		}
+	}
+
+	return failed, rejected, nil
+}
+
+// 将 index 中的 %{layout} 替换成按 layout 格式化的时间
+func (e *Elasticsearch) indexName(t time.Time) string {
+	index := e.index
+	buf := new(strings.Builder)
+	for {
+		start := strings.Index(index, "%{")
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(index[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		buf.WriteString(index[:start])
+		buf.WriteString(t.Format(index[start+2 : end]))
+		index = index[end+1:]
+	}
+	buf.WriteString(index)
+
+	return buf.String()
+}
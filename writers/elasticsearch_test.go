@@ -0,0 +1,190 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Leveler        = &Elasticsearch{}
+	_ Flusher        = &Elasticsearch{}
+	_ io.WriteCloser = &Elasticsearch{}
+)
+
+// 模拟 _bulk 接口的服务端
+type esServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	auth     []string
+	requests [][]map[string]interface{} // 每次请求中的文档
+	indexes  []string
+	status   [][]int // 每次请求中各文档的状态码，为 nil 表示全部成功
+	code     []int   // 每次请求的状态码
+}
+
+func newESServer() *esServer {
+	s := &esServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.auth = append(s.auth, r.Header.Get("Authorization"))
+
+		docs := []map[string]interface{}{}
+		scanner := bufio.NewScanner(r.Body)
+		for i := 0; scanner.Scan(); i++ {
+			m := map[string]interface{}{}
+			json.Unmarshal(scanner.Bytes(), &m)
+			if i%2 == 0 {
+				s.indexes = append(s.indexes, m["index"].(map[string]interface{})["_index"].(string))
+			} else {
+				docs = append(docs, m)
+			}
+		}
+		s.requests = append(s.requests, docs)
+
+		if len(s.code) > 0 {
+			code := s.code[0]
+			s.code = s.code[1:]
+			if code != http.StatusOK {
+				w.WriteHeader(code)
+				return
+			}
+		}
+
+		var status []int
+		if len(s.status) > 0 {
+			status = s.status[0]
+			s.status = s.status[1:]
+		}
+
+		items := make([]string, 0, len(docs))
+		for i := range docs {
+			code := 201
+			if status != nil {
+				code = status[i]
+			}
+			items = append(items, fmt.Sprintf(`{"index":{"status":%d}}`, code))
+		}
+		fmt.Fprintf(w, `{"errors":%v,"items":[%s]}`, status != nil, strings.Join(items, ","))
+	}))
+	return s
+}
+
+func (s *esServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func TestElasticsearch_Write(t *testing.T) {
+	a := assert.New(t)
+	srv := newESServer()
+	defer srv.Close()
+
+	e := NewElasticsearch(srv.URL+"/", "app-%{2006}")
+	a.NotError(e.SetBatch(10, time.Hour)).Error(e.SetBatch(0, 0))
+	a.Error(e.SetQueue(0, 0, 0))
+	e.SetAPIKey("key")
+	e.SetLevel("info", "[INFO]", 0)
+
+	size, err := e.Write([]byte("[INFO]m1\n"))
+	a.NotError(err).Equal(size, len("[INFO]m1\n"))
+	e.Write([]byte("[INFO]m2\n"))
+	e.Flush()
+
+	a.Equal(srv.count(), 1)
+	docs := srv.requests[0]
+	a.Equal(len(docs), 2).
+		Equal(docs[0]["message"], "m1").
+		Equal(docs[0]["level"], "info").
+		Equal(docs[1]["message"], "m2").
+		NotNil(docs[0]["@timestamp"])
+	a.Equal(srv.indexes[0], "app-"+time.Now().UTC().Format("2006")).
+		Equal(srv.auth[0], "ApiKey key")
+
+	a.NotError(e.Close()).NotError(e.Close())
+	a.Equal(e.Dropped(), 0)
+}
+
+func TestElasticsearch_indexName(t *testing.T) {
+	a := assert.New(t)
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	a.Equal(NewElasticsearch("", "logs-1").indexName(now), "logs-1")
+	a.Equal(NewElasticsearch("", "app2-%{2006.01}").indexName(now), "app2-2021.03")
+	a.Equal(NewElasticsearch("", "v15-%{2006}-%{01.02}").indexName(now), "v15-2021-03.04")
+	a.Equal(NewElasticsearch("", "app-%{2006").indexName(now), "app-%{2006")
+}
+
+// 只重试失败的日志
+func TestElasticsearch_retry(t *testing.T) {
+	a := assert.New(t)
+	srv := newESServer()
+	defer srv.Close()
+
+	e := NewElasticsearch(srv.URL, "app")
+	a.NotError(e.SetQueue(10, 2, time.Millisecond))
+	e.SetBasicAuth("user", "pwd")
+
+	// 第二条返回 429 需要重试，第三条返回 400 直接丢弃
+	srv.mu.Lock()
+	srv.status = [][]int{{201, 429, 400}}
+	srv.mu.Unlock()
+	e.Write([]byte("m1\n"))
+	e.Write([]byte("m2\n"))
+	e.Write([]byte("m3\n"))
+	e.Flush()
+
+	a.Equal(srv.count(), 2)
+	a.Equal(len(srv.requests[1]), 1).
+		Equal(srv.requests[1][0]["message"], "m2").
+		True(strings.HasPrefix(srv.auth[0], "Basic "))
+	a.Equal(e.Dropped(), 1)
+
+	// 整个请求返回 503 之后重试成功
+	srv.mu.Lock()
+	srv.code = []int{http.StatusServiceUnavailable}
+	srv.mu.Unlock()
+	e.Write([]byte("m4\n"))
+	e.Flush()
+	a.Equal(srv.count(), 4).Equal(e.Dropped(), 1)
+
+	// 400 不重试
+	srv.mu.Lock()
+	srv.code = []int{http.StatusBadRequest}
+	srv.mu.Unlock()
+	e.Write([]byte("m5\n"))
+	e.Flush()
+	a.Equal(srv.count(), 5).Equal(e.Dropped(), 2)
+
+	// 重试次数用完
+	srv.mu.Lock()
+	srv.status = [][]int{{500}, {500}, {500}}
+	srv.mu.Unlock()
+	e.Write([]byte("m6\n"))
+	e.Flush()
+	a.Equal(srv.count(), 8).Equal(e.Dropped(), 3)
+
+	a.NotError(e.Close())
+	a.Equal(e.Dropped(), 3)
+}
@@ -0,0 +1,343 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Exec 的默认配置
+const (
+	defaultExecTimeout    = 10 * time.Second
+	defaultExecBackoff    = 500 * time.Millisecond
+	defaultExecMaxBackoff = time.Minute
+	defaultExecBuffer     = 1 << 20 // 进程重启期间最多缓存 1M 的内容
+	defaultExecStderr     = 4 << 10 // 保留最后 4K 的 stderr 输出
+)
+
+// 将日志写入外部程序的标准输入，比如 logger 或是 sh -c "gzip > file"。
+//
+// 在第一次写入时才会启动进程，进程退出之后按 backoff 的倍数递增等待时间再重新启动，
+// 在等待期间写入的内容会被缓存，进程启动之后再依次写入；
+// 缓存超出大小时丢弃最早的内容，丢弃的数量可以通过 Dropped() 获取；
+// 写入失败时已经写入了一部分的日志也会被丢弃，不会在重启之后重复写入。
+// 进程的 stderr 会保留最后的一部分，可以通过 Stderr() 获取，用于排查问题。
+type Exec struct {
+	name    string
+	args    []string
+	dir     string
+	env     []string
+	timeout time.Duration
+	stderr  *execStderr
+	stderrW io.Writer // 同时输出 stderr 的内容，可以为空
+
+	mu      sync.Mutex
+	proc    *execProcess
+	backlog backlog // 进程不可用期间缓存的内容
+	err     error   // 最后一次启动失败或是退出时的错误
+}
+
+// 运行中的进程
+type execProcess struct {
+	cmd   *exec.Cmd
+	stdin *os.File
+	start time.Time
+	done  chan struct{} // 进程退出之后关闭
+	err   error         // cmd.Wait() 的返回值，done 关闭之后才可读取
+}
+
+// 保留最后 size 字节的 stderr 输出
+type execStderr struct {
+	mu   sync.Mutex
+	size int
+	buf  []byte
+}
+
+// 新建 Exec 实例。
+// name 为需要执行的程序，不包含路径时会从 PATH 中查找；args 为传递给程序的参数。
+func NewExec(name string, args ...string) (*Exec, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, err
+	}
+
+	return &Exec{
+		name:    name,
+		args:    args,
+		timeout: defaultExecTimeout,
+		stderr:  &execStderr{size: defaultExecStderr},
+		backlog: backlog{
+			bufSize:    defaultExecBuffer,
+			backoff:    defaultExecBackoff,
+			maxBackoff: defaultExecMaxBackoff,
+		},
+	}, nil
+}
+
+// 设置进程的工作目录，默认为当前目录。
+func (e *Exec) SetDir(dir string) {
+	e.dir = dir
+}
+
+// 设置额外的环境变量，格式为 key=value，会追加在当前进程的环境变量之后。
+func (e *Exec) SetEnv(env []string) {
+	e.env = env
+}
+
+// 设置写入的超时时间以及关闭时等待进程退出的时间，默认为 10 秒。
+// 写入超时的进程会被认为已经失去响应，将被强制结束并重新启动。
+func (e *Exec) SetTimeout(timeout time.Duration) {
+	e.timeout = timeout
+}
+
+// 设置重启的等待时间。
+// 第一次退出之后等待 backoff，之后每次翻倍，最多不超过 max。
+// 进程运行超过 max 之后才退出的，重新从 backoff 开始计算。
+func (e *Exec) SetBackoff(backoff, max time.Duration) {
+	e.backlog.backoff = backoff
+	e.backlog.maxBackoff = max
+}
+
+// 设置进程不可用期间最多缓存的字节数，为 0 表示不缓存。
+func (e *Exec) SetBuffer(size int) {
+	e.backlog.bufSize = size
+}
+
+// 将进程的 stderr 同时输出到 w，只能在第一次调用 Write 之前设置。
+func (e *Exec) SetStderr(w io.Writer) {
+	e.stderrW = w
+}
+
+// 进程最近输出到 stderr 的内容
+func (e *Exec) Stderr() string {
+	return e.stderr.String()
+}
+
+// 最后一次启动失败或是进程退出时的错误，正常退出时为 nil。
+func (e *Exec) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.err
+}
+
+// 因为缓存已满或是只写入了一部分而被丢弃的日志数量
+func (e *Exec) Dropped() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.backlog.dropCnt
+}
+
+// io.Writer
+//
+// 进程不可用时，内容会被缓存，并不会返回错误。
+func (e *Exec) Write(msg []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.backlog.push(msg)
+	if e.proc != nil || e.backlog.ready() {
+		e.send()
+	}
+	e.backlog.trim()
+
+	return len(msg), nil
+}
+
+// Flusher.Flush()
+// 忽略重启的等待时间，立即写入缓存的内容。
+func (e *Exec) Flush() (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	size := e.backlog.size
+	if err := e.send(); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// io.Closer.Close()
+// 尽量写入缓存的内容之后关闭进程的标准输入，并等待进程退出；
+// 超过 timeout 依然没有退出的，会被强制结束。
+func (e *Exec) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.backlog.items) > 0 {
+		e.send()
+	}
+
+	p := e.proc
+	if p == nil {
+		return nil
+	}
+	e.proc = nil
+
+	p.stdin.Close()
+
+	var timer <-chan time.Time
+	if e.timeout > 0 {
+		t := time.NewTimer(e.timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	select {
+	case <-p.done:
+		return p.err
+	case <-timer:
+		p.cmd.Process.Kill()
+		<-p.done
+		return errors.New("等待进程退出超时，已强制结束")
+	}
+}
+
+// 依次写入缓存的内容，需要在锁中调用。
+func (e *Exec) send() error {
+	if e.proc == nil {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	err := e.backlog.flush(func(data []byte) (int, error) {
+		if e.timeout > 0 {
+			e.proc.stdin.SetWriteDeadline(time.Now().Add(e.timeout))
+		}
+		return e.proc.stdin.Write(data)
+	})
+	if err != nil {
+		e.stop()
+	}
+	return err
+}
+
+// 启动进程，需要在锁中调用。
+func (e *Exec) start() error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		e.fail(err)
+		return err
+	}
+
+	cmd := exec.Command(e.name, e.args...)
+	cmd.Dir = e.dir
+	if len(e.env) > 0 {
+		cmd.Env = append(os.Environ(), e.env...)
+	}
+	cmd.Stdin = r
+
+	// stderr 由单独的 goroutine 读取，这样 cmd.Wait() 不会因为
+	// 子进程派生的进程依然持有 stderr 而一直等待。
+	er, ew, err := os.Pipe()
+	if err != nil {
+		r.Close()
+		w.Close()
+		e.fail(err)
+		return err
+	}
+	cmd.Stderr = ew
+
+	err = cmd.Start()
+	r.Close() // 子进程已经持有了各自的副本
+	ew.Close()
+	if err != nil {
+		w.Close()
+		er.Close()
+		e.fail(err)
+		return err
+	}
+
+	var stderr io.Writer = e.stderr
+	if e.stderrW != nil {
+		stderr = io.MultiWriter(e.stderrW, e.stderr)
+	}
+	go func() {
+		io.Copy(stderr, er)
+		er.Close()
+	}()
+
+	p := &execProcess{
+		cmd:   cmd,
+		stdin: w,
+		start: time.Now(),
+		done:  make(chan struct{}),
+	}
+	e.proc = p
+	go e.wait(p)
+
+	return nil
+}
+
+// 等待进程退出，并安排下一次启动的时间。
+func (e *Exec) wait(p *execProcess) {
+	p.err = p.cmd.Wait()
+	close(p.done)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.proc != p { // 由 Close() 或是 stop() 主动结束的
+		return
+	}
+
+	e.proc = nil
+	p.stdin.Close()
+	e.exited(p)
+}
+
+// 强制结束失去响应的进程，需要在锁中调用。
+func (e *Exec) stop() {
+	p := e.proc
+	e.proc = nil
+
+	p.stdin.Close()
+	p.cmd.Process.Kill()
+	<-p.done
+	e.exited(p)
+}
+
+// 记录进程退出的状态，需要在锁中调用。
+func (e *Exec) exited(p *execProcess) {
+	if time.Since(p.start) >= e.backlog.maxBackoff {
+		e.backlog.failures = 0
+	}
+	e.fail(p.err)
+}
+
+// 记录错误并计算下一次启动的时间，需要在锁中调用。
+func (e *Exec) fail(err error) {
+	e.err = err
+	e.backlog.fail()
+}
+
+func (s *execStderr) Write(data []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := len(data)
+	if size >= s.size {
+		data = data[size-s.size:]
+		s.buf = s.buf[:0]
+	} else if len(s.buf)+size > s.size {
+		s.buf = s.buf[len(s.buf)+size-s.size:]
+	}
+	s.buf = append(s.buf, data...)
+
+	return size, nil
+}
+
+func (s *execStderr) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return string(s.buf)
+}
@@ -0,0 +1,152 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var _ Flusher = &Exec{}
+
+// 新建一个执行 sh -c script 的 Exec 实例，没有 sh 的平台跳过测试。
+func newTestExec(t *testing.T, script string) *Exec {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("找不到 sh")
+	}
+
+	e, err := NewExec("sh", "-c", script)
+	assert.New(t).NotError(err).NotNil(e)
+	return e
+}
+
+// 等待 f 返回 true，最多等待 1 秒。
+func waitFor(f func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if f() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestNewExec(t *testing.T) {
+	a := assert.New(t)
+
+	e, err := NewExec("logs-not-exists-command")
+	a.Error(err).Nil(e)
+}
+
+func TestExec(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "logs-exec")
+	a.NotError(err)
+	defer os.RemoveAll(dir)
+
+	e := newTestExec(t, `cat > "$LOGS_FILE"; echo done >&2`)
+	e.SetEnv([]string{"LOGS_FILE=out.log"})
+	e.SetDir(dir)
+	stderr := new(bytes.Buffer)
+	e.SetStderr(stderr)
+
+	e.Write([]byte("line1\n"))
+	e.Write([]byte("line2\n"))
+	a.NotError(e.Close())
+	a.NotError(e.Err())
+	a.Equal(e.Dropped(), uint64(0))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "out.log"))
+	a.NotError(err)
+	a.Equal(string(data), "line1\nline2\n")
+
+	a.True(waitFor(func() bool { return e.Stderr() == "done\n" }))
+	a.Equal(stderr.String(), "done\n")
+
+	// 未启动的进程
+	a.NotError(e.Close())
+}
+
+func TestExec_restart(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "logs-exec")
+	a.NotError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.log")
+
+	// 每个进程只读取一行内容，之后以错误状态退出
+	e := newTestExec(t, `read line; echo "$line" >> "`+path+`"; echo "exit $line" >&2; exit 3`)
+	e.SetBackoff(time.Millisecond, 10*time.Millisecond)
+
+	e.Write([]byte("1\n"))
+	a.True(waitFor(func() bool { return e.Err() != nil }))
+	a.True(waitFor(func() bool { return strings.Contains(e.Stderr(), "exit 1") }))
+
+	time.Sleep(20 * time.Millisecond)
+	e.Write([]byte("2\n"))
+	a.True(waitFor(func() bool { return strings.Contains(e.Stderr(), "exit 2") }))
+
+	data, err := ioutil.ReadFile(path)
+	a.NotError(err)
+	a.Equal(string(data), "1\n2\n")
+	a.NotError(e.Close())
+}
+
+func TestExec_buffer(t *testing.T) {
+	a := assert.New(t)
+
+	e := newTestExec(t, "read line; exit 1")
+	e.SetBackoff(time.Hour, time.Hour)
+	e.SetBuffer(4)
+
+	e.Write([]byte("1\n"))
+	a.True(waitFor(func() bool { return e.Err() != nil }))
+
+	// 等待重启期间缓存内容，超出大小时丢弃最早的内容
+	e.Write([]byte("2\n"))
+	e.Write([]byte("3\n"))
+	e.Write([]byte("4\n"))
+	a.Equal(e.Dropped(), uint64(1))
+
+	e.mu.Lock()
+	a.Equal(e.backlog.items, [][]byte{[]byte("3\n"), []byte("4\n")})
+	a.Nil(e.proc)
+	e.mu.Unlock()
+}
+
+func TestExec_Close(t *testing.T) {
+	a := assert.New(t)
+
+	// 不读取标准输入，关闭时只能强制结束
+	e := newTestExec(t, "sleep 10")
+	e.SetTimeout(50 * time.Millisecond)
+	e.Write([]byte("1\n"))
+
+	start := time.Now()
+	a.Error(e.Close())
+	a.True(time.Since(start) < 5*time.Second)
+}
+
+func TestExecStderr(t *testing.T) {
+	a := assert.New(t)
+	s := &execStderr{size: 4}
+
+	s.Write([]byte("ab"))
+	a.Equal(s.String(), "ab")
+	s.Write([]byte("cde"))
+	a.Equal(s.String(), "bcde")
+	s.Write([]byte("123456"))
+	a.Equal(s.String(), "3456")
+}
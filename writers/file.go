@@ -0,0 +1,224 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File 新建文件和目录的默认权限
+const (
+	defaultFileMode    os.FileMode = 0640
+	defaultFileDirMode os.FileMode = 0750
+)
+
+// 新建文件和目录时的选项，由 File 和 Rotate 共用。
+type fileOptions struct {
+	mode     os.FileMode
+	dirMode  os.FileMode
+	chmod    bool // 是否指定了文件的权限，未指定时受 umask 的影响
+	chmodDir bool // 是否指定了目录的权限
+	uid      int  // 为 -1 表示不修改
+	gid      int
+	sync     bool
+	noFollow bool
+}
+
+// 将日志追加到指定的文件，文件或是所在的目录不存在时会被创建，
+// 新建的文件和目录的权限默认为 0640 和 0750，不受 umask 的影响。
+//
+// 在第一次写入时才会打开文件，Close 之后再次写入会重新打开，
+// 可以配合 logrotate 等外部工具使用。
+type File struct {
+	fileOptions
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+func newFileOptions() fileOptions {
+	return fileOptions{
+		mode:    defaultMode,
+		dirMode: defaultMode,
+		uid:     -1,
+		gid:     -1,
+	}
+}
+
+// 设置新建文件的权限，指定之后不再受 umask 的影响。
+// File 默认为 0640；Rotate 默认为 os.ModePerm 且受 umask 的影响。
+func (o *fileOptions) SetMode(mode os.FileMode) error {
+	if mode&^os.ModePerm != 0 {
+		return fmt.Errorf("无效的mode值:[%v]", mode)
+	}
+
+	o.mode = mode
+	o.chmod = true
+	return nil
+}
+
+// 设置新建目录的权限，指定之后不再受 umask 的影响。
+// File 默认为 0750；Rotate 默认为 os.ModePerm 且受 umask 的影响。
+func (o *fileOptions) SetDirMode(mode os.FileMode) error {
+	if mode&^os.ModePerm != 0 {
+		return fmt.Errorf("无效的mode值:[%v]", mode)
+	}
+
+	o.dirMode = mode
+	o.chmodDir = true
+	return nil
+}
+
+// 设置新建文件和目录的所有者，为 -1 表示不修改，windows 下不支持。
+// 一般只有以 root 运行时才有权限修改。
+func (o *fileOptions) SetOwner(uid, gid int) error {
+	if uid < -1 {
+		return fmt.Errorf("无效的uid值:[%v]", uid)
+	}
+	if gid < -1 {
+		return fmt.Errorf("无效的gid值:[%v]", gid)
+	}
+	if !fileOwnerSupported && (uid != -1 || gid != -1) {
+		return errors.New("当前平台不支持设置文件的所有者")
+	}
+
+	o.uid = uid
+	o.gid = gid
+	return nil
+}
+
+// 是否以 O_SYNC 打开文件，每次写入都会等待内容写入磁盘。
+func (o *fileOptions) SetSync(sync bool) {
+	o.sync = sync
+}
+
+// 是否以 O_NOFOLLOW 打开文件，文件为符号链接时将打开失败，windows 下无效。
+func (o *fileOptions) SetNoFollow(noFollow bool) {
+	o.noFollow = noFollow
+}
+
+// 创建目录，已经存在的目录不作任何修改。
+func (o *fileOptions) mkdir(dir string) error {
+	_, err := os.Stat(dir)
+	if err == nil || !os.IsNotExist(err) {
+		return err
+	}
+
+	if err = os.MkdirAll(dir, o.dirMode); err != nil {
+		return err
+	}
+
+	// MkdirAll 的权限会受 umask 的影响
+	if o.chmodDir {
+		if err = os.Chmod(dir, o.dirMode); err != nil {
+			return err
+		}
+	}
+
+	if o.uid != -1 || o.gid != -1 {
+		return os.Chown(dir, o.uid, o.gid)
+	}
+	return nil
+}
+
+// 以追加的方式打开文件，只有新建的文件才会设置权限和所有者。
+func (o *fileOptions) open(path string) (*os.File, error) {
+	flag := defaultFlag &^ os.O_CREATE
+	if o.sync {
+		flag |= os.O_SYNC
+	}
+	if o.noFollow {
+		flag |= fileNoFollowFlag
+	}
+
+	f, err := os.OpenFile(path, flag, o.mode)
+	if err == nil || !os.IsNotExist(err) {
+		return f, err
+	}
+
+	f, err = os.OpenFile(path, flag|os.O_CREATE|os.O_EXCL, o.mode)
+	if os.IsExist(err) { // 被其它进程抢先创建
+		return os.OpenFile(path, flag, o.mode)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// OpenFile 的权限会受 umask 的影响
+	if o.chmod {
+		err = f.Chmod(o.mode)
+	}
+	if err == nil && (o.uid != -1 || o.gid != -1) {
+		err = f.Chown(o.uid, o.gid)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// 新建 File 实例。
+func NewFile(path string) *File {
+	o := newFileOptions()
+	o.mode, o.chmod = defaultFileMode, true
+	o.dirMode, o.chmodDir = defaultFileDirMode, true
+
+	return &File{
+		fileOptions: o,
+		path:        path,
+	}
+}
+
+// io.Writer
+func (f *File) Write(buf []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.f == nil {
+		if err := f.mkdir(filepath.Dir(f.path)); err != nil {
+			return 0, err
+		}
+
+		file, err := f.open(f.path)
+		if err != nil {
+			return 0, err
+		}
+		f.f = file
+	}
+
+	return f.f.Write(buf)
+}
+
+// Flusher.Flush()
+// 将内容同步到磁盘。
+func (f *File) Flush() (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.f == nil {
+		return 0, nil
+	}
+	return 0, f.f.Sync()
+}
+
+// io.Closer.Close()
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.f == nil {
+		return nil
+	}
+
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
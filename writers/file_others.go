@@ -0,0 +1,14 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package writers
+
+// windows 等平台不支持 O_NOFOLLOW，也无法修改文件的所有者。
+const (
+	fileNoFollowFlag   = 0
+	fileOwnerSupported = false
+)
@@ -0,0 +1,146 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Flusher        = &File{}
+	_ io.WriteCloser = &File{}
+)
+
+func TestFileOptions(t *testing.T) {
+	a := assert.New(t)
+	o := newFileOptions()
+	a.Equal(o.mode, os.ModePerm).
+		Equal(o.dirMode, os.ModePerm).
+		False(o.chmod).
+		False(o.chmodDir).
+		Equal(o.uid, -1).
+		Equal(o.gid, -1)
+
+	a.Error(o.SetMode(os.ModeDir | 0600)).NotError(o.SetMode(0600))
+	a.Error(o.SetDirMode(os.ModeSymlink | 0700)).NotError(o.SetDirMode(0700))
+	a.Equal(o.mode, os.FileMode(0600)).Equal(o.dirMode, os.FileMode(0700))
+	a.True(o.chmod).True(o.chmodDir)
+
+	// File 默认使用 0640 和 0750
+	f := NewFile("a.log")
+	a.Equal(f.mode, os.FileMode(0640)).
+		Equal(f.dirMode, os.FileMode(0750)).
+		True(f.chmod).
+		True(f.chmodDir)
+
+	a.Error(o.SetOwner(-2, -1)).Error(o.SetOwner(-1, -2))
+	a.NotError(o.SetOwner(-1, -1))
+	if fileOwnerSupported {
+		a.NotError(o.SetOwner(os.Getuid(), os.Getgid()))
+	} else {
+		a.Error(o.SetOwner(0, 0))
+	}
+}
+
+func TestFile(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "logs-file")
+	a.NotError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sub", "a.log")
+
+	f := NewFile(path)
+	a.NotError(f.SetMode(0600)).NotError(f.SetDirMode(0700))
+	if fileOwnerSupported {
+		a.NotError(f.SetOwner(os.Getuid(), os.Getgid()))
+	}
+	f.SetSync(true)
+
+	_, err = f.Write([]byte("line1\n"))
+	a.NotError(err)
+	_, err = f.Flush()
+	a.NotError(err)
+	a.NotError(f.Close())
+
+	// 关闭之后再次写入，会追加到原来的文件
+	_, err = f.Write([]byte("line2\n"))
+	a.NotError(err)
+	a.NotError(f.Close()).NotError(f.Close())
+
+	data, err := ioutil.ReadFile(path)
+	a.NotError(err)
+	a.Equal(string(data), "line1\nline2\n")
+
+	if runtime.GOOS != "windows" {
+		stat, err := os.Stat(path)
+		a.NotError(err)
+		a.Equal(stat.Mode().Perm(), os.FileMode(0600))
+
+		stat, err = os.Stat(filepath.Dir(path))
+		a.NotError(err)
+		a.Equal(stat.Mode().Perm(), os.FileMode(0700))
+
+		// 已经存在的文件不修改权限
+		exists := filepath.Join(dir, "exists.log")
+		a.NotError(ioutil.WriteFile(exists, nil, 0604))
+		a.NotError(os.Chmod(exists, 0604))
+		f = NewFile(exists)
+		_, err = f.Write([]byte("line\n"))
+		a.NotError(err)
+		a.NotError(f.Close())
+		stat, err = os.Stat(exists)
+		a.NotError(err)
+		a.Equal(stat.Mode().Perm(), os.FileMode(0604))
+
+		// 默认的权限不受 umask 的影响
+		path = filepath.Join(dir, "default", "a.log")
+		f = NewFile(path)
+		_, err = f.Write([]byte("line\n"))
+		a.NotError(err)
+		a.NotError(f.Close())
+		stat, err = os.Stat(path)
+		a.NotError(err)
+		a.Equal(stat.Mode().Perm(), os.FileMode(0640))
+		stat, err = os.Stat(filepath.Dir(path))
+		a.NotError(err)
+		a.Equal(stat.Mode().Perm(), os.FileMode(0750))
+	}
+}
+
+func TestFile_SetNoFollow(t *testing.T) {
+	if fileNoFollowFlag == 0 {
+		t.Skip("当前平台不支持 O_NOFOLLOW")
+	}
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "logs-file")
+	a.NotError(err)
+	defer os.RemoveAll(dir)
+	target := filepath.Join(dir, "target.log")
+	link := filepath.Join(dir, "link.log")
+	a.NotError(ioutil.WriteFile(target, nil, 0600))
+	a.NotError(os.Symlink(target, link))
+
+	f := NewFile(link)
+	_, err = f.Write([]byte("line\n"))
+	a.NotError(err)
+	a.NotError(f.Close())
+
+	f.SetNoFollow(true)
+	_, err = f.Write([]byte("line\n"))
+	a.Error(err)
+
+	data, err := ioutil.ReadFile(target)
+	a.NotError(err)
+	a.Equal(string(data), "line\n")
+}
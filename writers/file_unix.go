@@ -0,0 +1,15 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package writers
+
+import "syscall"
+
+const (
+	fileNoFollowFlag   = syscall.O_NOFOLLOW
+	fileOwnerSupported = true
+)
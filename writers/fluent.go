@@ -0,0 +1,232 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+const defaultFluentTimeout = 10 * time.Second
+
+// 通过 Forward 协议将日志发送到 Fluentd 或是 Fluent Bit。
+//
+// Write 只是将日志放入队列，由后台的 goroutine 以 PackedForward
+// 格式合并发送。启用 ack 之后，会等待服务端确认每一批日志，
+// 发送或是确认失败时会重新连接并按 backoff 进行重试。
+type Fluent struct {
+	network string
+	addr    string
+	tag     string
+	ack     bool
+	fields  map[string]string
+	timeout time.Duration
+
+	batchWriter
+	conn net.Conn // 仅在后台的 goroutine 中使用
+}
+
+// 新建 Fluent 实例。
+// network 可以是 tcp、tcp4、tcp6 和 unix；tag 为日志的标签，比如 app.logs。
+func NewFluent(network, addr, tag string) (*Fluent, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, fmt.Errorf("无效的network值:[%v]", network)
+	}
+
+	if tag == "" {
+		return nil, fmt.Errorf("无效的tag值:[%v]", tag)
+	}
+
+	f := &Fluent{
+		network: network,
+		addr:    addr,
+		tag:     tag,
+		fields:  make(map[string]string),
+		timeout: defaultFluentTimeout,
+	}
+	f.batchWriter = newBatchWriter(f.send)
+	return f, nil
+}
+
+// 是否需要服务端确认，即 Forward 协议中的 chunk 选项。
+func (f *Fluent) SetAck(ack bool) {
+	f.ack = ack
+}
+
+// 添加一个自定义字段，每条日志都会带上该字段。
+func (f *Fluent) SetField(name, value string) error {
+	switch name {
+	case "", "message", "level", "caller":
+		return fmt.Errorf("无效的字段名:[%v]", name)
+	}
+
+	f.fields[name] = value
+	return nil
+}
+
+// 设置连接、写入以及等待确认的超时时间，默认为 10 秒。
+func (f *Fluent) SetTimeout(timeout time.Duration) {
+	f.timeout = timeout
+}
+
+// io.Writer
+func (f *Fluent) Write(msg []byte) (int, error) {
+	r := parseRecord(msg, f.prefix, f.flag)
+	t, ok := r.parseTime()
+	if !ok {
+		t = time.Now()
+	}
+
+	record := make(map[string]string, len(f.fields)+3)
+	for name, value := range f.fields {
+		record[name] = value
+	}
+	record["message"] = string(r.bytes(r.message))
+	if f.level != "" {
+		record["level"] = f.level
+	}
+	if !r.caller.empty() {
+		record["caller"] = string(r.bytes(r.caller))
+	}
+
+	f.push(fluentEntry(t, record))
+	return len(msg), nil
+}
+
+// io.Closer.Close()
+// 发送完队列中的日志之后关闭连接，之后可以通过 Write 重新启动。
+func (f *Fluent) Close() error {
+	f.batchWriter.Close()
+
+	// 后台的 goroutine 已经退出，可以直接访问 conn
+	if f.conn == nil {
+		return nil
+	}
+	err := f.conn.Close()
+	f.conn = nil
+	return err
+}
+
+// 编码一条 [time, record] 格式的日志，time 为 EventTime 扩展类型。
+func fluentEntry(t time.Time, record map[string]string) []byte {
+	names := make([]string, 0, len(record))
+	for name := range record {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint32(ts, uint32(t.Unix()))
+	binary.BigEndian.PutUint32(ts[4:], uint32(t.Nanosecond()))
+
+	e := &mpEncoder{}
+	e.array(2)
+	e.ext(0, ts)
+	e.mapHeader(len(names))
+	for _, name := range names {
+		e.string(name)
+		e.string(record[name])
+	}
+	return e.buf
+}
+
+// 以 PackedForward 格式发送一批日志：
+//
+//	[tag, bin(entries), {"size": n, "chunk": id}]
+func (f *Fluent) send(items [][]byte) error {
+	size := 0
+	for _, item := range items {
+		size += len(item)
+	}
+	entries := make([]byte, 0, size)
+	for _, item := range items {
+		entries = append(entries, item...)
+	}
+
+	var chunk string
+	if f.ack {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		chunk = base64.StdEncoding.EncodeToString(id)
+	}
+
+	e := &mpEncoder{}
+	e.array(3)
+	e.string(f.tag)
+	e.bin(entries)
+	if f.ack {
+		e.mapHeader(2)
+		e.string("chunk")
+		e.string(chunk)
+	} else {
+		e.mapHeader(1)
+	}
+	e.string("size")
+	e.uint(uint64(len(items)))
+
+	if err := f.write(e.buf, chunk); err != nil {
+		if f.conn != nil {
+			f.conn.Close()
+			f.conn = nil
+		}
+		return err
+	}
+	return nil
+}
+
+// 写入内容，chunk 不为空时等待服务端的确认。
+func (f *Fluent) write(data []byte, chunk string) error {
+	if f.conn == nil {
+		conn, err := net.DialTimeout(f.network, f.addr, f.timeout)
+		if err != nil {
+			return err
+		}
+		f.conn = conn
+	}
+
+	if f.timeout > 0 {
+		f.conn.SetDeadline(time.Now().Add(f.timeout))
+	}
+
+	if _, err := f.conn.Write(data); err != nil {
+		return err
+	}
+
+	if chunk == "" {
+		return nil
+	}
+
+	// 服务端返回 {"ack": chunk}
+	buf := make([]byte, 0, 64)
+	tmp := make([]byte, 64)
+	for {
+		n, err := f.conn.Read(tmp)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, tmp[:n]...)
+
+		v, _, err := mpDecode(buf)
+		if err == errMsgpackShort {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if m, ok := v.(map[string]interface{}); ok && m["ack"] == chunk {
+			return nil
+		}
+		return fmt.Errorf("无效的确认信息:[%v]", v)
+	}
+}
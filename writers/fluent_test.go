@@ -0,0 +1,201 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Leveler        = &Fluent{}
+	_ Flusher        = &Fluent{}
+	_ io.WriteCloser = &Fluent{}
+)
+
+// 模拟 Forward 协议的服务端
+type fluentServer struct {
+	l net.Listener
+
+	mu       sync.Mutex
+	messages []interface{}
+	acks     int // 需要忽略的 ack 数量，用于测试重试
+	conns    int
+}
+
+func newFluentServer(a *assert.Assertion) *fluentServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NotError(err)
+
+	s := &fluentServer{l: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fluentServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	buf := []byte{}
+	tmp := make([]byte, 1024)
+	for {
+		n, err := conn.Read(tmp)
+		if err != nil {
+			return
+		}
+		buf = append(buf, tmp[:n]...)
+
+		for {
+			v, rest, err := mpDecode(buf)
+			if err != nil {
+				break
+			}
+			buf = rest
+
+			s.mu.Lock()
+			s.messages = append(s.messages, v)
+			skip := s.acks > 0
+			if skip {
+				s.acks--
+			}
+			s.mu.Unlock()
+
+			option := v.([]interface{})[2].(map[string]interface{})
+			chunk, ok := option["chunk"]
+			if !ok {
+				continue
+			}
+			if skip { // 不返回 ack，直接断开连接
+				return
+			}
+
+			e := &mpEncoder{}
+			e.mapHeader(1)
+			e.string("ack")
+			e.string(chunk.(string))
+			conn.Write(e.buf)
+		}
+	}
+}
+
+func (s *fluentServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.messages)
+}
+
+// 解析 PackedForward 中的日志
+func decodeFluentEntries(a *assert.Assertion, v interface{}) (string, []map[string]interface{}) {
+	msg := v.([]interface{})
+	a.Equal(len(msg), 3)
+
+	entries := []map[string]interface{}{}
+	data := msg[1].([]byte)
+	for len(data) > 0 {
+		entry, rest, err := mpDecode(data)
+		a.NotError(err)
+		data = rest
+
+		arr := entry.([]interface{})
+		ext := arr[0].(*mpExt)
+		a.Equal(ext.typ, 0).Equal(len(ext.data), 8)
+		a.True(binary.BigEndian.Uint32(ext.data) > 0)
+		entries = append(entries, arr[1].(map[string]interface{}))
+	}
+
+	return msg[0].(string), entries
+}
+
+func TestNewFluent(t *testing.T) {
+	a := assert.New(t)
+
+	f, err := NewFluent("udp", "127.0.0.1:24224", "app")
+	a.Error(err).Nil(f)
+
+	f, err = NewFluent("tcp", "127.0.0.1:24224", "")
+	a.Error(err).Nil(f)
+
+	f, err = NewFluent("unix", "/var/run/fluent.sock", "app")
+	a.NotError(err).NotNil(f)
+	a.Error(f.SetField("message", "x")).NotError(f.SetField("app", "logs"))
+}
+
+func TestFluent_Write(t *testing.T) {
+	a := assert.New(t)
+	srv := newFluentServer(a)
+	defer srv.l.Close()
+
+	f, err := NewFluent("tcp", srv.l.Addr().String(), "app.logs")
+	a.NotError(err)
+	a.NotError(f.SetBatch(10, time.Hour))
+	a.NotError(f.SetField("app", "logs"))
+	f.SetLevel("info", "[INFO]", 0)
+
+	f.Write([]byte("[INFO]m1\n"))
+	f.Write([]byte("[INFO]m2\n"))
+	f.Flush()
+
+	// 没有 ack，需要等待服务端处理
+	for i := 0; i < 100 && srv.count() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.Equal(srv.count(), 1)
+
+	srv.mu.Lock()
+	msg := srv.messages[0]
+	srv.mu.Unlock()
+	tag, entries := decodeFluentEntries(a, msg)
+	a.Equal(tag, "app.logs").Equal(len(entries), 2)
+	a.Equal(entries[0], map[string]interface{}{"app": "logs", "level": "info", "message": "m1"}).
+		Equal(entries[1]["message"], "m2")
+	option := msg.([]interface{})[2].(map[string]interface{})
+	a.Equal(option["size"], 2)
+
+	a.NotError(f.Close()).NotError(f.Close())
+}
+
+// 没有收到 ack 时重新连接并重试
+func TestFluent_ack(t *testing.T) {
+	a := assert.New(t)
+	srv := newFluentServer(a)
+	defer srv.l.Close()
+
+	f, err := NewFluent("tcp", srv.l.Addr().String(), "app")
+	a.NotError(err)
+	f.SetAck(true)
+	a.NotError(f.SetQueue(10, 2, time.Millisecond))
+
+	srv.mu.Lock()
+	srv.acks = 1
+	srv.mu.Unlock()
+
+	f.Write([]byte("m1\n"))
+	f.Flush()
+
+	a.Equal(srv.count(), 2).Equal(f.Dropped(), 0)
+	srv.mu.Lock()
+	a.Equal(srv.conns, 2)
+	_, entries := decodeFluentEntries(a, srv.messages[1])
+	srv.mu.Unlock()
+	a.Equal(entries[0]["message"], "m1")
+
+	a.NotError(f.Close())
+}
@@ -0,0 +1,327 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GELF 在 UDP 中的压缩方式
+const (
+	GelfCompressNone = iota
+	GelfCompressGzip
+	GelfCompressZlib
+)
+
+const (
+	defaultGelfChunkSize = 1420
+	defaultGelfTimeout   = 10 * time.Second
+	gelfChunkHeaderSize  = 12  // 分块的报头：2 字节的魔数、8 字节的 ID、序号和总数
+	gelfMaxChunks        = 128 // 最多的分块数量
+)
+
+// 自定义字段的名称
+var gelfFieldName = regexp.MustCompile(`^[\w\.\-]+$`)
+
+// 以 GELF 1.1 格式将日志发送到 Graylog。
+//
+// UDP 可以使用 gzip 或 zlib 压缩，超过 chunkSize 的内容会被分块发送；
+// TCP 则以 \0 作为每条消息的结束符，且不支持压缩。
+type Gelf struct {
+	network   string
+	addr      string
+	stream    bool
+	host      string
+	fields    map[string]string
+	compress  int
+	chunkSize int
+	timeout   time.Duration
+	tlsConfig *tls.Config
+
+	level  int
+	prefix string
+	flag   int
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// 新建 Gelf 实例。
+// network 可以是 udp、udp4、udp6、tcp、tcp4 和 tcp6。
+func NewGelf(network, addr string) (*Gelf, error) {
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("无效的network值:[%v]", network)
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+
+	return &Gelf{
+		network:   network,
+		addr:      addr,
+		stream:    strings.HasPrefix(network, "tcp"),
+		host:      host,
+		fields:    make(map[string]string),
+		chunkSize: defaultGelfChunkSize,
+		timeout:   defaultGelfTimeout,
+		level:     SyslogInfo,
+	}, nil
+}
+
+// 设置 host 字段，默认为当前主机名。
+func (g *Gelf) SetHost(host string) {
+	g.host = host
+}
+
+// 添加一个自定义字段，name 不需要带下划线前缀。
+//
+// 字段名只能包含字母、数字、下划线、点和减号，且不能为 id。
+func (g *Gelf) SetField(name, value string) error {
+	if !gelfFieldName.MatchString(name) || name == "id" {
+		return fmt.Errorf("无效的字段名:[%v]", name)
+	}
+
+	g.fields["_"+name] = value
+	return nil
+}
+
+// 设置 UDP 的压缩方式，TCP 不支持压缩。
+func (g *Gelf) SetCompress(compress int) error {
+	switch compress {
+	case GelfCompressNone, GelfCompressGzip, GelfCompressZlib:
+	default:
+		return fmt.Errorf("无效的compress值:[%v]", compress)
+	}
+
+	if compress != GelfCompressNone && g.stream {
+		return fmt.Errorf("%v 不支持压缩", g.network)
+	}
+
+	g.compress = compress
+	return nil
+}
+
+// 设置 UDP 每个分块的最大字节数，默认为 1420。
+func (g *Gelf) SetChunkSize(size int) error {
+	if size <= gelfChunkHeaderSize {
+		return fmt.Errorf("无效的size值:[%v]", size)
+	}
+
+	g.chunkSize = size
+	return nil
+}
+
+// 设置连接和写入的超时时间，默认为 10 秒。
+func (g *Gelf) SetTimeout(timeout time.Duration) {
+	g.timeout = timeout
+}
+
+// 设置 TLS 配置，仅对 TCP 有效。
+func (g *Gelf) SetTLS(conf *tls.Config) {
+	g.tlsConfig = conf
+}
+
+// Leveler.SetLevel()
+// 根据日志级别确定 level 字段，与 syslog 的 severity 相同。
+func (g *Gelf) SetLevel(level, prefix string, flag int) {
+	severity, found := syslogSeverities[level]
+	if !found {
+		severity = SyslogInfo
+	}
+
+	g.level = severity
+	g.prefix = prefix
+	g.flag = flag
+}
+
+// io.Writer
+func (g *Gelf) Write(msg []byte) (int, error) {
+	data, err := g.message(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	if !g.stream {
+		if data, err = g.compressData(data); err != nil {
+			return 0, err
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for i := 0; i < 2; i++ { // 失败时重新连接一次
+		if g.conn == nil {
+			if err = g.connect(); err != nil {
+				continue
+			}
+		}
+
+		if g.timeout > 0 {
+			g.conn.SetWriteDeadline(time.Now().Add(g.timeout))
+		}
+		if err = g.send(data); err == nil {
+			return len(msg), nil
+		}
+
+		g.conn.Close()
+		g.conn = nil
+	}
+
+	return 0, err
+}
+
+// io.Closer.Close()
+func (g *Gelf) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.conn == nil {
+		return nil
+	}
+
+	err := g.conn.Close()
+	g.conn = nil
+	return err
+}
+
+func (g *Gelf) connect() (err error) {
+	dialer := &net.Dialer{Timeout: g.timeout}
+	if g.tlsConfig != nil && g.stream {
+		g.conn, err = tls.DialWithDialer(dialer, g.network, g.addr, g.tlsConfig)
+	} else {
+		g.conn, err = dialer.Dial(g.network, g.addr)
+	}
+
+	if err != nil {
+		g.conn = nil
+	}
+	return err
+}
+
+// 构造 GELF 格式的 JSON 内容。
+// 消息的第一行作为 short_message，多行时完整内容作为 full_message。
+func (g *Gelf) message(msg []byte) ([]byte, error) {
+	r := parseRecord(msg, g.prefix, g.flag)
+	t, ok := r.parseTime()
+	if !ok {
+		t = time.Now()
+	}
+
+	m := make(map[string]interface{}, len(g.fields)+8)
+	for name, value := range g.fields {
+		m[name] = value
+	}
+
+	message := string(r.bytes(r.message))
+	short := message
+	if index := strings.IndexByte(message, '\n'); index >= 0 {
+		short = message[:index]
+		m["full_message"] = message
+	}
+	if short == "" { // short_message 为必填项
+		short = "-"
+	}
+
+	m["version"] = "1.1"
+	m["host"] = g.host
+	m["short_message"] = short
+	m["timestamp"] = json.Number(fmt.Sprintf("%d.%03d", t.Unix(), t.Nanosecond()/int(time.Millisecond)))
+	m["level"] = g.level
+
+	if !r.caller.empty() {
+		caller := string(r.bytes(r.caller))
+		if index := strings.LastIndexByte(caller, ':'); index > 0 {
+			m["_file"] = caller[:index]
+			if line, err := strconv.Atoi(caller[index+1:]); err == nil {
+				m["_line"] = line
+			}
+		}
+	}
+
+	return json.Marshal(m)
+}
+
+func (g *Gelf) compressData(data []byte) ([]byte, error) {
+	var w io.WriteCloser
+	buf := new(bytes.Buffer)
+
+	switch g.compress {
+	case GelfCompressGzip:
+		w = gzip.NewWriter(buf)
+	case GelfCompressZlib:
+		w = zlib.NewWriter(buf)
+	default:
+		return data, nil
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 发送内容，需要在连接建立之后调用。
+func (g *Gelf) send(data []byte) error {
+	if g.stream {
+		_, err := g.conn.Write(append(data, 0))
+		return err
+	}
+
+	if len(data) <= g.chunkSize {
+		_, err := g.conn.Write(data)
+		return err
+	}
+
+	size := g.chunkSize - gelfChunkHeaderSize
+	count := (len(data) + size - 1) / size
+	if count > gelfMaxChunks {
+		return fmt.Errorf("内容过大，需要分成 %v 块", count)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, 0, g.chunkSize)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(data) {
+			end = len(data)
+		}
+
+		chunk = append(chunk[:0], 0x1e, 0x0f)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, data[i*size:end]...)
+		if _, err := g.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Leveler        = &Gelf{}
+	_ io.WriteCloser = &Gelf{}
+)
+
+func TestNewGelf(t *testing.T) {
+	a := assert.New(t)
+
+	g, err := NewGelf("unix", "/tmp/gelf")
+	a.Error(err).Nil(g)
+
+	g, err = NewGelf("tcp", "127.0.0.1:12201")
+	a.NotError(err).NotNil(g)
+	a.Error(g.SetCompress(GelfCompressGzip)).
+		NotError(g.SetCompress(GelfCompressNone)).
+		Error(g.SetField("id", "1")).
+		Error(g.SetField("a b", "1")).
+		NotError(g.SetField("app.name", "logs")).
+		Error(g.SetChunkSize(12))
+}
+
+func TestGelf_message(t *testing.T) {
+	a := assert.New(t)
+
+	g, err := NewGelf("udp", "127.0.0.1:12201")
+	a.NotError(err)
+	g.SetHost("example.com")
+	a.NotError(g.SetField("app", "logs"))
+	g.SetLevel("error", "[ERROR]", log.Lshortfile)
+
+	data, err := g.message([]byte("[ERROR]gelf.go:10: line1\nline2\n"))
+	a.NotError(err)
+
+	m := map[string]interface{}{}
+	a.NotError(json.Unmarshal(data, &m))
+	a.Equal(m["version"], "1.1").
+		Equal(m["host"], "example.com").
+		Equal(m["short_message"], "line1").
+		Equal(m["full_message"], "line1\nline2").
+		Equal(m["level"], 3).
+		Equal(m["_file"], "gelf.go").
+		Equal(m["_line"], 10).
+		Equal(m["_app"], "logs").
+		NotNil(m["timestamp"])
+}
+
+func readGelfUDP(a *assert.Assertion, conn net.PacketConn) []byte {
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	a.NotError(err)
+	return buf[:n]
+}
+
+func TestGelf_udp(t *testing.T) {
+	a := assert.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	a.NotError(err)
+	defer conn.Close()
+
+	g, err := NewGelf("udp", conn.LocalAddr().String())
+	a.NotError(err)
+
+	// gzip
+	a.NotError(g.SetCompress(GelfCompressGzip))
+	_, err = g.Write([]byte("gzip\n"))
+	a.NotError(err)
+	r, err := gzip.NewReader(bytes.NewReader(readGelfUDP(a, conn)))
+	a.NotError(err)
+	data, err := ioutil.ReadAll(r)
+	a.NotError(err)
+	a.True(strings.Contains(string(data), `"short_message":"gzip"`))
+
+	// zlib
+	a.NotError(g.SetCompress(GelfCompressZlib))
+	_, err = g.Write([]byte("zlib\n"))
+	a.NotError(err)
+	r2, err := zlib.NewReader(bytes.NewReader(readGelfUDP(a, conn)))
+	a.NotError(err)
+	data, err = ioutil.ReadAll(r2)
+	a.NotError(err)
+	a.True(strings.Contains(string(data), `"short_message":"zlib"`))
+
+	a.NotError(g.Close())
+}
+
+func TestGelf_chunk(t *testing.T) {
+	a := assert.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	a.NotError(err)
+	defer conn.Close()
+
+	g, err := NewGelf("udp", conn.LocalAddr().String())
+	a.NotError(err)
+	a.NotError(g.SetChunkSize(112))
+
+	msg := strings.Repeat("x", 250)
+	_, err = g.Write([]byte(msg + "\n"))
+	a.NotError(err)
+
+	var id []byte
+	var count int
+	payload := new(bytes.Buffer)
+	for i := 0; ; i++ {
+		chunk := readGelfUDP(a, conn)
+		a.True(len(chunk) <= 112).
+			Equal(chunk[0], 0x1e).
+			Equal(chunk[1], 0x0f).
+			Equal(int(chunk[10]), i)
+		if id == nil {
+			id = chunk[2:10]
+			count = int(chunk[11])
+		}
+		a.Equal(chunk[2:10], id).Equal(int(chunk[11]), count)
+		payload.Write(chunk[12:])
+
+		if i == count-1 {
+			break
+		}
+	}
+
+	m := map[string]interface{}{}
+	a.NotError(json.Unmarshal(payload.Bytes(), &m))
+	a.Equal(m["short_message"], msg)
+
+	// 超过最大的分块数量
+	_, err = g.Write([]byte(strings.Repeat("x", 100*gelfMaxChunks) + "\n"))
+	a.Error(err)
+
+	a.NotError(g.Close())
+}
+
+func TestGelf_tcp(t *testing.T) {
+	a := assert.New(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NotError(err)
+	defer l.Close()
+
+	g, err := NewGelf("tcp", l.Addr().String())
+	a.NotError(err)
+	_, err = g.Write([]byte("line1\n"))
+	a.NotError(err)
+	_, err = g.Write([]byte("line2\n"))
+	a.NotError(err)
+
+	conn, err := l.Accept()
+	a.NotError(err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"line1", "line2"} {
+		data, err := r.ReadBytes(0)
+		a.NotError(err)
+
+		m := map[string]interface{}{}
+		a.NotError(json.Unmarshal(data[:len(data)-1], &m))
+		a.Equal(m["short_message"], want)
+	}
+
+	a.NotError(g.Close())
+}
@@ -0,0 +1,245 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTP 请求的内容格式
+const (
+	HTTPFormatJSON   = iota // JSON 数组
+	HTTPFormatNDJSON        // 每行一条 JSON 记录
+)
+
+// HTTP 的默认配置
+const (
+	defaultHTTPQueue    = 1000
+	defaultHTTPBatch    = 100
+	defaultHTTPInterval = time.Second
+	defaultHTTPRetries  = 3
+	defaultHTTPBackoff  = time.Second
+	defaultHTTPTimeout  = 30 * time.Second
+)
+
+// 将日志以 JSON 格式批量 POST 到指定的 URL。
+//
+// Write 只是将日志放入队列，由后台的 goroutine 负责合并发送，
+// 队列已满时新的日志将被丢弃。服务端返回 5xx 和 429 时会进行重试，
+// 带有 Retry-After 报头时按其指定的时间等待(最长 1 分钟)；
+// 其它的错误状态码则直接丢弃该批次的日志。
+type HTTP struct {
+	url      string
+	format   int
+	gzip     bool
+	header   http.Header
+	username string
+	password string
+	token    string
+	client   *http.Client
+
+	batchWriter
+}
+
+// 每一条日志的 JSON 格式
+type httpRecord struct {
+	Time    string `json:"time"`
+	Level   string `json:"level,omitempty"`
+	Caller  string `json:"caller,omitempty"`
+	Message string `json:"message"`
+}
+
+// 新建 HTTP 实例，url 为接收日志的地址。
+func NewHTTP(url string) *HTTP {
+	h := &HTTP{
+		url:    url,
+		format: HTTPFormatJSON,
+		header: http.Header{},
+		client: &http.Client{Timeout: defaultHTTPTimeout},
+	}
+	h.batchWriter = newBatchWriter(h.send)
+	return h
+}
+
+// 设置请求内容的格式，可以是 HTTPFormatJSON 或是 HTTPFormatNDJSON。
+func (h *HTTP) SetFormat(format int) error {
+	if format != HTTPFormatJSON && format != HTTPFormatNDJSON {
+		return fmt.Errorf("无效的format值:[%v]", format)
+	}
+
+	h.format = format
+	return nil
+}
+
+// 是否使用 gzip 压缩请求内容。
+func (h *HTTP) SetGzip(gzip bool) {
+	h.gzip = gzip
+}
+
+// 添加一个自定义的报头，每次请求都会带上。
+func (h *HTTP) SetHeader(name, value string) {
+	h.header.Add(name, value)
+}
+
+// 使用 Basic 验证。
+func (h *HTTP) SetBasicAuth(username, password string) {
+	h.username = username
+	h.password = password
+}
+
+// 使用 Bearer 验证，优先于 SetBasicAuth()。
+func (h *HTTP) SetBearer(token string) {
+	h.token = token
+}
+
+// 设置每次请求的超时时间，默认为 30 秒。
+func (h *HTTP) SetTimeout(timeout time.Duration) {
+	h.client.Timeout = timeout
+}
+
+// 设置 http.Client 使用的 Transport，可用于指定 TLS 等配置。
+func (h *HTTP) SetTransport(transport http.RoundTripper) {
+	h.client.Transport = transport
+}
+
+// io.Writer
+func (h *HTTP) Write(msg []byte) (int, error) {
+	r := parseRecord(msg, h.prefix, h.flag)
+
+	t, ok := r.parseTime()
+	if !ok {
+		t = time.Now()
+	}
+
+	data, err := json.Marshal(&httpRecord{
+		Time:    t.Format(time.RFC3339Nano),
+		Level:   h.level,
+		Caller:  string(r.bytes(r.caller)),
+		Message: string(r.bytes(r.message)),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	h.push(data)
+	return len(msg), nil
+}
+
+// 将多条日志合并成请求内容
+func (h *HTTP) body(items [][]byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	var w io.Writer = buf
+
+	var gw *gzip.Writer
+	if h.gzip {
+		gw = gzip.NewWriter(buf)
+		w = gw
+	}
+
+	if h.format == HTTPFormatNDJSON {
+		for _, item := range items {
+			w.Write(item)
+			w.Write([]byte{'\n'})
+		}
+	} else {
+		w.Write([]byte{'['})
+		for i, item := range items {
+			if i > 0 {
+				w.Write([]byte{','})
+			}
+			w.Write(item)
+		}
+		w.Write([]byte{']'})
+	}
+
+	if gw != nil {
+		if err := gw.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (h *HTTP) send(items [][]byte) error {
+	body, err := h.body(items)
+	if err != nil {
+		return &noRetryError{err: err}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return &noRetryError{err: err}
+	}
+
+	for name, values := range h.header {
+		req.Header[name] = values
+	}
+	if h.format == HTTPFormatNDJSON {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if h.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	switch {
+	case h.token != "":
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	case h.username != "":
+		req.SetBasicAuth(h.username, h.password)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil { // 网络错误，需要重试
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("服务端返回错误的状态码:[%v]", resp.StatusCode)
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		if wait := parseRetryAfter(resp.Header.Get("Retry-After")); wait > 0 {
+			return &retryAfterError{err: err, wait: wait}
+		}
+		return err
+	}
+	return &noRetryError{err: err}
+}
+
+// 解析 Retry-After 报头，可以是秒数或是 HTTP 时间格式，无法解析时返回 0。
+// 重试时最多等待 maxRetryWait，不会完全按照服务端的值。
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
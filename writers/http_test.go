@@ -0,0 +1,232 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Leveler        = &HTTP{}
+	_ Flusher        = &HTTP{}
+	_ io.WriteCloser = &HTTP{}
+)
+
+// 记录所有请求的测试服务
+type httpServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   []int  // 依次返回的状态码，用完之后返回 200
+	after    string // 返回错误状态码时的 Retry-After 报头
+}
+
+func newHTTPServer() *httpServer {
+	s := &httpServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reader io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			reader = gr
+		}
+		body, _ := ioutil.ReadAll(reader)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		if len(s.status) > 0 {
+			if s.after != "" {
+				w.Header().Set("Retry-After", s.after)
+			}
+			w.WriteHeader(s.status[0])
+			s.status = s.status[1:]
+		}
+	}))
+
+	return s
+}
+
+func (s *httpServer) setStatus(status ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+func (s *httpServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func TestHTTP_Write(t *testing.T) {
+	a := assert.New(t)
+	srv := newHTTPServer()
+	defer srv.Close()
+
+	h := NewHTTP(srv.URL)
+	a.NotError(h.SetBatch(2, time.Hour)).Error(h.SetBatch(0, 0))
+	h.SetBearer("token")
+	h.SetHeader("X-App", "logs")
+	h.SetLevel("error", "[ERROR]", log.Lshortfile)
+
+	for _, msg := range []string{"[ERROR]a.go:1: m1\n", "[ERROR]a.go:2: m2\n", "[ERROR]a.go:3: m3\n"} {
+		size, err := h.Write([]byte(msg))
+		a.NotError(err).Equal(size, len(msg))
+	}
+	h.Flush()
+
+	a.Equal(srv.count(), 2)
+	r := srv.requests[0]
+	a.Equal(r.Method, http.MethodPost).
+		Equal(r.Header.Get("Authorization"), "Bearer token").
+		Equal(r.Header.Get("X-App"), "logs").
+		Equal(r.Header.Get("Content-Type"), "application/json")
+
+	records := []*httpRecord{}
+	a.NotError(json.Unmarshal(srv.bodies[0], &records))
+	a.Equal(len(records), 2).
+		Equal(records[0].Level, "error").
+		Equal(records[0].Caller, "a.go:1").
+		Equal(records[0].Message, "m1").
+		Equal(records[1].Message, "m2").
+		NotEmpty(records[0].Time)
+
+	records = records[:0]
+	a.NotError(json.Unmarshal(srv.bodies[1], &records))
+	a.Equal(len(records), 1).Equal(records[0].Message, "m3")
+
+	a.NotError(h.Close()).NotError(h.Close())
+	a.Equal(h.Dropped(), 0)
+}
+
+func TestHTTP_ndjson(t *testing.T) {
+	a := assert.New(t)
+	srv := newHTTPServer()
+	defer srv.Close()
+
+	h := NewHTTP(srv.URL)
+	a.NotError(h.SetFormat(HTTPFormatNDJSON)).Error(h.SetFormat(10))
+	h.SetGzip(true)
+	h.SetBasicAuth("user", "pwd")
+	h.Write([]byte("m1\n"))
+	h.Write([]byte("m2\n"))
+	h.Flush()
+	defer h.Close()
+
+	a.Equal(srv.count(), 1)
+	r := srv.requests[0]
+	username, password, ok := r.BasicAuth()
+	a.True(ok).Equal(username, "user").Equal(password, "pwd").
+		Equal(r.Header.Get("Content-Type"), "application/x-ndjson")
+
+	lines := 0
+	s := bufio.NewScanner(strings.NewReader(string(srv.bodies[0])))
+	for s.Scan() {
+		record := &httpRecord{}
+		a.NotError(json.Unmarshal(s.Bytes(), record))
+		lines++
+	}
+	a.Equal(lines, 2)
+}
+
+func TestHTTP_retry(t *testing.T) {
+	a := assert.New(t)
+	srv := newHTTPServer()
+	defer srv.Close()
+
+	h := NewHTTP(srv.URL)
+	a.NotError(h.SetQueue(10, 2, time.Millisecond))
+
+	// 5xx 和 429 会重试
+	srv.setStatus(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	h.Write([]byte("m1\n"))
+	h.Flush()
+	a.Equal(srv.count(), 3).Equal(h.Dropped(), 0)
+
+	// 4xx 不会重试
+	srv.setStatus(http.StatusBadRequest)
+	h.Write([]byte("m2\n"))
+	h.Flush()
+	a.Equal(srv.count(), 4).Equal(h.Dropped(), 1)
+
+	// 重试次数用完
+	srv.setStatus(500, 500, 500)
+	h.Write([]byte("m3\n"))
+	h.Flush()
+	a.Equal(srv.count(), 7).Equal(h.Dropped(), 2)
+
+	// 429 时按照 Retry-After 等待
+	srv.mu.Lock()
+	srv.status = []int{http.StatusTooManyRequests}
+	srv.after = "1"
+	srv.mu.Unlock()
+	start := time.Now()
+	h.Write([]byte("m4\n"))
+	h.Flush()
+	a.Equal(srv.count(), 9).Equal(h.Dropped(), 2)
+	a.True(time.Since(start) >= time.Second)
+
+	a.NotError(h.Close())
+}
+
+// Retry-After 过长时，Close 不会一直等待
+func TestHTTP_retryAfterClose(t *testing.T) {
+	a := assert.New(t)
+	srv := newHTTPServer()
+	defer srv.Close()
+
+	h := NewHTTP(srv.URL)
+	a.NotError(h.SetQueue(10, 2, time.Millisecond))
+	a.NotError(h.SetBatch(1, 0))
+
+	srv.mu.Lock()
+	srv.status = []int{503, 503, 503}
+	srv.after = "86400"
+	srv.mu.Unlock()
+	h.Write([]byte("m1\n"))
+	h.Write([]byte("m2\n"))
+	for srv.count() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// m1 等待中被中断，m2 只发送一次
+	start := time.Now()
+	a.NotError(h.Close())
+	a.True(time.Since(start) < 5*time.Second)
+	a.Equal(srv.count(), 2).Equal(h.Dropped(), 2)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal(parseRetryAfter(""), time.Duration(0))
+	a.Equal(parseRetryAfter("x"), time.Duration(0))
+	a.Equal(parseRetryAfter("-1"), time.Duration(0))
+	a.Equal(parseRetryAfter("3"), 3*time.Second)
+
+	d := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	a.True(d > 50*time.Second && d <= time.Minute)
+	a.Equal(parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)), time.Duration(0))
+}
@@ -0,0 +1,191 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// journald 的默认监听地址
+const journaldSocket = "/run/systemd/journal/socket"
+
+// 通过 systemd-journald 的原生协议输出日志。
+//
+// 除了 MESSAGE 之外，还会输出 PRIORITY、SYSLOG_IDENTIFIER 以及从日志中
+// 解析出来的 CODE_FILE 和 CODE_LINE 等字段，也可以通过 SetField 添加自定义字段。
+// 内容过大，无法通过一个数据报发送时，会写入 memfd 之后再传递其文件描述符。
+type Journald struct {
+	path       string
+	identifier string
+	fields     map[string]string
+	priority   int
+
+	prefix string
+	flag   int
+
+	mu   sync.Mutex
+	conn *net.UnixConn
+}
+
+// 新建 Journald 实例，path 为 journald 的 socket 地址，为空表示默认地址。
+func NewJournald(path string) *Journald {
+	if path == "" {
+		path = journaldSocket
+	}
+
+	return &Journald{
+		path:       path,
+		identifier: filepath.Base(os.Args[0]),
+		fields:     make(map[string]string),
+		priority:   SyslogInfo,
+	}
+}
+
+// 设置 SYSLOG_IDENTIFIER 字段，默认为程序的文件名。
+func (j *Journald) SetIdentifier(identifier string) {
+	j.identifier = identifier
+}
+
+// 添加一个自定义字段，每条日志都会带上该字段。
+//
+// 字段名只能包含大写字母、数字和下划线，且不能以下划线和数字开头。
+func (j *Journald) SetField(name, value string) error {
+	if !isJournaldField(name) {
+		return fmt.Errorf("无效的字段名:[%v]", name)
+	}
+
+	j.fields[name] = value
+	return nil
+}
+
+// Leveler.SetLevel()
+// 根据日志级别确定 PRIORITY 字段，与 syslog 的 severity 相同。
+func (j *Journald) SetLevel(level, prefix string, flag int) {
+	priority, found := syslogSeverities[level]
+	if !found {
+		priority = SyslogInfo
+	}
+
+	j.priority = priority
+	j.prefix = prefix
+	j.flag = flag
+}
+
+// io.Writer
+func (j *Journald) Write(msg []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	data := j.entry(msg)
+
+	var err error
+	for i := 0; i < 2; i++ { // 失败时重新连接一次
+		if j.conn == nil {
+			addr := &net.UnixAddr{Name: j.path, Net: "unixgram"}
+			if j.conn, err = net.DialUnix("unixgram", nil, addr); err != nil {
+				continue
+			}
+		}
+
+		if _, err = j.conn.Write(data); err == nil {
+			return len(msg), nil
+		}
+
+		if isMsgTooLarge(err) {
+			if err = sendLarge(j.conn, data); err != nil {
+				return 0, err
+			}
+			return len(msg), nil
+		}
+
+		j.conn.Close()
+		j.conn = nil
+	}
+
+	return 0, err
+}
+
+// io.Closer.Close()
+func (j *Journald) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.conn == nil {
+		return nil
+	}
+
+	err := j.conn.Close()
+	j.conn = nil
+	return err
+}
+
+// 构造一条原生协议格式的日志
+func (j *Journald) entry(msg []byte) []byte {
+	r := parseRecord(msg, j.prefix, j.flag)
+	buf := new(bytes.Buffer)
+
+	writeJournaldField(buf, "MESSAGE", r.bytes(r.message))
+	writeJournaldField(buf, "PRIORITY", []byte(fmt.Sprint(j.priority)))
+	if j.identifier != "" {
+		writeJournaldField(buf, "SYSLOG_IDENTIFIER", []byte(j.identifier))
+	}
+
+	if !r.caller.empty() {
+		caller := string(r.bytes(r.caller))
+		if index := strings.LastIndexByte(caller, ':'); index > 0 {
+			writeJournaldField(buf, "CODE_FILE", []byte(caller[:index]))
+			writeJournaldField(buf, "CODE_LINE", []byte(caller[index+1:]))
+		}
+	}
+
+	names := make([]string, 0, len(j.fields))
+	for name := range j.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeJournaldField(buf, name, []byte(j.fields[name]))
+	}
+
+	return buf.Bytes()
+}
+
+// 写入一个字段。
+// 值中包含换行符时，需要使用 名称\n 64 位小端长度 值\n 的格式。
+func writeJournaldField(buf *bytes.Buffer, name string, value []byte) {
+	buf.WriteString(name)
+	if bytes.IndexByte(value, '\n') < 0 {
+		buf.WriteByte('=')
+		buf.Write(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.Write(value)
+	buf.WriteByte('\n')
+}
+
+func isJournaldField(name string) bool {
+	if name == "" || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+
+	for _, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
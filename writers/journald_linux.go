@@ -0,0 +1,107 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	mfdCloexec       = 0x1
+	mfdAllowSealing  = 0x2
+	fcntlAddSeals    = 1033
+	sealAll          = 0x1 | 0x2 | 0x4 | 0x8 // F_SEAL_SEAL|F_SEAL_SHRINK|F_SEAL_GROW|F_SEAL_WRITE
+	journaldShmDir   = "/dev/shm"
+	journaldFilename = "logs-journald"
+)
+
+// 数据报是否因为过大而发送失败
+func isMsgTooLarge(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// 将过大的内容写入 memfd，并通过 SCM_RIGHTS 传递其文件描述符。
+// 系统不支持 memfd 时，使用 /dev/shm 中已经删除的临时文件代替，
+// 与 sd_journal_sendv 的处理方式相同。
+func sendLarge(conn *net.UnixConn, data []byte) error {
+	f, err := memfd(data)
+	if err != nil {
+		if f, err = shmFile(data); err != nil {
+			return err
+		}
+	}
+	defer f.Close()
+
+	// 已连接的 unixgram 无法通过 WriteMsgUnix 发送，直接调用 sendmsg
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	rights := syscall.UnixRights(int(f.Fd()))
+	werr := raw.Write(func(fd uintptr) bool {
+		err = syscall.Sendmsg(int(fd), nil, rights, nil, 0)
+		return err != syscall.EAGAIN
+	})
+	if werr != nil {
+		return werr
+	}
+	return err
+}
+
+func memfd(data []byte) (*os.File, error) {
+	if sysMemfdCreate == 0 {
+		return nil, errors.New("当前平台不支持 memfd")
+	}
+
+	name, err := syscall.BytePtrFromString(journaldFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, _, errno := syscall.Syscall(uintptr(sysMemfdCreate), uintptr(unsafe.Pointer(name)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	f := os.NewFile(fd, journaldFilename)
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// journald 要求 memfd 是已经密封的
+	if _, _, errno = syscall.Syscall(syscall.SYS_FCNTL, fd, fcntlAddSeals, sealAll); errno != 0 {
+		f.Close()
+		return nil, errno
+	}
+
+	return f, nil
+}
+
+func shmFile(data []byte) (*os.File, error) {
+	f, err := ioutil.TempFile(journaldShmDir, journaldFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	// journald 只接受已经删除的文件
+	if err = os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
@@ -0,0 +1,8 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+// memfd_create 的系统调用号
+const sysMemfdCreate = 319
@@ -0,0 +1,11 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build linux && !amd64 && !arm64
+// +build linux,!amd64,!arm64
+
+package writers
+
+// 未知的平台，不使用 memfd
+const sysMemfdCreate = 0
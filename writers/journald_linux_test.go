@@ -0,0 +1,66 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+// 超过数据报大小的内容，通过文件描述符传递
+func TestJournald_Write_large(t *testing.T) {
+	a := assert.New(t)
+	path, conn, closeFn := newJournaldServer(a)
+	defer closeFn()
+
+	j := NewJournald(path)
+	j.SetIdentifier("app")
+	msg := strings.Repeat("x", 1024*1024)
+	size, err := j.Write([]byte(msg + "\n"))
+	a.NotError(err).Equal(size, len(msg)+1)
+	defer j.Close()
+
+	buf := make([]byte, 1024)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	a.NotError(err).Equal(n, 0)
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	a.NotError(err).Equal(len(msgs), 1)
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	a.NotError(err).Equal(len(fds), 1)
+
+	f := os.NewFile(uintptr(fds[0]), "journald")
+	defer f.Close()
+	_, err = f.Seek(0, 0)
+	a.NotError(err)
+	data, err := ioutil.ReadAll(f)
+	a.NotError(err)
+	a.True(bytes.HasPrefix(data, []byte("MESSAGE="+msg+"\n"))).
+		True(bytes.HasSuffix(data, []byte("SYSLOG_IDENTIFIER=app\n")))
+}
+
+func TestMemfd(t *testing.T) {
+	a := assert.New(t)
+	if sysMemfdCreate == 0 {
+		return
+	}
+
+	f, err := memfd([]byte("abc"))
+	a.NotError(err).NotNil(f)
+	defer f.Close()
+
+	// 已经密封，不能再写入
+	_, err = f.Write([]byte("def"))
+	a.Error(err)
+}
@@ -0,0 +1,22 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package writers
+
+import (
+	"errors"
+	"net"
+)
+
+// 非 linux 平台不存在 journald
+func isMsgTooLarge(err error) bool {
+	return false
+}
+
+func sendLarge(conn *net.UnixConn, data []byte) error {
+	return errors.New("当前平台不支持 journald")
+}
@@ -0,0 +1,97 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Leveler        = &Journald{}
+	_ io.WriteCloser = &Journald{}
+)
+
+// 监听一个临时的 unixgram 地址，模拟 journald
+func newJournaldServer(a *assert.Assertion) (string, *net.UnixConn, func()) {
+	dir, err := ioutil.TempDir("", "logs-journald")
+	a.NotError(err)
+
+	path := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	a.NotError(err)
+
+	return path, conn, func() {
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestIsJournaldField(t *testing.T) {
+	a := assert.New(t)
+
+	a.True(isJournaldField("MESSAGE")).
+		True(isJournaldField("APP_1")).
+		False(isJournaldField("")).
+		False(isJournaldField("_PID")).
+		False(isJournaldField("1A")).
+		False(isJournaldField("Message")).
+		False(isJournaldField("A-B"))
+}
+
+func TestWriteJournaldField(t *testing.T) {
+	a := assert.New(t)
+	buf := new(bytes.Buffer)
+
+	writeJournaldField(buf, "A", []byte("abc"))
+	a.Equal(buf.String(), "A=abc\n")
+
+	buf.Reset()
+	writeJournaldField(buf, "B", []byte("a\nb"))
+	size := make([]byte, 8)
+	binary.LittleEndian.PutUint64(size, 3)
+	a.Equal(buf.String(), "B\n"+string(size)+"a\nb\n")
+}
+
+func TestJournald_Write(t *testing.T) {
+	a := assert.New(t)
+	path, conn, closeFn := newJournaldServer(a)
+	defer closeFn()
+
+	j := NewJournald(path)
+	j.SetIdentifier("app")
+	a.NotError(j.SetField("APP", "logs")).Error(j.SetField("_PID", "1"))
+	j.SetLevel("warn", "[WARN]", log.Lshortfile)
+
+	size, err := j.Write([]byte("[WARN]journald.go:10: hello\n"))
+	a.NotError(err).Equal(size, len("[WARN]journald.go:10: hello\n"))
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	a.NotError(err)
+	a.Equal(string(buf[:n]), "MESSAGE=hello\nPRIORITY=4\nSYSLOG_IDENTIFIER=app\n"+
+		"CODE_FILE=journald.go\nCODE_LINE=10\nAPP=logs\n")
+
+	a.NotError(j.Close()).NotError(j.Close())
+}
+
+func TestJournald_Write_notExists(t *testing.T) {
+	a := assert.New(t)
+
+	j := NewJournald(filepath.Join(os.TempDir(), "logs-journald-not-exists"))
+	size, err := j.Write([]byte("hello\n"))
+	a.Error(err).Equal(size, 0)
+}
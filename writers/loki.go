@@ -0,0 +1,329 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Loki 请求的内容格式
+const (
+	LokiFormatJSON     = iota // JSON 格式
+	LokiFormatProtobuf        // snappy 压缩的 protobuf 格式
+)
+
+// 推送日志的接口地址
+const lokiPushPath = "/loki/api/v1/push"
+
+// 将日志推送到 Grafana Loki。
+//
+// 日志按标签分成不同的 stream，默认的标签为 host 和 level，
+// 可以通过 SetLabel 添加其它的静态标签，比如 app。
+// Write 只是将日志放入队列，由后台的 goroutine 按顺序合并发送，
+// 同一 stream 中的日志按时间排序，服务端返回 5xx 和 429 时会进行重试。
+type Loki struct {
+	url      string
+	format   int
+	labels   map[string]string
+	tenant   string
+	username string
+	password string
+	client   *http.Client
+
+	batchWriter
+}
+
+// 队列中的每一条日志
+type lokiEntry struct {
+	Labels map[string]string `json:"l"`
+	Time   int64             `json:"t"` // 纳秒
+	Line   string            `json:"m"`
+}
+
+// 新建 Loki 实例，url 为 Loki 的服务地址，比如 http://localhost:3100。
+func NewLoki(url string) *Loki {
+	labels := map[string]string{}
+	if host, err := os.Hostname(); err == nil {
+		labels["host"] = host
+	}
+
+	l := &Loki{
+		url:    strings.TrimRight(url, "/") + lokiPushPath,
+		format: LokiFormatJSON,
+		labels: labels,
+		client: &http.Client{Timeout: defaultHTTPTimeout},
+	}
+	l.batchWriter = newBatchWriter(l.send)
+	return l
+}
+
+// 设置请求内容的格式，可以是 LokiFormatJSON 或是 LokiFormatProtobuf。
+func (l *Loki) SetFormat(format int) error {
+	if format != LokiFormatJSON && format != LokiFormatProtobuf {
+		return fmt.Errorf("无效的format值:[%v]", format)
+	}
+
+	l.format = format
+	return nil
+}
+
+// 添加一个静态标签，value 为空表示删除该标签。
+//
+// 标签名只能包含字母、数字和下划线，且不能以数字开头。
+func (l *Loki) SetLabel(name, value string) error {
+	if !isLokiLabel(name) {
+		return fmt.Errorf("无效的标签名:[%v]", name)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if value == "" {
+		delete(l.labels, name)
+	} else {
+		l.labels[name] = value
+	}
+	return nil
+}
+
+// 设置多租户模式下的租户 ID，即 X-Scope-OrgID 报头。
+func (l *Loki) SetTenant(tenant string) {
+	l.tenant = tenant
+}
+
+// 使用 Basic 验证。
+func (l *Loki) SetBasicAuth(username, password string) {
+	l.username = username
+	l.password = password
+}
+
+// 设置每次请求的超时时间，默认为 30 秒。
+func (l *Loki) SetTimeout(timeout time.Duration) {
+	l.client.Timeout = timeout
+}
+
+// 设置 http.Client 使用的 Transport，可用于指定 TLS 等配置。
+func (l *Loki) SetTransport(transport http.RoundTripper) {
+	l.client.Transport = transport
+}
+
+// Leveler.SetLevel()
+// level 会作为 stream 的标签。
+func (l *Loki) SetLevel(level, prefix string, flag int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.level = level
+	l.prefix = prefix
+	l.flag = flag
+}
+
+// io.Writer
+func (l *Loki) Write(msg []byte) (int, error) {
+	l.mu.Lock()
+	r := parseRecord(msg, l.prefix, l.flag)
+	labels := l.streamLabels()
+	l.mu.Unlock()
+
+	t, ok := r.parseTime()
+	if !ok {
+		t = time.Now()
+	}
+
+	// 保留调用位置，只去掉前缀和时间
+	line := string(r.bytes(r.message))
+	if !r.caller.empty() {
+		line = string(r.bytes(r.caller)) + ": " + line
+	}
+
+	data, err := json.Marshal(&lokiEntry{Labels: labels, Time: t.UnixNano(), Line: line})
+	if err != nil {
+		return 0, err
+	}
+
+	l.push(data)
+	return len(msg), nil
+}
+
+// 当前日志的标签，需要在锁中调用。
+func (l *Loki) streamLabels() map[string]string {
+	labels := make(map[string]string, len(l.labels)+1)
+	for name, value := range l.labels {
+		labels[name] = value
+	}
+	if l.level != "" {
+		labels["level"] = l.level
+	}
+	return labels
+}
+
+// 将标签格式化成 {a="1", b="2"} 的形式，同时作为 stream 的标识。
+func formatLokiLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(name)
+		buf.WriteByte('=')
+		buf.WriteString(strconv.Quote(labels[name]))
+	}
+	buf.WriteByte('}')
+
+	return buf.String()
+}
+
+// 将日志按 stream 分组，保持 stream 第一次出现的顺序，
+// 同一 stream 中的日志按时间排序。
+func lokiStreams(items [][]byte) ([]string, map[string][]*lokiEntry, error) {
+	keys := make([]string, 0, 2)
+	streams := make(map[string][]*lokiEntry, 2)
+
+	for _, item := range items {
+		entry := &lokiEntry{}
+		if err := json.Unmarshal(item, entry); err != nil {
+			return nil, nil, err
+		}
+
+		key := formatLokiLabels(entry.Labels)
+		if _, found := streams[key]; !found {
+			keys = append(keys, key)
+		}
+		streams[key] = append(streams[key], entry)
+	}
+
+	for _, entries := range streams {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Time < entries[j].Time
+		})
+	}
+
+	return keys, streams, nil
+}
+
+func (l *Loki) send(items [][]byte) error {
+	keys, streams, err := lokiStreams(items)
+	if err != nil {
+		return &noRetryError{err: err}
+	}
+
+	var body []byte
+	contentType := "application/json"
+	if l.format == LokiFormatProtobuf {
+		body = lokiProtobuf(keys, streams)
+		contentType = "application/x-protobuf"
+	} else if body, err = lokiJSON(keys, streams); err != nil {
+		return &noRetryError{err: err}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, l.url, bytes.NewReader(body))
+	if err != nil {
+		return &noRetryError{err: err}
+	}
+	req.Header.Set("Content-Type", contentType)
+	if l.tenant != "" {
+		req.Header.Set("X-Scope-OrgID", l.tenant)
+	}
+	if l.username != "" {
+		req.SetBasicAuth(l.username, l.password)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil { // 网络错误，需要重试
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("服务端返回错误的状态码:[%v]", resp.StatusCode)
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return err
+	}
+	return &noRetryError{err: err}
+}
+
+// 构造 JSON 格式的请求内容：
+//
+//	{"streams":[{"stream":{"label":"value"},"values":[["纳秒","内容"]]}]}
+func lokiJSON(keys []string, streams map[string][]*lokiEntry) ([]byte, error) {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+
+	ss := make([]*stream, 0, len(keys))
+	for _, key := range keys {
+		s := &stream{Stream: streams[key][0].Labels, Values: make([][2]string, 0, len(streams[key]))}
+		for _, entry := range streams[key] {
+			s.Values = append(s.Values, [2]string{strconv.FormatInt(entry.Time, 10), entry.Line})
+		}
+		ss = append(ss, s)
+	}
+
+	return json.Marshal(map[string]interface{}{"streams": ss})
+}
+
+// 构造 snappy 压缩的 protobuf 格式的请求内容：
+//
+//	PushRequest { repeated StreamAdapter streams = 1; }
+//	StreamAdapter { string labels = 1; repeated EntryAdapter entries = 2; }
+//	EntryAdapter { Timestamp timestamp = 1; string line = 2; }
+//	Timestamp { int64 seconds = 1; int32 nanos = 2; }
+func lokiProtobuf(keys []string, streams map[string][]*lokiEntry) []byte {
+	req := &pbEncoder{}
+	for _, key := range keys {
+		stream := &pbEncoder{}
+		stream.string(1, key)
+
+		for _, entry := range streams[key] {
+			ts := &pbEncoder{}
+			ts.uint(1, uint64(entry.Time/int64(time.Second)))
+			ts.uint(2, uint64(entry.Time%int64(time.Second)))
+
+			e := &pbEncoder{}
+			e.message(1, ts.buf)
+			e.string(2, entry.Line)
+			stream.message(2, e.buf)
+		}
+
+		req.message(1, stream.buf)
+	}
+
+	return snappyEncode(req.buf)
+}
+
+func isLokiLabel(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
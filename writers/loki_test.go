@@ -0,0 +1,188 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Leveler        = &Loki{}
+	_ Flusher        = &Loki{}
+	_ io.WriteCloser = &Loki{}
+)
+
+// 模拟 Loki 的服务端
+type lokiServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	headers []http.Header
+	bodies  [][]byte
+	status  []int
+}
+
+func newLokiServer() *lokiServer {
+	s := &lokiServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.URL.Path != lokiPushPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		s.headers = append(s.headers, r.Header)
+		s.bodies = append(s.bodies, body)
+		if len(s.status) > 0 {
+			w.WriteHeader(s.status[0])
+			s.status = s.status[1:]
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return s
+}
+
+func (s *lokiServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func TestFormatLokiLabels(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal(formatLokiLabels(map[string]string{}), "{}")
+	a.Equal(formatLokiLabels(map[string]string{"level": "info", "app": `a"b`}), `{app="a\"b", level="info"}`)
+}
+
+func TestLoki_json(t *testing.T) {
+	a := assert.New(t)
+	srv := newLokiServer()
+	defer srv.Close()
+
+	l := NewLoki(srv.URL + "/")
+	a.NotError(l.SetBatch(10, time.Hour))
+	a.NotError(l.SetLabel("app", "logs")).
+		NotError(l.SetLabel("host", "")).
+		Error(l.SetLabel("1a", "x"))
+	l.SetTenant("team")
+
+	l.SetLevel("info", "[INFO]", 0)
+	l.Write([]byte("[INFO]m1\n"))
+	l.SetLevel("error", "[ERROR]", 0)
+	l.Write([]byte("[ERROR]m2\n"))
+	l.SetLevel("info", "[INFO]", 0)
+	l.Write([]byte("[INFO]m3\n"))
+	l.Flush()
+
+	a.Equal(srv.count(), 1)
+	a.Equal(srv.headers[0].Get("X-Scope-OrgID"), "team").
+		Equal(srv.headers[0].Get("Content-Type"), "application/json")
+
+	req := &struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}{}
+	a.NotError(json.Unmarshal(srv.bodies[0], req))
+	a.Equal(len(req.Streams), 2)
+
+	s := req.Streams[0]
+	a.Equal(s.Stream, map[string]string{"app": "logs", "level": "info"}).
+		Equal(len(s.Values), 2).
+		Equal(s.Values[0][1], "m1").
+		Equal(s.Values[1][1], "m3")
+	t1, err := strconv.ParseInt(s.Values[0][0], 10, 64)
+	a.NotError(err)
+	t2, err := strconv.ParseInt(s.Values[1][0], 10, 64)
+	a.NotError(err)
+	a.True(t1 <= t2)
+
+	s = req.Streams[1]
+	a.Equal(s.Stream["level"], "error").Equal(s.Values[0][1], "m2")
+
+	a.NotError(l.Close()).NotError(l.Close())
+}
+
+func TestLoki_protobuf(t *testing.T) {
+	a := assert.New(t)
+	srv := newLokiServer()
+	defer srv.Close()
+
+	l := NewLoki(srv.URL)
+	a.NotError(l.SetFormat(LokiFormatProtobuf)).Error(l.SetFormat(10))
+	a.NotError(l.SetLabel("host", ""))
+	l.SetLevel("warn", "[WARN]", 0)
+	l.Write([]byte("[WARN]m1\n"))
+	l.Flush()
+	defer l.Close()
+
+	a.Equal(srv.count(), 1)
+	a.Equal(srv.headers[0].Get("Content-Type"), "application/x-protobuf")
+
+	data, err := snappyDecode(srv.bodies[0])
+	a.NotError(err)
+
+	// PushRequest
+	fields, err := pbDecode(data)
+	a.NotError(err).Equal(len(fields), 1).Equal(fields[0].num, 1)
+
+	// StreamAdapter
+	fields, err = pbDecode(fields[0].data)
+	a.NotError(err).Equal(len(fields), 2)
+	a.Equal(string(fields[0].data), `{level="warn"}`).Equal(fields[1].num, 2)
+
+	// EntryAdapter
+	fields, err = pbDecode(fields[1].data)
+	a.NotError(err).Equal(len(fields), 2)
+	a.Equal(string(fields[1].data), "m1")
+
+	// Timestamp
+	fields, err = pbDecode(fields[0].data)
+	a.NotError(err)
+	a.True(fields[0].value > 0)
+}
+
+func TestLoki_retry(t *testing.T) {
+	a := assert.New(t)
+	srv := newLokiServer()
+	defer srv.Close()
+
+	l := NewLoki(srv.URL)
+	a.NotError(l.SetQueue(10, 2, time.Millisecond))
+
+	srv.mu.Lock()
+	srv.status = []int{http.StatusTooManyRequests, http.StatusBadGateway}
+	srv.mu.Unlock()
+	l.Write([]byte("m1\n"))
+	l.Flush()
+	a.Equal(srv.count(), 3).Equal(l.Dropped(), 0)
+
+	// 400 不重试
+	srv.mu.Lock()
+	srv.status = []int{http.StatusBadRequest}
+	srv.mu.Unlock()
+	l.Write([]byte("m2\n"))
+	l.Flush()
+	a.Equal(srv.count(), 4).Equal(l.Dropped(), 1)
+
+	a.NotError(l.Close())
+}
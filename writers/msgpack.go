@@ -0,0 +1,319 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// 简单的 msgpack 编码器，仅实现了输出日志需要用到的类型。
+type mpEncoder struct {
+	buf []byte
+}
+
+// msgpack 中的扩展类型
+type mpExt struct {
+	typ  int8
+	data []byte
+}
+
+var errMsgpackShort = errors.New("msgpack 内容不完整")
+
+func (e *mpEncoder) nil() {
+	e.buf = append(e.buf, 0xc0)
+}
+
+func (e *mpEncoder) bool(v bool) {
+	if v {
+		e.buf = append(e.buf, 0xc3)
+	} else {
+		e.buf = append(e.buf, 0xc2)
+	}
+}
+
+func (e *mpEncoder) int(v int64) {
+	switch {
+	case v >= 0:
+		e.uint(uint64(v))
+	case v >= -32:
+		e.buf = append(e.buf, byte(v))
+	case v >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+	case v >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+	}
+}
+
+func (e *mpEncoder) uint(v uint64) {
+	switch {
+	case v < 128:
+		e.buf = append(e.buf, byte(v))
+	case v <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+	case v <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, v)
+	}
+}
+
+func (e *mpEncoder) string(v string) {
+	n := len(v)
+	switch {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, v...)
+}
+
+func (e *mpEncoder) bin(v []byte) {
+	n := len(v)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xc5)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xc6)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, v...)
+}
+
+// 写入数组的头部，之后需要再写入 n 个元素。
+func (e *mpEncoder) array(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xdc)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdd)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+// 写入 map 的头部，之后需要再写入 n 组键值。
+func (e *mpEncoder) mapHeader(n int) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xde)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdf)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+func (e *mpEncoder) ext(typ int8, data []byte) {
+	n := len(data)
+	switch n {
+	case 1:
+		e.buf = append(e.buf, 0xd4)
+	case 2:
+		e.buf = append(e.buf, 0xd5)
+	case 4:
+		e.buf = append(e.buf, 0xd6)
+	case 8:
+		e.buf = append(e.buf, 0xd7)
+	case 16:
+		e.buf = append(e.buf, 0xd8)
+	default:
+		switch {
+		case n <= math.MaxUint8:
+			e.buf = append(e.buf, 0xc7, byte(n))
+		case n <= math.MaxUint16:
+			e.buf = append(e.buf, 0xc8)
+			e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+		default:
+			e.buf = append(e.buf, 0xc9)
+			e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+		}
+	}
+	e.buf = append(e.buf, byte(typ))
+	e.buf = append(e.buf, data...)
+}
+
+// 解码一个 msgpack 值，返回解码后的值以及剩余的内容。
+//
+// 整数统一解码为 int64 或 uint64，str 为 string，bin 为 []byte，
+// array 为 []interface{}，map 为 map[string]interface{}（键名必须是 str），
+// 扩展类型为 *mpExt。
+func mpDecode(data []byte) (interface{}, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errMsgpackShort
+	}
+
+	b := data[0]
+	data = data[1:]
+	switch {
+	case b <= 0x7f:
+		return int64(b), data, nil
+	case b >= 0xe0:
+		return int64(int8(b)), data, nil
+	case b&0xf0 == 0x80:
+		return mpDecodeMap(data, int(b&0x0f))
+	case b&0xf0 == 0x90:
+		return mpDecodeArray(data, int(b&0x0f))
+	case b&0xe0 == 0xa0:
+		return mpDecodeBytes(data, int(b&0x1f), true)
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, data, nil
+	case 0xc2:
+		return false, data, nil
+	case 0xc3:
+		return true, data, nil
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb:
+		size, rest, err := mpReadSize(data, b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return mpDecodeBytes(rest, size, b >= 0xd9)
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n := 1 << (b - 0xcc)
+		if len(data) < n {
+			return nil, nil, errMsgpackShort
+		}
+		return mpReadUint(data[:n]), data[n:], nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (b - 0xd0)
+		if len(data) < n {
+			return nil, nil, errMsgpackShort
+		}
+		v := mpReadUint(data[:n])
+		shift := uint(64 - 8*n)
+		return int64(v<<shift) >> shift, data[n:], nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return mpDecodeExt(data, 1<<(b-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		size, rest, err := mpReadSize(data, b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return mpDecodeExt(rest, size)
+	case 0xdc, 0xdd:
+		size, rest, err := mpReadSize(data, b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return mpDecodeArray(rest, size)
+	case 0xde, 0xdf:
+		size, rest, err := mpReadSize(data, b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return mpDecodeMap(rest, size)
+	}
+
+	return nil, nil, errors.New("不支持的 msgpack 类型")
+}
+
+// 读取长度字段，各类型的长度字段分别为 1、2 或 4 字节。
+func mpReadSize(data []byte, b byte) (int, []byte, error) {
+	var n int
+	switch b {
+	case 0xc4, 0xc7, 0xd9:
+		n = 1
+	case 0xc5, 0xc8, 0xda, 0xdc, 0xde:
+		n = 2
+	default:
+		n = 4
+	}
+
+	if len(data) < n {
+		return 0, nil, errMsgpackShort
+	}
+	return int(mpReadUint(data[:n])), data[n:], nil
+}
+
+func mpReadUint(data []byte) uint64 {
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func mpDecodeBytes(data []byte, size int, str bool) (interface{}, []byte, error) {
+	if len(data) < size {
+		return nil, nil, errMsgpackShort
+	}
+
+	if str {
+		return string(data[:size]), data[size:], nil
+	}
+	return data[:size], data[size:], nil
+}
+
+func mpDecodeExt(data []byte, size int) (interface{}, []byte, error) {
+	if len(data) < size+1 {
+		return nil, nil, errMsgpackShort
+	}
+	return &mpExt{typ: int8(data[0]), data: data[1 : size+1]}, data[size+1:], nil
+}
+
+func mpDecodeArray(data []byte, size int) (interface{}, []byte, error) {
+	arr := make([]interface{}, 0, size)
+	for i := 0; i < size; i++ {
+		v, rest, err := mpDecode(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		arr = append(arr, v)
+		data = rest
+	}
+	return arr, data, nil
+}
+
+func mpDecodeMap(data []byte, size int) (interface{}, []byte, error) {
+	m := make(map[string]interface{}, size)
+	for i := 0; i < size; i++ {
+		k, rest, err := mpDecode(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, nil, errors.New("map 的键名必须是字符串")
+		}
+
+		v, rest, err := mpDecode(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		m[key] = v
+		data = rest
+	}
+	return m, data, nil
+}
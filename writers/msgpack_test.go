@@ -0,0 +1,62 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/issue9/assert"
+)
+
+func TestMsgpack(t *testing.T) {
+	a := assert.New(t)
+
+	e := &mpEncoder{}
+	e.array(12)
+	e.nil()
+	e.bool(true)
+	e.bool(false)
+	e.int(-1)
+	e.int(-100)
+	e.int(math.MinInt64)
+	e.uint(1)
+	e.uint(300)
+	e.uint(math.MaxUint64)
+	e.string("abc")
+	e.bin([]byte{1, 2, 3})
+	e.ext(0, []byte{1, 2, 3, 4, 5, 6, 7, 8})
+
+	v, rest, err := mpDecode(e.buf)
+	a.NotError(err).Empty(rest)
+	a.Equal(v, []interface{}{
+		nil, true, false,
+		int64(-1), int64(-100), int64(math.MinInt64),
+		int64(1), uint64(300), uint64(math.MaxUint64),
+		"abc", []byte{1, 2, 3},
+		&mpExt{typ: 0, data: []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+	})
+
+	// 长度较大的类型
+	long := strings.Repeat("x", 70000)
+	e = &mpEncoder{}
+	e.mapHeader(2)
+	e.string("str")
+	e.string(long)
+	e.string("arr")
+	e.array(20)
+	for i := 0; i < 20; i++ {
+		e.int(int64(i))
+	}
+	v, rest, err = mpDecode(e.buf)
+	a.NotError(err).Empty(rest)
+	m := v.(map[string]interface{})
+	a.Equal(m["str"], long).Equal(len(m["arr"].([]interface{})), 20)
+
+	// 内容不完整
+	_, _, err = mpDecode(e.buf[:len(e.buf)-1])
+	a.Equal(err, errMsgpackShort)
+}
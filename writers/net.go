@@ -0,0 +1,218 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Net 的默认配置
+const (
+	defaultNetTimeout     = 10 * time.Second
+	defaultNetBackoff     = 500 * time.Millisecond
+	defaultNetMaxBackoff  = time.Minute
+	defaultNetBuffer      = 1 << 20 // 断开期间最多缓存 1M 的内容
+	defaultNetMaxDatagram = 1400    // 避免 UDP 数据报在以太网中被分片
+)
+
+// 将日志输出到网络连接，支持 tcp、udp、unix 和 unixgram。
+//
+// 在第一次写入时才会建立连接，连接失败之后按 backoff 的倍数递增等待时间，
+// 在等待期间写入的内容会被缓存，连接成功之后再依次发送；
+// 缓存超出大小时丢弃最早的内容，丢弃的数量可以通过 Dropped() 获取；
+// 写入失败时已经发送了一部分的日志也会被丢弃，不会在重连之后重复发送。
+// 对于数据报类型的连接，超过 maxDatagram 的内容会被拆分成多个数据报发送。
+type Net struct {
+	network     string
+	addr        string
+	stream      bool
+	tlsConfig   *tls.Config
+	timeout     time.Duration
+	maxDatagram int
+
+	mu      sync.Mutex
+	conn    net.Conn
+	backlog backlog // 连接断开期间缓存的内容
+}
+
+// 新建 Net 实例。
+// network 可以是 tcp、tcp4、tcp6、udp、udp4、udp6、unix 和 unixgram。
+func NewNet(network, addr string) (*Net, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	case "udp", "udp4", "udp6", "unixgram":
+	default:
+		return nil, fmt.Errorf("无效的network值:[%v]", network)
+	}
+
+	return &Net{
+		network:     network,
+		addr:        addr,
+		stream:      strings.HasPrefix(network, "tcp") || network == "unix",
+		timeout:     defaultNetTimeout,
+		maxDatagram: defaultNetMaxDatagram,
+		backlog: backlog{
+			bufSize:    defaultNetBuffer,
+			backoff:    defaultNetBackoff,
+			maxBackoff: defaultNetMaxBackoff,
+		},
+	}, nil
+}
+
+// 设置 TLS 配置，仅对 tcp 有效。
+func (n *Net) SetTLS(conf *tls.Config) {
+	n.tlsConfig = conf
+}
+
+// 设置连接和写入的超时时间，默认为 10 秒。
+func (n *Net) SetTimeout(timeout time.Duration) {
+	n.timeout = timeout
+}
+
+// 设置重连的等待时间。
+// 第一次连接失败之后等待 backoff，之后每次翻倍，最多不超过 max。
+func (n *Net) SetBackoff(backoff, max time.Duration) {
+	n.backlog.backoff = backoff
+	n.backlog.maxBackoff = max
+}
+
+// 设置连接断开期间最多缓存的字节数，为 0 表示不缓存。
+func (n *Net) SetBuffer(size int) {
+	n.backlog.bufSize = size
+}
+
+// 设置每个数据报的最大字节数，仅对 udp 和 unixgram 有效。
+func (n *Net) SetMaxDatagram(size int) error {
+	if size < 1 {
+		return fmt.Errorf("无效的size值:[%v]", size)
+	}
+
+	n.maxDatagram = size
+	return nil
+}
+
+// 因为缓存已满或是只发送了一部分而被丢弃的日志数量
+func (n *Net) Dropped() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.backlog.dropCnt
+}
+
+// io.Writer
+//
+// 连接不可用时，内容会被缓存，并不会返回错误。
+func (n *Net) Write(msg []byte) (int, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.backlog.push(msg)
+	if n.conn != nil || n.backlog.ready() {
+		n.send()
+	}
+	n.backlog.trim()
+
+	return len(msg), nil
+}
+
+// Flusher.Flush()
+// 忽略重连的等待时间，立即发送缓存的内容。
+func (n *Net) Flush() (int, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	size := n.backlog.size
+	if err := n.send(); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// io.Closer.Close()
+// 尽量发送缓存的内容之后关闭连接。
+func (n *Net) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(n.backlog.items) > 0 {
+		n.send()
+	}
+
+	if n.conn == nil {
+		return nil
+	}
+
+	err := n.conn.Close()
+	n.conn = nil
+	return err
+}
+
+// 依次发送缓存的内容，需要在锁中调用。
+func (n *Net) send() error {
+	if n.conn == nil {
+		if err := n.dial(); err != nil {
+			return err
+		}
+	}
+
+	if err := n.backlog.flush(n.write); err != nil {
+		n.conn.Close()
+		n.conn = nil
+		n.backlog.fail()
+		return err
+	}
+
+	// 连续失败的次数只有在成功发送之后才清零，防止频繁断开的连接被不停地重连。
+	n.backlog.failures = 0
+	return nil
+}
+
+func (n *Net) dial() (err error) {
+	dialer := &net.Dialer{Timeout: n.timeout}
+	if n.tlsConfig != nil && strings.HasPrefix(n.network, "tcp") {
+		n.conn, err = tls.DialWithDialer(dialer, n.network, n.addr, n.tlsConfig)
+	} else {
+		n.conn, err = dialer.Dial(n.network, n.addr)
+	}
+
+	if err != nil {
+		n.conn = nil
+		n.backlog.fail()
+		return err
+	}
+	return nil
+}
+
+// 写入一条内容，数据报类型的连接会按 maxDatagram 拆分，
+// 返回已经写入的字节数。
+func (n *Net) write(data []byte) (int, error) {
+	if n.timeout > 0 {
+		n.conn.SetWriteDeadline(time.Now().Add(n.timeout))
+	}
+
+	if n.stream {
+		return n.conn.Write(data)
+	}
+
+	written := 0
+	for len(data) > 0 {
+		size := n.maxDatagram
+		if size > len(data) {
+			size = len(data)
+		}
+
+		if _, err := n.conn.Write(data[:size]); err != nil {
+			return written, err
+		}
+		data = data[size:]
+		written += size
+	}
+	return written, nil
+}
@@ -0,0 +1,218 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Flusher        = &Net{}
+	_ io.WriteCloser = &Net{}
+)
+
+func TestNewNet(t *testing.T) {
+	a := assert.New(t)
+
+	n, err := NewNet("http", "127.0.0.1:80")
+	a.Error(err).Nil(n)
+
+	n, err = NewNet("tcp", "127.0.0.1:80")
+	a.NotError(err).NotNil(n)
+	a.True(n.stream)
+
+	n, err = NewNet("unixgram", "/dev/log")
+	a.NotError(err).NotNil(n)
+	a.False(n.stream)
+	a.Error(n.SetMaxDatagram(0))
+}
+
+// 服务端在写入之后才启动，之前的内容被缓存
+func TestNet_tcp(t *testing.T) {
+	a := assert.New(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NotError(err)
+	addr := l.Addr().String()
+	l.Close()
+
+	n, err := NewNet("tcp", addr)
+	a.NotError(err)
+	n.SetBackoff(time.Hour, time.Hour)
+
+	size, err := n.Write([]byte("1\n"))
+	a.NotError(err).Equal(size, 2)
+	size, err = n.Write([]byte("2\n"))
+	a.NotError(err).Equal(size, 2)
+	a.Equal(len(n.backlog.items), 2).Equal(n.backlog.failures, 1)
+
+	l, err = net.Listen("tcp", addr)
+	a.NotError(err)
+	defer l.Close()
+
+	// 在等待时间内，不会重连
+	n.Write([]byte("3\n"))
+	a.Equal(len(n.backlog.items), 3)
+
+	// Flush 忽略等待时间
+	size, err = n.Flush()
+	a.NotError(err).Equal(size, 6)
+	a.Equal(len(n.backlog.items), 0).Equal(n.backlog.failures, 0)
+
+	conn, err := l.Accept()
+	a.NotError(err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	r := bufio.NewReader(conn)
+	for _, line := range []string{"1\n", "2\n", "3\n"} {
+		str, err := r.ReadString('\n')
+		a.NotError(err).Equal(str, line)
+	}
+
+	n.Write([]byte("4\n"))
+	str, err := r.ReadString('\n')
+	a.NotError(err).Equal(str, "4\n")
+
+	a.NotError(n.Close()).NotError(n.Close())
+}
+
+func TestNet_buffer(t *testing.T) {
+	a := assert.New(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NotError(err)
+	addr := l.Addr().String()
+	l.Close()
+
+	n, err := NewNet("tcp", addr)
+	a.NotError(err)
+	n.SetBackoff(time.Hour, time.Hour)
+	n.SetBuffer(5)
+
+	n.Write([]byte("12\n"))
+	n.Write([]byte("34\n"))
+	a.Equal(n.Dropped(), 1).
+		Equal(n.backlog.size, 3).
+		Equal(n.backlog.items, [][]byte{[]byte("34\n")})
+
+	_, err = n.Flush()
+	a.Error(err)
+	a.NotError(n.Close())
+}
+
+// 最多写入 limit 个字节之后返回错误的连接
+type netTestConn struct {
+	net.Conn
+	limit int
+	buf   []byte
+}
+
+func (c *netTestConn) Write(data []byte) (int, error) {
+	if len(data) > c.limit {
+		c.buf = append(c.buf, data[:c.limit]...)
+		return c.limit, errors.New("broken pipe")
+	}
+
+	c.limit -= len(data)
+	c.buf = append(c.buf, data...)
+	return len(data), nil
+}
+
+func (c *netTestConn) SetWriteDeadline(time.Time) error { return nil }
+
+func (c *netTestConn) Close() error { return nil }
+
+func TestNet_writeError(t *testing.T) {
+	a := assert.New(t)
+
+	n, err := NewNet("tcp", "127.0.0.1:0")
+	a.NotError(err)
+	n.SetBackoff(time.Hour, time.Hour)
+
+	// 只写入了一部分的内容被丢弃，之后的内容依然保留
+	conn := &netTestConn{limit: 5}
+	n.conn = conn
+	n.backlog.items = [][]byte{[]byte("1\n"), []byte("2345\n"), []byte("6\n")}
+	n.backlog.size = 9
+	_, err = n.Flush()
+	a.Error(err)
+	a.Equal(string(conn.buf), "1\n234")
+	a.Equal(n.backlog.items, [][]byte{[]byte("6\n")}).
+		Equal(n.backlog.size, 2).
+		Equal(n.Dropped(), 1)
+
+	// 写入失败之后需要等待 backoff 才会重连
+	a.Nil(n.conn).Equal(n.backlog.failures, 1)
+	a.True(n.backlog.next.After(time.Now().Add(time.Minute)))
+	n.Write([]byte("7\n"))
+	a.Equal(len(n.backlog.items), 2).Equal(n.backlog.failures, 1)
+
+	// 一个字节都没有写入时，保留该内容
+	n.conn = &netTestConn{limit: 0}
+	_, err = n.Flush()
+	a.Error(err)
+	a.Equal(len(n.backlog.items), 2).Equal(n.Dropped(), 1).Equal(n.backlog.failures, 2)
+}
+
+// 超过 maxDatagram 的内容被拆分
+func TestNet_udp(t *testing.T) {
+	a := assert.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	a.NotError(err)
+	defer conn.Close()
+
+	n, err := NewNet("udp", conn.LocalAddr().String())
+	a.NotError(err)
+	a.NotError(n.SetMaxDatagram(4))
+
+	size, err := n.Write([]byte("0123456789\n"))
+	a.NotError(err).Equal(size, 11)
+
+	buf := make([]byte, 100)
+	for _, want := range []string{"0123", "4567", "89\n"} {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		size, _, err := conn.ReadFrom(buf)
+		a.NotError(err).Equal(string(buf[:size]), want)
+	}
+
+	a.NotError(n.Close())
+}
+
+func TestNet_unix(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "logs-net")
+	a.NotError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "socket")
+	l, err := net.Listen("unix", path)
+	a.NotError(err)
+	defer l.Close()
+
+	n, err := NewNet("unix", path)
+	a.NotError(err)
+	n.Write([]byte("hello\n"))
+
+	conn, err := l.Accept()
+	a.NotError(err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	str, err := bufio.NewReader(conn).ReadString('\n')
+	a.NotError(err).Equal(str, "hello\n")
+
+	a.NotError(n.Close())
+}
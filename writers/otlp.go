@@ -0,0 +1,413 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLP 请求的内容格式
+const (
+	OTLPFormatProtobuf = iota // protobuf 格式
+	OTLPFormatJSON            // JSON 格式
+)
+
+const (
+	otlpLogsPath       = "/v1/logs"
+	otlpScope          = "github.com/issue9/logs"
+	defaultOTLPTimeout = 10 * time.Second
+)
+
+// 日志级别与 SeverityNumber 的对应关系
+var otlpSeverities = map[string]int{
+	"trace":    1,
+	"debug":    5,
+	"info":     9,
+	"warn":     13,
+	"error":    17,
+	"critical": 21,
+}
+
+// 通过 OTLP/HTTP 协议将日志导出到 OpenTelemetry Collector 等服务。
+//
+// 日志级别会被转换成对应的 SeverityNumber，调用位置会被转换成
+// code.filepath 和 code.lineno 属性。Write 只是将日志放入队列，
+// 由后台的 goroutine 合并发送。按照规范，服务端返回 429、502、503
+// 和 504 时会进行重试，并优先使用 Retry-After 报头指定的等待时间，
+// 其它的错误状态码则直接丢弃该批次的日志。
+type OTLP struct {
+	url        string
+	format     int
+	gzip       bool
+	header     http.Header
+	resource   map[string]string
+	attributes map[string]string
+	client     *http.Client
+
+	severity int
+	text     string
+
+	batchWriter
+}
+
+// 队列中的每一条日志
+type otlpEntry struct {
+	Time     int64  `json:"t"` // 纳秒
+	Observed int64  `json:"o"` // 纳秒
+	Severity int    `json:"n,omitempty"`
+	Text     string `json:"s,omitempty"`
+	Body     string `json:"m"`
+	File     string `json:"f,omitempty"`
+	Line     int    `json:"l,omitempty"`
+}
+
+// 新建 OTLP 实例，url 为服务地址，比如 http://localhost:4318，
+// 未以 /v1/logs 结尾时会自动加上。
+//
+// 默认的资源属性为 service.name 和 host.name。
+func NewOTLP(url string) *OTLP {
+	url = strings.TrimRight(url, "/")
+	if !strings.HasSuffix(url, otlpLogsPath) {
+		url += otlpLogsPath
+	}
+
+	resource := map[string]string{
+		"service.name": "unknown_service:" + filepath.Base(os.Args[0]),
+	}
+	if host, err := os.Hostname(); err == nil {
+		resource["host.name"] = host
+	}
+
+	o := &OTLP{
+		url:        url,
+		format:     OTLPFormatProtobuf,
+		header:     http.Header{},
+		resource:   resource,
+		attributes: map[string]string{},
+		client:     &http.Client{Timeout: defaultOTLPTimeout},
+	}
+	o.batchWriter = newBatchWriter(o.send)
+	return o
+}
+
+// 设置请求内容的格式，可以是 OTLPFormatProtobuf 或是 OTLPFormatJSON。
+func (o *OTLP) SetFormat(format int) error {
+	if format != OTLPFormatProtobuf && format != OTLPFormatJSON {
+		return fmt.Errorf("无效的format值:[%v]", format)
+	}
+
+	o.format = format
+	return nil
+}
+
+// 是否使用 gzip 压缩请求内容。
+func (o *OTLP) SetGzip(gzip bool) {
+	o.gzip = gzip
+}
+
+// 添加一个自定义的报头，每次请求都会带上，一般用于验证。
+func (o *OTLP) SetHeader(name, value string) {
+	o.header.Add(name, value)
+}
+
+// 设置资源属性，比如 service.name，value 为空表示删除该属性。
+// 只能在第一次调用 Write 之前设置。
+func (o *OTLP) SetResource(name, value string) error {
+	return setOTLPAttribute(o.resource, name, value)
+}
+
+// 设置每条日志都会带上的属性，value 为空表示删除该属性。
+// 只能在第一次调用 Write 之前设置。
+func (o *OTLP) SetAttribute(name, value string) error {
+	return setOTLPAttribute(o.attributes, name, value)
+}
+
+func setOTLPAttribute(attrs map[string]string, name, value string) error {
+	if name == "" {
+		return fmt.Errorf("无效的属性名:[%v]", name)
+	}
+
+	if value == "" {
+		delete(attrs, name)
+	} else {
+		attrs[name] = value
+	}
+	return nil
+}
+
+// 设置每次请求的超时时间，默认为 10 秒。
+func (o *OTLP) SetTimeout(timeout time.Duration) {
+	o.client.Timeout = timeout
+}
+
+// 设置 http.Client 使用的 Transport，可用于指定 TLS 等配置。
+func (o *OTLP) SetTransport(transport http.RoundTripper) {
+	o.client.Transport = transport
+}
+
+// Leveler.SetLevel()
+func (o *OTLP) SetLevel(level, prefix string, flag int) {
+	o.severity = otlpSeverities[level]
+	o.text = strings.ToUpper(level)
+	o.batchWriter.SetLevel(level, prefix, flag)
+}
+
+// io.Writer
+func (o *OTLP) Write(msg []byte) (int, error) {
+	now := time.Now()
+	r := parseRecord(msg, o.prefix, o.flag)
+	t, ok := r.parseTime()
+	if !ok {
+		t = now
+	}
+
+	entry := &otlpEntry{
+		Time:     t.UnixNano(),
+		Observed: now.UnixNano(),
+		Severity: o.severity,
+		Text:     o.text,
+		Body:     string(r.bytes(r.message)),
+	}
+	if !r.caller.empty() {
+		caller := string(r.bytes(r.caller))
+		entry.File = caller
+		if index := strings.LastIndexByte(caller, ':'); index > 0 {
+			if line, err := strconv.Atoi(caller[index+1:]); err == nil {
+				entry.File = caller[:index]
+				entry.Line = line
+			}
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	o.push(data)
+	return len(msg), nil
+}
+
+func (o *OTLP) send(items [][]byte) error {
+	entries := make([]*otlpEntry, 0, len(items))
+	for _, item := range items {
+		entry := &otlpEntry{}
+		if err := json.Unmarshal(item, entry); err != nil {
+			return &noRetryError{err: err}
+		}
+		entries = append(entries, entry)
+	}
+
+	var body []byte
+	var err error
+	contentType := "application/x-protobuf"
+	if o.format == OTLPFormatJSON {
+		contentType = "application/json"
+		if body, err = o.json(entries); err != nil {
+			return &noRetryError{err: err}
+		}
+	} else {
+		body = o.protobuf(entries)
+	}
+
+	if o.gzip {
+		buf := new(bytes.Buffer)
+		gw := gzip.NewWriter(buf)
+		gw.Write(body)
+		if err = gw.Close(); err != nil {
+			return &noRetryError{err: err}
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return &noRetryError{err: err}
+	}
+	for name, values := range o.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+	if o.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil { // 网络错误，需要重试
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	// 部分日志被拒绝时同样返回 2xx，按规范不能重试。
+	if resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("服务端返回错误的状态码:[%v]", resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if wait := parseRetryAfter(resp.Header.Get("Retry-After")); wait > 0 {
+			return &retryAfterError{err: err, wait: wait}
+		}
+		return err
+	}
+	return &noRetryError{err: err}
+}
+
+// 构造 JSON 格式的请求内容，int64 类型的值按规范以字符串表示：
+//
+//	{"resourceLogs":[{"resource":{...},"scopeLogs":[{"scope":{...},"logRecords":[...]}]}]}
+func (o *OTLP) json(entries []*otlpEntry) ([]byte, error) {
+	type keyValue struct {
+		Key   string            `json:"key"`
+		Value map[string]string `json:"value"`
+	}
+
+	type logRecord struct {
+		Time       string            `json:"timeUnixNano"`
+		Observed   string            `json:"observedTimeUnixNano"`
+		Severity   int               `json:"severityNumber,omitempty"`
+		Text       string            `json:"severityText,omitempty"`
+		Body       map[string]string `json:"body"`
+		Attributes []*keyValue       `json:"attributes,omitempty"`
+	}
+
+	attrs := func(m map[string]string) []*keyValue {
+		kvs := make([]*keyValue, 0, len(m))
+		for _, name := range sortedOTLPNames(m) {
+			kvs = append(kvs, &keyValue{Key: name, Value: map[string]string{"stringValue": m[name]}})
+		}
+		return kvs
+	}
+
+	records := make([]*logRecord, 0, len(entries))
+	for _, entry := range entries {
+		r := &logRecord{
+			Time:       strconv.FormatInt(entry.Time, 10),
+			Observed:   strconv.FormatInt(entry.Observed, 10),
+			Severity:   entry.Severity,
+			Text:       entry.Text,
+			Body:       map[string]string{"stringValue": entry.Body},
+			Attributes: attrs(o.attributes),
+		}
+		if entry.File != "" {
+			r.Attributes = append(r.Attributes, &keyValue{Key: "code.filepath", Value: map[string]string{"stringValue": entry.File}})
+		}
+		if entry.Line > 0 {
+			r.Attributes = append(r.Attributes, &keyValue{Key: "code.lineno", Value: map[string]string{"intValue": strconv.Itoa(entry.Line)}})
+		}
+		records = append(records, r)
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"resourceLogs": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{"attributes": attrs(o.resource)},
+				"scopeLogs": []interface{}{
+					map[string]interface{}{
+						"scope":      map[string]string{"name": otlpScope},
+						"logRecords": records,
+					},
+				},
+			},
+		},
+	})
+}
+
+// 构造 protobuf 格式的请求内容：
+//
+//	ExportLogsServiceRequest { repeated ResourceLogs resource_logs = 1; }
+//	ResourceLogs { Resource resource = 1; repeated ScopeLogs scope_logs = 2; }
+//	Resource { repeated KeyValue attributes = 1; }
+//	ScopeLogs { InstrumentationScope scope = 1; repeated LogRecord log_records = 2; }
+//	InstrumentationScope { string name = 1; }
+//	LogRecord { fixed64 time_unix_nano = 1; SeverityNumber severity_number = 2;
+//	            string severity_text = 3; AnyValue body = 5; repeated KeyValue attributes = 6;
+//	            fixed64 observed_time_unix_nano = 11; }
+//	KeyValue { string key = 1; AnyValue value = 2; }
+//	AnyValue { oneof { string string_value = 1; int64 int_value = 3; } }
+func (o *OTLP) protobuf(entries []*otlpEntry) []byte {
+	resource := &pbEncoder{}
+	for _, name := range sortedOTLPNames(o.resource) {
+		resource.message(1, otlpStringKeyValue(name, o.resource[name]))
+	}
+
+	scope := &pbEncoder{}
+	scope.string(1, otlpScope)
+
+	scopeLogs := &pbEncoder{}
+	scopeLogs.message(1, scope.buf)
+
+	names := sortedOTLPNames(o.attributes)
+	for _, entry := range entries {
+		body := &pbEncoder{}
+		body.string(1, entry.Body)
+
+		r := &pbEncoder{}
+		r.fixed64(1, uint64(entry.Time))
+		r.uint(2, uint64(entry.Severity))
+		r.string(3, entry.Text)
+		r.message(5, body.buf)
+		for _, name := range names {
+			r.message(6, otlpStringKeyValue(name, o.attributes[name]))
+		}
+		if entry.File != "" {
+			r.message(6, otlpStringKeyValue("code.filepath", entry.File))
+		}
+		if entry.Line > 0 {
+			v := &pbEncoder{}
+			v.uint(3, uint64(entry.Line))
+
+			kv := &pbEncoder{}
+			kv.string(1, "code.lineno")
+			kv.message(2, v.buf)
+			r.message(6, kv.buf)
+		}
+		r.fixed64(11, uint64(entry.Observed))
+
+		scopeLogs.message(2, r.buf)
+	}
+
+	resourceLogs := &pbEncoder{}
+	resourceLogs.message(1, resource.buf)
+	resourceLogs.message(2, scopeLogs.buf)
+
+	req := &pbEncoder{}
+	req.message(1, resourceLogs.buf)
+	return req.buf
+}
+
+// 编码值为字符串的 KeyValue
+func otlpStringKeyValue(key, value string) []byte {
+	v := &pbEncoder{}
+	v.string(1, value)
+
+	kv := &pbEncoder{}
+	kv.string(1, key)
+	kv.message(2, v.buf)
+	return kv.buf
+}
+
+func sortedOTLPNames(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,279 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Leveler        = &OTLP{}
+	_ Flusher        = &OTLP{}
+	_ io.WriteCloser = &OTLP{}
+)
+
+// 模拟 OTLP/HTTP 的接收端
+type otlpServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	headers []http.Header
+	bodies  [][]byte
+	status  []int
+	after   string // Retry-After 报头
+}
+
+func newOTLPServer() *otlpServer {
+	s := &otlpServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.URL.Path != otlpLogsPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		s.headers = append(s.headers, r.Header)
+		s.bodies = append(s.bodies, body)
+		if len(s.status) > 0 {
+			if s.after != "" {
+				w.Header().Set("Retry-After", s.after)
+			}
+			w.WriteHeader(s.status[0])
+			s.status = s.status[1:]
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return s
+}
+
+func (s *otlpServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+// 将 KeyValue 列表解析成 map，整数值转换成字符串
+func otlpDecodeKeyValues(a *assert.Assertion, fields []*pbField, num int) map[string]string {
+	m := map[string]string{}
+	for _, f := range fields {
+		if f.num != num {
+			continue
+		}
+
+		kv, err := pbDecode(f.data)
+		a.NotError(err).Equal(len(kv), 2)
+		value, err := pbDecode(kv[1].data)
+		a.NotError(err).Equal(len(value), 1)
+
+		switch value[0].num {
+		case 1:
+			m[string(kv[0].data)] = string(value[0].data)
+		case 3:
+			m[string(kv[0].data)] = strconv.FormatUint(value[0].value, 10)
+		}
+	}
+	return m
+}
+
+func TestNewOTLP(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal(NewOTLP("http://localhost:4318").url, "http://localhost:4318/v1/logs")
+	a.Equal(NewOTLP("http://localhost:4318/").url, "http://localhost:4318/v1/logs")
+	a.Equal(NewOTLP("http://localhost:4318/v1/logs").url, "http://localhost:4318/v1/logs")
+
+	o := NewOTLP("http://localhost:4318")
+	a.NotEmpty(o.resource["service.name"])
+	a.NotError(o.SetResource("service.name", "app")).
+		NotError(o.SetResource("host.name", "")).
+		Error(o.SetResource("", "x"))
+	a.Equal(o.resource, map[string]string{"service.name": "app"})
+	a.NotError(o.SetFormat(OTLPFormatJSON)).Error(o.SetFormat(10))
+}
+
+func TestOTLP_protobuf(t *testing.T) {
+	a := assert.New(t)
+	srv := newOTLPServer()
+	defer srv.Close()
+
+	o := NewOTLP(srv.URL)
+	a.NotError(o.SetResource("service.name", "app")).
+		NotError(o.SetResource("host.name", "")).
+		NotError(o.SetAttribute("env", "dev"))
+	o.SetHeader("Authorization", "token")
+	o.SetLevel("warn", "[WARN]", log.Lshortfile)
+	o.Write([]byte("[WARN]otlp_test.go:10: m1\n"))
+	o.Flush()
+	defer o.Close()
+
+	a.Equal(srv.count(), 1)
+	a.Equal(srv.headers[0].Get("Content-Type"), "application/x-protobuf").
+		Equal(srv.headers[0].Get("Authorization"), "token")
+
+	// ExportLogsServiceRequest
+	fields, err := pbDecode(srv.bodies[0])
+	a.NotError(err).Equal(len(fields), 1).Equal(fields[0].num, 1)
+
+	// ResourceLogs
+	fields, err = pbDecode(fields[0].data)
+	a.NotError(err).Equal(len(fields), 2)
+
+	resource, err := pbDecode(fields[0].data)
+	a.NotError(err)
+	a.Equal(otlpDecodeKeyValues(a, resource, 1), map[string]string{"service.name": "app"})
+
+	// ScopeLogs
+	fields, err = pbDecode(fields[1].data)
+	a.NotError(err).Equal(len(fields), 2)
+	scope, err := pbDecode(fields[0].data)
+	a.NotError(err).Equal(string(scope[0].data), otlpScope)
+
+	// LogRecord
+	fields, err = pbDecode(fields[1].data)
+	a.NotError(err)
+	record := map[int]*pbField{}
+	for _, f := range fields {
+		record[f.num] = f
+	}
+	a.True(record[1].value > 0).
+		True(record[11].value >= record[1].value).
+		Equal(record[2].value, 13).
+		Equal(string(record[3].data), "WARN")
+
+	body, err := pbDecode(record[5].data)
+	a.NotError(err).Equal(string(body[0].data), "m1")
+
+	a.Equal(otlpDecodeKeyValues(a, fields, 6), map[string]string{
+		"env":           "dev",
+		"code.filepath": "otlp_test.go",
+		"code.lineno":   "10",
+	})
+}
+
+func TestOTLP_json(t *testing.T) {
+	a := assert.New(t)
+	srv := newOTLPServer()
+	defer srv.Close()
+
+	o := NewOTLP(srv.URL)
+	a.NotError(o.SetFormat(OTLPFormatJSON))
+	a.NotError(o.SetBatch(10, time.Hour))
+	a.NotError(o.SetResource("service.name", "app"))
+	o.SetGzip(true)
+	o.SetLevel("info", "[INFO]", 0)
+	o.Write([]byte("[INFO]m1\n"))
+	o.Write([]byte("[INFO]m2\n"))
+	o.Flush()
+	defer o.Close()
+
+	a.Equal(srv.count(), 1)
+	a.Equal(srv.headers[0].Get("Content-Type"), "application/json").
+		Equal(srv.headers[0].Get("Content-Encoding"), "gzip")
+
+	r, err := gzip.NewReader(bytes.NewReader(srv.bodies[0]))
+	a.NotError(err)
+	data, err := ioutil.ReadAll(r)
+	a.NotError(err)
+
+	type keyValue struct {
+		Key   string            `json:"key"`
+		Value map[string]string `json:"value"`
+	}
+	req := &struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []*keyValue `json:"attributes"`
+			} `json:"resource"`
+			ScopeLogs []struct {
+				Scope struct {
+					Name string `json:"name"`
+				} `json:"scope"`
+				LogRecords []struct {
+					Time     string            `json:"timeUnixNano"`
+					Severity int               `json:"severityNumber"`
+					Text     string            `json:"severityText"`
+					Body     map[string]string `json:"body"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}{}
+	a.NotError(json.Unmarshal(data, req))
+	a.Equal(len(req.ResourceLogs), 1)
+
+	rl := req.ResourceLogs[0]
+	attrs := map[string]string{}
+	for _, kv := range rl.Resource.Attributes {
+		attrs[kv.Key] = kv.Value["stringValue"]
+	}
+	a.Equal(attrs["service.name"], "app")
+
+	a.Equal(len(rl.ScopeLogs), 1)
+	sl := rl.ScopeLogs[0]
+	a.Equal(sl.Scope.Name, otlpScope).Equal(len(sl.LogRecords), 2)
+
+	lr := sl.LogRecords[0]
+	a.Equal(lr.Severity, 9).
+		Equal(lr.Text, "INFO").
+		Equal(lr.Body["stringValue"], "m1")
+	ts, err := strconv.ParseInt(lr.Time, 10, 64)
+	a.NotError(err).True(ts > 0)
+	a.Equal(sl.LogRecords[1].Body["stringValue"], "m2")
+}
+
+func TestOTLP_retry(t *testing.T) {
+	a := assert.New(t)
+	srv := newOTLPServer()
+	defer srv.Close()
+
+	o := NewOTLP(srv.URL)
+	a.NotError(o.SetQueue(10, 2, time.Millisecond))
+
+	// 429、502、503 和 504 需要重试
+	srv.mu.Lock()
+	srv.status = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	srv.mu.Unlock()
+	o.Write([]byte("m1\n"))
+	o.Flush()
+	a.Equal(srv.count(), 3).Equal(o.Dropped(), 0)
+
+	// 500 不重试
+	srv.mu.Lock()
+	srv.status = []int{http.StatusInternalServerError}
+	srv.mu.Unlock()
+	o.Write([]byte("m2\n"))
+	o.Flush()
+	a.Equal(srv.count(), 4).Equal(o.Dropped(), 1)
+
+	// Retry-After
+	srv.mu.Lock()
+	srv.status = []int{http.StatusServiceUnavailable}
+	srv.after = "1"
+	srv.mu.Unlock()
+	start := time.Now()
+	o.Write([]byte("m3\n"))
+	o.Flush()
+	a.Equal(srv.count(), 6).Equal(o.Dropped(), 1)
+	a.True(time.Since(start) >= time.Second)
+
+	a.NotError(o.Close())
+}
@@ -0,0 +1,81 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+// protobuf 的数据类型
+const (
+	pbVarint  = 0
+	pbFixed64 = 1
+	pbBytes   = 2
+)
+
+// 简单的 protobuf 编码器，仅实现了输出日志需要用到的类型。
+//
+// 嵌套的消息需要先编码成 []byte，再通过 message() 写入。
+type pbEncoder struct {
+	buf []byte
+}
+
+func (e *pbEncoder) varint(v uint64) {
+	for v >= 0x80 {
+		e.buf = append(e.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *pbEncoder) tag(field, typ int) {
+	e.varint(uint64(field)<<3 | uint64(typ))
+}
+
+// 写入 int32、int64、uint32、uint64、bool 和 enum 类型的字段，为 0 时忽略。
+func (e *pbEncoder) uint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+
+	e.tag(field, pbVarint)
+	e.varint(v)
+}
+
+// 写入 fixed64 类型的字段，为 0 时忽略。
+func (e *pbEncoder) fixed64(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+
+	e.tag(field, pbFixed64)
+	for i := 0; i < 8; i++ {
+		e.buf = append(e.buf, byte(v>>(8*uint(i))))
+	}
+}
+
+// 写入 bytes 或是嵌套的消息，为空时忽略。
+func (e *pbEncoder) bytes(field int, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+
+	e.tag(field, pbBytes)
+	e.varint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// 写入嵌套的消息，即使内容为空也会写入。
+func (e *pbEncoder) message(field int, v []byte) {
+	e.tag(field, pbBytes)
+	e.varint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *pbEncoder) string(field int, v string) {
+	if v == "" {
+		return
+	}
+
+	e.tag(field, pbBytes)
+	e.varint(uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
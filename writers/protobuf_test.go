@@ -0,0 +1,93 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/issue9/assert"
+)
+
+// 解码后的字段，供测试使用
+type pbField struct {
+	num   int
+	typ   int
+	value uint64 // varint 和 fixed64
+	data  []byte // bytes
+}
+
+// 解析 protobuf 消息的所有字段，不作嵌套解析
+func pbDecode(data []byte) ([]*pbField, error) {
+	fields := []*pbField{}
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("无效的 tag")
+		}
+		data = data[n:]
+
+		f := &pbField{num: int(tag >> 3), typ: int(tag & 7)}
+		switch f.typ {
+		case pbVarint:
+			if f.value, n = binary.Uvarint(data); n <= 0 {
+				return nil, errors.New("无效的 varint")
+			}
+			data = data[n:]
+		case pbFixed64:
+			if len(data) < 8 {
+				return nil, errors.New("无效的 fixed64")
+			}
+			f.value = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case pbBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return nil, errors.New("无效的 bytes")
+			}
+			f.data = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			return nil, errors.New("不支持的类型")
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+func TestPbEncoder(t *testing.T) {
+	a := assert.New(t)
+
+	e := &pbEncoder{}
+	e.uint(1, 150)
+	a.Equal(e.buf, []byte{0x08, 0x96, 0x01})
+
+	e = &pbEncoder{}
+	e.string(2, "testing")
+	a.Equal(e.buf, []byte{0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g'})
+
+	// 零值被忽略
+	e = &pbEncoder{}
+	e.uint(1, 0)
+	e.string(2, "")
+	e.bytes(3, nil)
+	e.fixed64(4, 0)
+	a.Empty(e.buf)
+
+	// 空的嵌套消息
+	e.message(5, nil)
+	a.Equal(e.buf, []byte{0x2a, 0x00})
+
+	e = &pbEncoder{}
+	e.fixed64(1, 1)
+	e.bytes(2, []byte{1, 2})
+	fields, err := pbDecode(e.buf)
+	a.NotError(err).Equal(len(fields), 2)
+	a.Equal(fields[0].num, 1).Equal(fields[0].typ, pbFixed64).Equal(fields[0].value, 1)
+	a.Equal(fields[1].num, 2).Equal(fields[1].data, []byte{1, 2})
+}
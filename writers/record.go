@@ -0,0 +1,127 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"log"
+	"time"
+)
+
+// 表示一段内容在原始数据中的位置，[start,end)
+type span struct {
+	start, end int
+}
+
+func (s span) empty() bool {
+	return s.end <= s.start
+}
+
+// 由 log.Logger 输出的一条日志。
+//
+// 根据 log.Logger 的 prefix 和 flag 参数，将内容拆分成前缀、时间、
+// 文件名和消息等部分，各部分均以位置的形式引用原始内容。
+type record struct {
+	data    []byte
+	flag    int
+	prefix  span
+	time    span // 日期和时间，不包含其后的空格
+	caller  span // 文件名和行号，不包含其后的冒号
+	message span // 消息内容，不包含最后的换行符
+}
+
+// 解析 data 的内容，prefix 和 flag 为输出 data 的 log.Logger 的参数。
+// 无法识别的部分都会被当作消息内容。
+func parseRecord(data []byte, prefix string, flag int) *record {
+	r := &record{data: data, flag: flag}
+	pos := 0
+
+	msgPrefix := flag&log.Lmsgprefix != 0
+	if !msgPrefix && prefix != "" && bytes.HasPrefix(data, []byte(prefix)) {
+		r.prefix = span{0, len(prefix)}
+		pos = len(prefix)
+	}
+
+	if flag&(log.Ldate|log.Ltime|log.Lmicroseconds) != 0 {
+		size := 0
+		if flag&log.Ldate != 0 {
+			size += len("2006/01/02 ")
+		}
+		if flag&(log.Ltime|log.Lmicroseconds) != 0 {
+			size += len("15:04:05 ")
+			if flag&log.Lmicroseconds != 0 {
+				size += len(".000000")
+			}
+		}
+
+		if pos+size <= len(data) {
+			r.time = span{pos, pos + size - 1}
+			pos += size
+		}
+	}
+
+	if flag&(log.Lshortfile|log.Llongfile) != 0 {
+		if index := bytes.Index(data[pos:], []byte(": ")); index >= 0 {
+			r.caller = span{pos, pos + index}
+			pos += index + 2
+		}
+	}
+
+	if msgPrefix && prefix != "" && bytes.HasPrefix(data[pos:], []byte(prefix)) {
+		r.prefix = span{pos, pos + len(prefix)}
+		pos += len(prefix)
+	}
+
+	end := len(data)
+	if end > pos && data[end-1] == '\n' {
+		end--
+	}
+	r.message = span{pos, end}
+
+	return r
+}
+
+func (r *record) bytes(s span) []byte {
+	return r.data[s.start:s.end]
+}
+
+// 解析时间部分的内容，不存在时间内容时，返回 false。
+// 仅有时间没有日期时，日期部分为当天。
+func (r *record) parseTime() (time.Time, bool) {
+	if r.time.empty() {
+		return time.Time{}, false
+	}
+
+	layout := ""
+	if r.flag&log.Ldate != 0 {
+		layout = "2006/01/02"
+	}
+	if r.flag&(log.Ltime|log.Lmicroseconds) != 0 {
+		if layout != "" {
+			layout += " "
+		}
+		layout += "15:04:05"
+		if r.flag&log.Lmicroseconds != 0 {
+			layout += ".000000"
+		}
+	}
+
+	loc := time.Local
+	if r.flag&log.LUTC != 0 {
+		loc = time.UTC
+	}
+
+	t, err := time.ParseInLocation(layout, string(r.bytes(r.time)), loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	if r.flag&log.Ldate == 0 {
+		now := time.Now().In(loc)
+		t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+	}
+
+	return t, true
+}
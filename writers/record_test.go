@@ -0,0 +1,53 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+func TestParseRecord(t *testing.T) {
+	a := assert.New(t)
+	buf := new(bytes.Buffer)
+
+	parse := func(prefix string, flag int, msg string) *record {
+		buf.Reset()
+		log.New(buf, prefix, flag).Output(1, msg)
+		return parseRecord(buf.Bytes(), prefix, flag)
+	}
+
+	r := parse("[INFO]", log.LstdFlags|log.Lshortfile, "hello world")
+	a.Equal(string(r.bytes(r.prefix)), "[INFO]").
+		Equal(len(r.bytes(r.time)), len("2006/01/02 15:04:05")).
+		Equal(string(r.bytes(r.caller))[:len("record_test.go:")], "record_test.go:").
+		Equal(string(r.bytes(r.message)), "hello world")
+	tm, ok := r.parseTime()
+	a.True(ok).True(time.Since(tm) < time.Minute)
+
+	// Lmsgprefix
+	r = parse("[INFO] ", log.Ltime|log.Lmicroseconds|log.Lmsgprefix, "hello")
+	a.Equal(string(r.bytes(r.prefix)), "[INFO] ").
+		Equal(len(r.bytes(r.time)), len("15:04:05.000000")).
+		True(r.caller.empty()).
+		Equal(string(r.bytes(r.message)), "hello")
+	tm, ok = r.parseTime()
+	a.True(ok).True(time.Since(tm) < time.Minute)
+
+	// 无 flag，多行内容
+	r = parse("", 0, "line1\nline2")
+	a.True(r.prefix.empty()).True(r.time.empty()).
+		Equal(string(r.bytes(r.message)), "line1\nline2")
+	_, ok = r.parseTime()
+	a.False(ok)
+
+	// prefix 不匹配时，当作消息内容
+	r = parseRecord([]byte("abc\n"), "[INFO]", 0)
+	a.True(r.prefix.empty()).Equal(string(r.bytes(r.message)), "abc")
+}
@@ -0,0 +1,376 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Redis 的写入方式
+const (
+	RedisList   = iota // 通过 RPUSH 写入列表
+	RedisStream        // 通过 XADD 写入流
+)
+
+const defaultRedisTimeout = 10 * time.Second
+
+// 以下错误表示服务端暂时不可用，可以进行重试
+var redisRetryErrors = []string{"LOADING", "BUSY", "TRYAGAIN", "MASTERDOWN", "CLUSTERDOWN"}
+
+// 将日志写入 Redis 的列表或是流中。
+//
+// 列表中的每一项为 {"time":"...","level":"...","caller":"...","message":"..."}
+// 格式的 JSON，流中的每一项则包含 time、level、caller 和 message 字段。
+// Write 只是将日志放入队列，由后台的 goroutine 通过 pipeline 合并发送，
+// 连接断开时会在下次发送时重新连接，并按 backoff 进行重试。
+type Redis struct {
+	network   string
+	addr      string
+	key       string
+	mode      int
+	maxLen    int
+	username  string
+	password  string
+	db        int
+	tlsConfig *tls.Config
+	timeout   time.Duration
+
+	batchWriter
+
+	// 仅在后台的 goroutine 中使用
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// 服务端返回的错误信息
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// 新建 Redis 实例。
+// network 可以是 tcp、tcp4、tcp6 和 unix；key 为列表或是流的键名。
+func NewRedis(network, addr, key string) (*Redis, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, fmt.Errorf("无效的network值:[%v]", network)
+	}
+
+	if key == "" {
+		return nil, fmt.Errorf("无效的key值:[%v]", key)
+	}
+
+	r := &Redis{
+		network: network,
+		addr:    addr,
+		key:     key,
+		mode:    RedisList,
+		timeout: defaultRedisTimeout,
+	}
+	r.batchWriter = newBatchWriter(r.send)
+	return r, nil
+}
+
+// 设置写入方式，可以是 RedisList 或是 RedisStream。
+//
+// maxLen 为列表或流的最大长度，超出时删除最早的内容，为 0 表示不限制。
+// 列表通过 LTRIM 截断；流则使用 XADD 的 MAXLEN ~ 参数，实际长度可能略大于 maxLen。
+func (r *Redis) SetMode(mode, maxLen int) error {
+	if mode != RedisList && mode != RedisStream {
+		return fmt.Errorf("无效的mode值:[%v]", mode)
+	}
+
+	if maxLen < 0 {
+		return fmt.Errorf("无效的maxLen值:[%v]", maxLen)
+	}
+
+	r.mode = mode
+	r.maxLen = maxLen
+	return nil
+}
+
+// 设置验证信息，username 为空时表示只使用密码验证。
+func (r *Redis) SetAuth(username, password string) {
+	r.username = username
+	r.password = password
+}
+
+// 设置使用的数据库，默认为 0。
+func (r *Redis) SetDB(db int) error {
+	if db < 0 {
+		return fmt.Errorf("无效的db值:[%v]", db)
+	}
+
+	r.db = db
+	return nil
+}
+
+// 设置 TLS 配置，仅对 tcp 有效。
+func (r *Redis) SetTLS(conf *tls.Config) {
+	r.tlsConfig = conf
+}
+
+// 设置连接、写入以及读取的超时时间，默认为 10 秒。
+func (r *Redis) SetTimeout(timeout time.Duration) {
+	r.timeout = timeout
+}
+
+// io.Writer
+func (r *Redis) Write(msg []byte) (int, error) {
+	rec := parseRecord(msg, r.prefix, r.flag)
+
+	t, ok := rec.parseTime()
+	if !ok {
+		t = time.Now()
+	}
+
+	data, err := json.Marshal(&httpRecord{
+		Time:    t.Format(time.RFC3339Nano),
+		Level:   r.level,
+		Caller:  string(rec.bytes(rec.caller)),
+		Message: string(rec.bytes(rec.message)),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	r.push(data)
+	return len(msg), nil
+}
+
+// io.Closer.Close()
+// 发送完队列中的日志之后关闭连接，之后可以通过 Write 重新启动。
+func (r *Redis) Close() error {
+	r.batchWriter.Close()
+
+	// 后台的 goroutine 已经退出，可以直接访问 conn
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	r.r = nil
+	return err
+}
+
+// 将一批日志转换成命令，通过 pipeline 一次性发送。
+func (r *Redis) send(items [][]byte) error {
+	cmds, err := r.commands(items)
+	if err != nil {
+		return &noRetryError{err: err}
+	}
+
+	if err = r.pipeline(cmds); err == nil {
+		return nil
+	}
+
+	var re redisError
+	if !errors.As(err, &re) { // 网络错误，需要重新连接
+		if r.conn != nil {
+			r.conn.Close()
+			r.conn = nil
+			r.r = nil
+		}
+		return err
+	}
+
+	for _, prefix := range redisRetryErrors {
+		if strings.HasPrefix(string(re), prefix) {
+			return err
+		}
+	}
+	return &noRetryError{err: err}
+}
+
+func (r *Redis) commands(items [][]byte) ([][]string, error) {
+	if r.mode == RedisList {
+		cmd := make([]string, 0, len(items)+2)
+		cmd = append(cmd, "RPUSH", r.key)
+		for _, item := range items {
+			cmd = append(cmd, string(item))
+		}
+
+		if r.maxLen == 0 {
+			return [][]string{cmd}, nil
+		}
+		return [][]string{cmd, {"LTRIM", r.key, strconv.Itoa(-r.maxLen), "-1"}}, nil
+	}
+
+	cmds := make([][]string, 0, len(items))
+	for _, item := range items {
+		rec := &httpRecord{}
+		if err := json.Unmarshal(item, rec); err != nil {
+			return nil, err
+		}
+
+		cmd := []string{"XADD", r.key}
+		if r.maxLen > 0 {
+			cmd = append(cmd, "MAXLEN", "~", strconv.Itoa(r.maxLen))
+		}
+		cmd = append(cmd, "*", "time", rec.Time)
+		if rec.Level != "" {
+			cmd = append(cmd, "level", rec.Level)
+		}
+		if rec.Caller != "" {
+			cmd = append(cmd, "caller", rec.Caller)
+		}
+		cmd = append(cmd, "message", rec.Message)
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+// 发送多条命令，并读取所有的返回值。
+// 有命令返回错误时，依然会读取完所有的返回值，之后返回第一个错误。
+func (r *Redis) pipeline(cmds [][]string) error {
+	if r.conn == nil {
+		if err := r.connect(); err != nil {
+			return err
+		}
+	}
+
+	if r.timeout > 0 {
+		r.conn.SetDeadline(time.Now().Add(r.timeout))
+	}
+
+	buf := make([]byte, 0, 512)
+	for _, cmd := range cmds {
+		buf = appendRedisCommand(buf, cmd)
+	}
+	if _, err := r.conn.Write(buf); err != nil {
+		return err
+	}
+
+	var first error
+	for range cmds {
+		err := readRedisReply(r.r)
+		if _, ok := err.(redisError); !ok && err != nil {
+			return err
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// 建立连接，并根据需要进行验证和选择数据库。
+func (r *Redis) connect() (err error) {
+	dialer := &net.Dialer{Timeout: r.timeout}
+	var conn net.Conn
+	if r.tlsConfig != nil && r.network != "unix" {
+		conn, err = tls.DialWithDialer(dialer, r.network, r.addr, r.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(r.network, r.addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.conn = conn
+	r.r = bufio.NewReader(conn)
+
+	var cmds [][]string
+	switch {
+	case r.username != "":
+		cmds = append(cmds, []string{"AUTH", r.username, r.password})
+	case r.password != "":
+		cmds = append(cmds, []string{"AUTH", r.password})
+	}
+	if r.db > 0 {
+		cmds = append(cmds, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	if err = r.pipeline(cmds); err != nil {
+		conn.Close()
+		r.conn = nil
+		r.r = nil
+
+		if re, ok := err.(redisError); ok {
+			return fmt.Errorf("连接 redis 失败:%w", re)
+		}
+	}
+	return err
+}
+
+// 以 RESP 格式编码命令：*n\r\n$len\r\narg\r\n...
+func appendRedisCommand(buf []byte, cmd []string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(cmd)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range cmd {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// 读取一个返回值，只关心是否为错误，返回的内容会被忽略。
+func readRedisReply(r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return fmt.Errorf("无效的返回值:[%v]", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return redisError(line[1:])
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return err
+		}
+		if size < 0 { // null
+			return nil
+		}
+		_, err = io.CopyN(ioutil.Discard, r, int64(size+2))
+		return err
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return err
+		}
+
+		// 元素中的错误需要在读取完所有元素之后才返回
+		var first error
+		for i := 0; i < size; i++ {
+			err = readRedisReply(r)
+			if _, ok := err.(redisError); !ok && err != nil {
+				return err
+			}
+			if first == nil {
+				first = err
+			}
+		}
+		return first
+	default:
+		return fmt.Errorf("无效的返回值:[%v]", line)
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Leveler        = &Redis{}
+	_ Flusher        = &Redis{}
+	_ io.WriteCloser = &Redis{}
+)
+
+// 模拟 Redis 服务端，仅实现了测试需要用到的命令
+type redisServer struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	password string
+	lists    map[string][]string
+	streams  map[string][]map[string]string
+	cmds     []string // 收到的命令名称
+	conns    int      // 建立过的连接数量
+	errReply string   // 下一条写入命令返回的错误
+	drop     bool     // 收到下一条写入命令时断开连接
+}
+
+func newRedisServer(t *testing.T) *redisServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &redisServer{
+		ln:      ln,
+		lists:   map[string][]string{},
+		streams: map[string][]map[string]string{},
+	}
+	go s.serve()
+	return s
+}
+
+func (s *redisServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *redisServer) close() {
+	s.ln.Close()
+}
+
+func (s *redisServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns++
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *redisServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := false
+
+	for {
+		cmd, err := readRedisCommand(r)
+		if err != nil {
+			return
+		}
+
+		reply, ok := s.exec(cmd, &authed)
+		if !ok {
+			return
+		}
+		if _, err = conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// 执行命令并返回 RESP 格式的返回值，返回 false 表示需要断开连接。
+func (s *redisServer) exec(cmd []string, authed *bool) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.ToUpper(cmd[0])
+	s.cmds = append(s.cmds, name)
+
+	if name == "AUTH" {
+		if cmd[len(cmd)-1] != s.password {
+			return "-WRONGPASS invalid password\r\n", true
+		}
+		*authed = true
+		return "+OK\r\n", true
+	}
+
+	if s.password != "" && !*authed {
+		return "-NOAUTH Authentication required.\r\n", true
+	}
+
+	switch name {
+	case "SELECT":
+		return "+OK\r\n", true
+	case "RPUSH", "XADD":
+		if s.drop {
+			s.drop = false
+			return "", false
+		}
+		if s.errReply != "" {
+			reply := "-" + s.errReply + "\r\n"
+			s.errReply = ""
+			return reply, true
+		}
+	}
+
+	key := cmd[1]
+	switch name {
+	case "RPUSH":
+		s.lists[key] = append(s.lists[key], cmd[2:]...)
+		return fmt.Sprintf(":%d\r\n", len(s.lists[key])), true
+	case "LTRIM":
+		start, _ := strconv.Atoi(cmd[2])
+		if l := s.lists[key]; start < 0 && -start < len(l) {
+			s.lists[key] = l[len(l)+start:]
+		}
+		return "+OK\r\n", true
+	case "XADD":
+		args := cmd[2:]
+		maxLen := 0
+		if strings.ToUpper(args[0]) == "MAXLEN" {
+			maxLen, _ = strconv.Atoi(args[2])
+			args = args[3:]
+		}
+		args = args[1:] // *
+
+		entry := map[string]string{}
+		for i := 0; i+1 < len(args); i += 2 {
+			entry[args[i]] = args[i+1]
+		}
+		s.streams[key] = append(s.streams[key], entry)
+		if l := s.streams[key]; maxLen > 0 && len(l) > maxLen {
+			s.streams[key] = l[len(l)-maxLen:]
+		}
+		id := strconv.Itoa(len(s.streams[key])) + "-0"
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(id), id), true
+	}
+	return "-ERR unknown command\r\n", true
+}
+
+func readRedisCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		cmd = append(cmd, string(buf[:size]))
+	}
+	return cmd, nil
+}
+
+func TestAppendRedisCommand(t *testing.T) {
+	a := assert.New(t)
+
+	buf := appendRedisCommand(nil, []string{"RPUSH", "logs", "abc"})
+	a.Equal(string(buf), "*3\r\n$5\r\nRPUSH\r\n$4\r\nlogs\r\n$3\r\nabc\r\n")
+}
+
+func TestReadRedisReply(t *testing.T) {
+	a := assert.New(t)
+
+	read := func(s string) error {
+		return readRedisReply(bufio.NewReader(strings.NewReader(s)))
+	}
+
+	a.NotError(read("+OK\r\n"))
+	a.NotError(read(":10\r\n"))
+	a.NotError(read("$3\r\nabc\r\n"))
+	a.NotError(read("$-1\r\n"))
+	a.NotError(read("*2\r\n$1\r\na\r\n:1\r\n"))
+	a.Equal(read("-ERR wrong\r\n"), redisError("ERR wrong"))
+	a.Equal(read("*2\r\n-ERR 1\r\n-ERR 2\r\n"), redisError("ERR 1"))
+	a.Error(read("?\r\n"))
+	a.Error(read("$3\r\nab"))
+}
+
+func TestRedis_list(t *testing.T) {
+	a := assert.New(t)
+	srv := newRedisServer(t)
+	defer srv.close()
+
+	_, err := NewRedis("udp", srv.addr(), "logs")
+	a.Error(err)
+	_, err = NewRedis("tcp", srv.addr(), "")
+	a.Error(err)
+
+	r, err := NewRedis("tcp", srv.addr(), "logs")
+	a.NotError(err).NotNil(r)
+	a.NotError(r.SetMode(RedisList, 2)).
+		Error(r.SetMode(10, 0)).
+		Error(r.SetMode(RedisList, -1))
+	a.NotError(r.SetBatch(10, time.Hour))
+	a.NotError(r.SetDB(2)).Error(r.SetDB(-1))
+
+	r.SetLevel("info", "[INFO]", 0)
+	r.Write([]byte("[INFO]m1\n"))
+	r.Write([]byte("[INFO]m2\n"))
+	r.Write([]byte("[INFO]m3\n"))
+	r.Flush()
+
+	srv.mu.Lock()
+	a.Equal(srv.cmds, []string{"SELECT", "RPUSH", "LTRIM"})
+	list := srv.lists["logs"]
+	srv.mu.Unlock()
+	a.Equal(len(list), 2)
+
+	rec := &httpRecord{}
+	a.NotError(json.Unmarshal([]byte(list[0]), rec))
+	a.Equal(rec.Message, "m2").Equal(rec.Level, "info").NotEmpty(rec.Time)
+
+	a.NotError(r.Close()).NotError(r.Close())
+}
+
+func TestRedis_stream(t *testing.T) {
+	a := assert.New(t)
+	srv := newRedisServer(t)
+	defer srv.close()
+	srv.mu.Lock()
+	srv.password = "pass"
+	srv.mu.Unlock()
+
+	r, err := NewRedis("tcp", srv.addr(), "logs")
+	a.NotError(err)
+	a.NotError(r.SetMode(RedisStream, 10))
+	a.NotError(r.SetBatch(10, time.Hour))
+	r.SetAuth("", "pass")
+
+	r.SetLevel("error", "[ERROR]", 0)
+	r.Write([]byte("[ERROR]m1\n"))
+	r.Write([]byte("[ERROR]m2\n"))
+	r.Flush()
+
+	srv.mu.Lock()
+	a.Equal(srv.cmds, []string{"AUTH", "XADD", "XADD"})
+	stream := srv.streams["logs"]
+	srv.mu.Unlock()
+	a.Equal(len(stream), 2)
+	a.Equal(stream[0]["message"], "m1").
+		Equal(stream[0]["level"], "error").
+		Equal(stream[1]["message"], "m2")
+	_, found := stream[0]["caller"]
+	a.False(found)
+
+	a.NotError(r.Close())
+
+	// 密码错误，不重试
+	r, err = NewRedis("tcp", srv.addr(), "logs")
+	a.NotError(err)
+	a.NotError(r.SetQueue(10, 3, time.Millisecond))
+	r.SetAuth("user", "invalid")
+	r.Write([]byte("m3\n"))
+	r.Flush()
+	a.Equal(r.Dropped(), 1)
+	a.NotError(r.Close())
+}
+
+func TestRedis_reconnect(t *testing.T) {
+	a := assert.New(t)
+	srv := newRedisServer(t)
+	defer srv.close()
+
+	r, err := NewRedis("tcp", srv.addr(), "logs")
+	a.NotError(err)
+	a.NotError(r.SetQueue(10, 2, time.Millisecond))
+
+	r.Write([]byte("m1\n"))
+	r.Flush()
+
+	// 连接断开之后重新连接并重试
+	srv.mu.Lock()
+	srv.drop = true
+	srv.mu.Unlock()
+	r.Write([]byte("m2\n"))
+	r.Flush()
+	a.Equal(r.Dropped(), 0)
+
+	srv.mu.Lock()
+	a.Equal(srv.conns, 2).Equal(len(srv.lists["logs"]), 2)
+	srv.mu.Unlock()
+
+	// 可以重试的错误
+	srv.mu.Lock()
+	srv.errReply = "LOADING Redis is loading the dataset in memory"
+	srv.mu.Unlock()
+	r.Write([]byte("m3\n"))
+	r.Flush()
+	a.Equal(r.Dropped(), 0)
+
+	// 不能重试的错误
+	srv.mu.Lock()
+	srv.errReply = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	srv.mu.Unlock()
+	r.Write([]byte("m4\n"))
+	r.Flush()
+	a.Equal(r.Dropped(), 1)
+
+	srv.mu.Lock()
+	a.Equal(srv.conns, 2).Equal(len(srv.lists["logs"]), 3)
+	srv.mu.Unlock()
+
+	a.NotError(r.Close())
+}
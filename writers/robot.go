@@ -0,0 +1,417 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 聊天机器人的平台
+const (
+	RobotDingTalk = iota // 钉钉
+	RobotWeCom           // 企业微信
+	RobotFeishu          // 飞书
+	RobotSlack
+)
+
+const (
+	defaultRobotTimeout = 10 * time.Second
+	robotMaxLines       = 100 // 每条消息最多包含的日志行数
+)
+
+// 各平台默认的频率限制
+var robotRateLimits = map[int]struct {
+	count int
+	per   time.Duration
+}{
+	RobotDingTalk: {20, time.Minute},
+	RobotWeCom:    {20, time.Minute},
+	RobotFeishu:   {100, time.Minute},
+	RobotSlack:    {1, time.Second},
+}
+
+// 各平台每条消息内容的最大字节数
+var robotMaxBytes = map[int]int{
+	RobotDingTalk: 20000,
+	RobotWeCom:    2048,
+	RobotFeishu:   18000, // 请求体不能超过 20K，需要预留签名等内容的空间
+	RobotSlack:    40000,
+}
+
+// 将日志发送到聊天机器人的 webhook。
+//
+// 在 window 时间内的日志会被合并成一条消息发送；超过频率限制时，
+// 日志会继续合并，直到可以发送为止。每条消息最多包含 100 行日志，
+// 且不能超过平台限制的字节数，超出的部分将被丢弃，
+// 丢弃或是被截断的行数可以通过 Dropped() 获取。
+type Robot struct {
+	platform int
+	webhook  string
+	secret   string
+	mentions []string
+	atAll    bool
+	window   time.Duration
+	count    int // 频率限制，per 时间内最多发送 count 条消息
+	per      time.Duration
+	client   *http.Client
+
+	mu      sync.Mutex
+	lines   []string
+	timer   *time.Timer
+	sent    []time.Time // 最近发送消息的时间，用于频率限制
+	dropCnt uint64
+	err     error // 后台发送时的最后一个错误
+}
+
+// 新建 Robot 实例。
+// platform 为平台类型，webhook 为机器人的地址。
+func NewRobot(platform int, webhook string) (*Robot, error) {
+	limit, found := robotRateLimits[platform]
+	if !found {
+		return nil, fmt.Errorf("无效的platform值:[%v]", platform)
+	}
+
+	return &Robot{
+		platform: platform,
+		webhook:  webhook,
+		count:    limit.count,
+		per:      limit.per,
+		client:   &http.Client{Timeout: defaultRobotTimeout},
+	}, nil
+}
+
+// 设置签名的密钥，仅对钉钉和飞书有效。
+func (r *Robot) SetSecret(secret string) {
+	r.secret = secret
+}
+
+// 设置需要 @ 的用户。
+//
+// ids 在钉钉和企业微信中为手机号，在飞书中为 open_id，在 Slack 中为用户 ID；
+// all 表示是否 @ 所有人。
+func (r *Robot) SetMentions(ids []string, all bool) {
+	r.mentions = ids
+	r.atAll = all
+}
+
+// 设置合并消息的时间窗口，为 0 表示不合并。
+func (r *Robot) SetWindow(window time.Duration) {
+	r.window = window
+}
+
+// 设置频率限制，per 时间内最多发送 count 条消息，
+// count 小于 1 表示不作限制。默认值为各平台的限制。
+func (r *Robot) SetRateLimit(count int, per time.Duration) {
+	r.count = count
+	r.per = per
+}
+
+// 设置每次请求的超时时间，默认为 10 秒。
+func (r *Robot) SetTimeout(timeout time.Duration) {
+	r.client.Timeout = timeout
+}
+
+// io.Writer
+func (r *Robot) Write(msg []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.lines) >= robotMaxLines {
+		r.dropCnt++
+		return len(msg), nil
+	}
+	r.lines = append(r.lines, strings.TrimRight(string(msg), "\r\n"))
+
+	if r.timer == nil {
+		r.timer = time.AfterFunc(r.delay(time.Now()), r.fire)
+	}
+
+	return len(msg), nil
+}
+
+// 被丢弃或是被截断的日志行数
+func (r *Robot) Dropped() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.dropCnt
+}
+
+// Flusher.Flush()
+// 立即发送还未发送的日志，不受 window 和频率限制的影响。
+// 同时返回后台发送时产生的最后一个错误。
+func (r *Robot) Flush() (int, error) {
+	r.mu.Lock()
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	content, now := r.take()
+	lastErr := r.err
+	r.err = nil
+	r.mu.Unlock()
+
+	if content == "" {
+		return 0, lastErr
+	}
+
+	if err := r.send(content, now); err != nil {
+		return 0, err
+	}
+	return len(content), lastErr
+}
+
+// io.Closer.Close()
+func (r *Robot) Close() error {
+	_, err := r.Flush()
+	return err
+}
+
+// 计算下一次发送需要等待的时间
+func (r *Robot) delay(now time.Time) time.Duration {
+	if wait := r.rateWait(now); wait > r.window {
+		return wait
+	}
+	return r.window
+}
+
+// 受频率限制需要等待的时间，小于等于 0 表示可以立即发送。
+func (r *Robot) rateWait(now time.Time) time.Duration {
+	if r.count <= 0 || len(r.sent) < r.count {
+		return 0
+	}
+	return r.sent[len(r.sent)-r.count].Add(r.per).Sub(now)
+}
+
+func (r *Robot) fire() {
+	r.mu.Lock()
+	if r.timer == nil { // 已经被 Flush 处理
+		r.mu.Unlock()
+		return
+	}
+
+	// 依然超过频率限制，继续等待，window 已经在第一次等待中满足。
+	if wait := r.rateWait(time.Now()); wait > 0 {
+		r.timer.Reset(wait)
+		r.mu.Unlock()
+		return
+	}
+	r.timer = nil
+	content, now := r.take()
+	r.mu.Unlock()
+
+	if content == "" {
+		return
+	}
+
+	if err := r.send(content, now); err != nil {
+		r.mu.Lock()
+		r.err = err
+		r.mu.Unlock()
+	}
+}
+
+// 取出已经缓存的日志并记录发送时间，需要在锁中调用。
+func (r *Robot) take() (string, time.Time) {
+	now := time.Now()
+	if len(r.lines) == 0 {
+		return "", now
+	}
+
+	content := r.join(robotMaxBytes[r.platform] - len(r.mentionText()))
+	r.lines = r.lines[:0]
+
+	r.sent = append(r.sent, now)
+	if r.count > 0 && len(r.sent) > r.count {
+		r.sent = r.sent[len(r.sent)-r.count:]
+	}
+
+	return content, now
+}
+
+// 将缓存的日志合并成不超过 limit 字节的内容，需要在锁中调用。
+//
+// 放不下的行将被丢弃，若第一行就超出了 limit，则截断该行。
+func (r *Robot) join(limit int) string {
+	buf := new(strings.Builder)
+	for i, line := range r.lines {
+		if i > 0 {
+			if buf.Len()+1+len(line) > limit {
+				r.dropCnt += uint64(len(r.lines) - i)
+				break
+			}
+			buf.WriteByte('\n')
+		} else if len(line) > limit {
+			line = robotTruncate(line, limit)
+			r.dropCnt++
+		}
+		buf.WriteString(line)
+	}
+	return buf.String()
+}
+
+func (r *Robot) send(content string, now time.Time) error {
+	webhook, body, err := r.payload(content, now)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Post(webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("服务端返回错误的状态码:[%v]", resp.StatusCode)
+	}
+
+	if r.platform == RobotSlack { // Slack 只返回 ok
+		return nil
+	}
+
+	// 钉钉和企业微信返回 errcode，飞书返回 code
+	ret := &struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		Code    int    `json:"code"`
+		Msg     string `json:"msg"`
+	}{}
+	if err = json.Unmarshal(data, ret); err != nil {
+		return err
+	}
+	if ret.ErrCode != 0 {
+		return fmt.Errorf("发送消息失败:[%v] %v", ret.ErrCode, ret.ErrMsg)
+	}
+	if ret.Code != 0 {
+		return fmt.Errorf("发送消息失败:[%v] %v", ret.Code, ret.Msg)
+	}
+	return nil
+}
+
+// 根据平台构造请求的地址和内容
+func (r *Robot) payload(content string, now time.Time) (string, []byte, error) {
+	webhook := r.webhook
+	var p interface{}
+
+	switch r.platform {
+	case RobotDingTalk:
+		if r.secret != "" {
+			ts := strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10)
+			sign := robotSign(r.secret, ts+"\n"+r.secret)
+			sep := "?"
+			if strings.Contains(webhook, "?") {
+				sep = "&"
+			}
+			webhook += sep + "timestamp=" + ts + "&sign=" + url.QueryEscape(sign)
+		}
+
+		p = map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": content + r.mentionText()},
+			"at": map[string]interface{}{
+				"atMobiles": r.mentionList(),
+				"isAtAll":   r.atAll,
+			},
+		}
+	case RobotWeCom:
+		mentions := r.mentionList()
+		if r.atAll {
+			mentions = append(mentions, "@all")
+		}
+		p = map[string]interface{}{
+			"msgtype": "text",
+			"text": map[string]interface{}{
+				"content":               content,
+				"mentioned_mobile_list": mentions,
+			},
+		}
+	case RobotFeishu:
+		m := map[string]interface{}{
+			"msg_type": "text",
+			"content":  map[string]string{"text": content + r.mentionText()},
+		}
+		if r.secret != "" {
+			ts := strconv.FormatInt(now.Unix(), 10)
+			m["timestamp"] = ts
+			m["sign"] = robotSign(ts+"\n"+r.secret, "")
+		}
+		p = m
+	case RobotSlack:
+		p = map[string]string{"text": content + r.mentionText()}
+	}
+
+	body, err := json.Marshal(p)
+	return webhook, body, err
+}
+
+// 需要追加在内容之后的 @ 信息，企业微信的 @ 信息不在内容中。
+func (r *Robot) mentionText() string {
+	buf := new(strings.Builder)
+	switch r.platform {
+	case RobotDingTalk: // 钉钉需要在内容中包含 @手机号 才会高亮显示
+		for _, id := range r.mentions {
+			buf.WriteString(" @" + id)
+		}
+	case RobotFeishu:
+		for _, id := range r.mentions {
+			buf.WriteString(`<at user_id="` + id + `"></at>`)
+		}
+		if r.atAll {
+			buf.WriteString(`<at user_id="all">所有人</at>`)
+		}
+	case RobotSlack:
+		for _, id := range r.mentions {
+			buf.WriteString(" <@" + id + ">")
+		}
+		if r.atAll {
+			buf.WriteString(" <!channel>")
+		}
+	}
+	return buf.String()
+}
+
+// 将 s 截断为最多 size 字节，不会截断在 UTF-8 字符的中间。
+func robotTruncate(s string, size int) string {
+	if size <= 0 {
+		return ""
+	}
+	for size > 0 && !utf8.RuneStart(s[size]) {
+		size--
+	}
+	return s[:size]
+}
+
+func (r *Robot) mentionList() []string {
+	list := make([]string, 0, len(r.mentions)+1)
+	return append(list, r.mentions...)
+}
+
+// 以 key 作为密钥，计算 msg 的 HMAC-SHA256 并以 base64 编码。
+// 钉钉以 secret 为密钥，timestamp+"\n"+secret 为内容；
+// 飞书以 timestamp+"\n"+secret 为密钥，内容为空。
+func robotSign(key, msg string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(msg))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,245 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Flusher        = &Robot{}
+	_ io.WriteCloser = &Robot{}
+)
+
+// 模拟机器人的服务端
+type robotServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	queries  []map[string][]string
+	payloads []map[string]interface{}
+	response string
+}
+
+func newRobotServer(response string) *robotServer {
+	s := &robotServer{response: response}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		p := map[string]interface{}{}
+		json.Unmarshal(data, &p)
+
+		s.mu.Lock()
+		s.queries = append(s.queries, r.URL.Query())
+		s.payloads = append(s.payloads, p)
+		s.mu.Unlock()
+
+		w.Write([]byte(s.response))
+	}))
+	return s
+}
+
+func (s *robotServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.payloads)
+}
+
+func TestNewRobot(t *testing.T) {
+	a := assert.New(t)
+
+	r, err := NewRobot(100, "http://localhost")
+	a.Error(err).Nil(r)
+
+	r, err = NewRobot(RobotSlack, "http://localhost")
+	a.NotError(err).NotNil(r)
+}
+
+func TestRobot_dingtalk(t *testing.T) {
+	a := assert.New(t)
+	srv := newRobotServer(`{"errcode":0,"errmsg":"ok"}`)
+	defer srv.Close()
+
+	r, err := NewRobot(RobotDingTalk, srv.URL+"?access_token=token")
+	a.NotError(err)
+	r.SetSecret("secret")
+	r.SetMentions([]string{"13800000000"}, true)
+
+	size, err := r.Write([]byte("[ERROR]hello\n"))
+	a.NotError(err).Equal(size, len("[ERROR]hello\n"))
+	_, err = r.Flush()
+	a.NotError(err)
+
+	a.Equal(srv.count(), 1)
+	q := srv.queries[0]
+	a.Equal(q["access_token"][0], "token")
+	ts := q["timestamp"][0]
+	a.Equal(q["sign"][0], robotSign("secret", ts+"\nsecret"))
+
+	p := srv.payloads[0]
+	a.Equal(p["msgtype"], "text")
+	text := p["text"].(map[string]interface{})
+	a.Equal(text["content"], "[ERROR]hello @13800000000")
+	at := p["at"].(map[string]interface{})
+	a.Equal(at["isAtAll"], true).
+		Equal(at["atMobiles"], []interface{}{"13800000000"})
+
+	a.NotError(r.Close())
+}
+
+func TestRobot_wecom(t *testing.T) {
+	a := assert.New(t)
+	srv := newRobotServer(`{"errcode":93000,"errmsg":"invalid webhook url"}`)
+	defer srv.Close()
+
+	r, err := NewRobot(RobotWeCom, srv.URL)
+	a.NotError(err)
+	r.SetMentions([]string{"13800000000"}, true)
+	r.Write([]byte("hello\n"))
+
+	// 返回的错误码
+	_, err = r.Flush()
+	a.Error(err)
+
+	p := srv.payloads[0]
+	text := p["text"].(map[string]interface{})
+	a.Equal(text["content"], "hello").
+		Equal(text["mentioned_mobile_list"], []interface{}{"13800000000", "@all"})
+}
+
+func TestRobot_feishu(t *testing.T) {
+	a := assert.New(t)
+	srv := newRobotServer(`{"code":0,"msg":"success"}`)
+	defer srv.Close()
+
+	r, err := NewRobot(RobotFeishu, srv.URL)
+	a.NotError(err)
+	r.SetSecret("secret")
+	r.SetMentions([]string{"ou_1"}, false)
+	r.Write([]byte("hello\n"))
+	_, err = r.Flush()
+	a.NotError(err)
+
+	p := srv.payloads[0]
+	ts := p["timestamp"].(string)
+	a.Equal(p["msg_type"], "text").
+		Equal(p["sign"], robotSign(ts+"\nsecret", ""))
+	content := p["content"].(map[string]interface{})
+	a.Equal(content["text"], `hello<at user_id="ou_1"></at>`)
+}
+
+func TestRobot_slack(t *testing.T) {
+	a := assert.New(t)
+	srv := newRobotServer("ok")
+	defer srv.Close()
+
+	r, err := NewRobot(RobotSlack, srv.URL)
+	a.NotError(err)
+	r.SetMentions([]string{"U1"}, true)
+	r.Write([]byte("hello\n"))
+	_, err = r.Flush()
+	a.NotError(err)
+
+	a.Equal(srv.payloads[0]["text"], "hello <@U1> <!channel>")
+}
+
+// 合并以及频率限制
+func TestRobot_aggregate(t *testing.T) {
+	a := assert.New(t)
+	srv := newRobotServer("ok")
+	defer srv.Close()
+
+	r, err := NewRobot(RobotSlack, srv.URL)
+	a.NotError(err)
+	r.SetWindow(50 * time.Millisecond)
+	r.SetRateLimit(1, 500*time.Millisecond)
+
+	r.Write([]byte("1\n"))
+	r.Write([]byte("2\n"))
+	time.Sleep(150 * time.Millisecond)
+	a.Equal(srv.count(), 1)
+	a.Equal(srv.payloads[0]["text"], "1\n2")
+
+	// 超过频率限制，在第一条消息的 500ms 之后才会发送
+	r.Write([]byte("3\n"))
+	time.Sleep(100 * time.Millisecond)
+	r.Write([]byte("4\n"))
+	time.Sleep(100 * time.Millisecond)
+	a.Equal(srv.count(), 1)
+	time.Sleep(300 * time.Millisecond)
+	a.Equal(srv.count(), 2)
+	a.Equal(srv.payloads[1]["text"], "3\n4")
+
+	// 超过最大行数
+	for i := 0; i < robotMaxLines+2; i++ {
+		r.Write([]byte("x\n"))
+	}
+	a.Equal(r.Dropped(), 2)
+	a.NotError(r.Close())
+	a.Equal(srv.count(), 3)
+}
+
+// 超过平台限制的字节数
+func TestRobot_maxBytes(t *testing.T) {
+	a := assert.New(t)
+	srv := newRobotServer(`{"errcode":0,"errmsg":"ok"}`)
+	defer srv.Close()
+
+	r, err := NewRobot(RobotWeCom, srv.URL)
+	a.NotError(err)
+	line := strings.Repeat("中", 100) // 300 字节
+	for i := 0; i < 10; i++ {
+		r.Write([]byte(line + "\n"))
+	}
+	_, err = r.Flush()
+	a.NotError(err)
+
+	text := srv.payloads[0]["text"].(map[string]interface{})
+	content := text["content"].(string)
+	a.True(len(content) <= 2048).
+		Equal(content, strings.Repeat(line+"\n", 5)+line). // 第 7 行将超过 2048 字节
+		Equal(r.Dropped(), 4)
+
+	// 单行超出时截断该行
+	r.Write([]byte(strings.Repeat(line, 10) + "\n"))
+	_, err = r.Flush()
+	a.NotError(err)
+	text = srv.payloads[1]["text"].(map[string]interface{})
+	a.Equal(text["content"], strings.Repeat("中", 682)).Equal(r.Dropped(), 5)
+}
+
+func TestRobot_rateLimit(t *testing.T) {
+	a := assert.New(t)
+	srv := newRobotServer("ok")
+	defer srv.Close()
+
+	r, err := NewRobot(RobotSlack, srv.URL)
+	a.NotError(err)
+	r.SetWindow(50 * time.Millisecond)
+	r.SetRateLimit(1, 300*time.Millisecond)
+
+	// 在等待 window 期间，另有消息被发送，触发了频率限制
+	r.Write([]byte("1\n"))
+	r.mu.Lock()
+	r.sent = append(r.sent, time.Now())
+	r.mu.Unlock()
+
+	// 必须等到频率限制结束之后才能发送，不能提前 window
+	time.Sleep(270 * time.Millisecond)
+	a.Equal(srv.count(), 0)
+	time.Sleep(180 * time.Millisecond)
+	a.Equal(srv.count(), 1)
+
+	a.NotError(r.Close())
+}
@@ -23,17 +23,35 @@ var (
 )
 
 // 可按大小进行分割的文件
+//
+// 新建的文件和目录的权限默认受 umask 的影响，可以通过 SetMode、SetDirMode 等修改。
 //  import "log"
 //  // 每个文件以100M大小进行分割，以日期名作为文件名保存在/var/log下。
 //  f,_ := NewRotate("/var/log", 100*1024*1024)
 //  l := log.New(f, "DEBUG", log.LstdFlags)
 type Rotate struct {
+	fileOptions
 	dir      string // 文件的保存目录
+	created  bool   // dir 是否由 NewRotate 创建
 	size     int    // 每个文件的最大尺寸
 	basePath string
 
 	w     io.Writer // 当前正在写的文件
 	wSize int       // 当前正在写的文件大小
+	name  string    // 当前正在写的文件路径
+
+	rotated  func(path string) // 文件被切换之后的回调
+	uploader Uploader          // 上传写满的文件
+}
+
+// 上传 Rotate 写满的文件，比如 S3。
+//
+// Rotate 会将已经关闭的文件路径传递给 Rotated，
+// 并在自身的 Flush 和 Close 中调用 Uploader 对应的方法。
+type Uploader interface {
+	Rotated(path string)
+	Flusher
+	io.Closer
 }
 
 // 新建Rotate。
@@ -44,42 +62,99 @@ type Rotate struct {
 func NewRotate(prefix, dir string, size int) (*Rotate, error) {
 	// 确保结目录分隔符结尾，如果是文件的话，加上目录分隔符，在os.Stat时会返回error。
 	dir = dir + string(os.PathSeparator)
-	if _, err := os.Stat(dir); err != nil && !os.IsExist(err) {
-		if !os.IsNotExist(err) {
-			return nil, err
-		}
 
-		// 尝试创建目录
-		if err := os.MkdirAll(dir, defaultMode); err != nil {
-			return nil, err
-		}
+	r := &Rotate{
+		fileOptions: newFileOptions(),
+		dir:         dir,
+		basePath:    dir + prefix,
+		size:        size,
+	}
 
-		// 创建目录成功，重新获取状态
-		if _, err = os.Stat(dir); err != nil {
+	// 尝试创建目录，之后通过 SetDirMode 和 SetOwner 修改其权限和所有者。
+	_, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		if err = r.mkdir(dir); err != nil {
 			return nil, err
 		}
+		r.created = true
+	case err != nil:
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// 设置新建目录的权限，由 NewRotate 创建的目录也会被修改。
+func (r *Rotate) SetDirMode(mode os.FileMode) error {
+	if err := r.fileOptions.SetDirMode(mode); err != nil {
+		return err
+	}
+
+	if r.created {
+		return os.Chmod(r.dir, mode)
+	}
+	return nil
+}
+
+// 设置新建文件和目录的所有者，由 NewRotate 创建的目录也会被修改。
+func (r *Rotate) SetOwner(uid, gid int) error {
+	if err := r.fileOptions.SetOwner(uid, gid); err != nil {
+		return err
+	}
+
+	if r.created && (uid != -1 || gid != -1) {
+		return os.Chown(r.dir, uid, gid)
 	}
+	return nil
+}
 
-	return &Rotate{
-		dir:      dir,
-		basePath: dir + prefix,
-		size:     size,
-	}, nil
+// 设置文件被切换之后的回调函数，参数为已经写满并关闭的文件路径，
+// 可用于压缩或是上传该文件。f 在 Write 中被调用，耗时的操作应该异步进行。
+func (r *Rotate) SetRotated(f func(path string)) {
+	r.rotated = f
+}
+
+// 设置上传者，写满的文件以及 Close 时正在写的文件都会交给 u 上传，
+// 之后 Rotate 的 Flush 和 Close 也会调用 u 的 Flush 和 Close。
+func (r *Rotate) SetUploader(u Uploader) {
+	r.uploader = u
+}
+
+// 文件写满或是关闭之后，交给回调函数以及上传者处理。
+func (r *Rotate) finish(path string) {
+	if r.rotated != nil {
+		r.rotated(path)
+	}
+	if r.uploader != nil {
+		r.uploader.Rotated(path)
+	}
 }
 
 // 初始化一个新的文件对象
 func (r *Rotate) init() error {
+	name := r.basePath + time.Now().Format("20060102150405") + defaultExt
+
 	if r.w != nil {
 		r.w.(*os.File).Close()
+
+		// 同一秒内切换时，依然会写入原来的文件
+		if r.name != name {
+			r.finish(r.name)
+		}
 	}
 
-	name := r.basePath + time.Now().Format("20060102150405") + defaultExt
+	// 目录可能在运行期间被删除
+	if err := r.mkdir(r.dir); err != nil {
+		return err
+	}
 
 	var err error
-	if r.w, err = os.OpenFile(name, defaultFlag, defaultMode); err != nil {
+	if r.w, err = r.open(name); err != nil {
 		return err
 	}
 
+	r.name = name
 	r.wSize = 0
 
 	return nil
@@ -104,15 +179,35 @@ func (r *Rotate) Write(buf []byte) (int, error) {
 }
 
 // io.WriteCloser.Close()
+// 关闭当前的文件并交给上传者，之后再关闭上传者。
+// 关闭之后再次写入会打开新的文件。
 func (r *Rotate) Close() error {
-	if r.w == nil {
-		return nil
+	var err error
+	if r.w != nil {
+		err = r.w.(*os.File).Close()
+		r.w = nil
+		r.finish(r.name)
 	}
 
-	return r.w.(*os.File).Close()
+	if r.uploader != nil {
+		if err2 := r.uploader.Close(); err == nil {
+			err = err2
+		}
+	}
+	return err
 }
 
 // Flusher.Flush()
-func (r *Rotate) Flush() {
-	r.Close()
+// 将内容同步到磁盘，并等待上传者上传完队列中的文件。
+func (r *Rotate) Flush() (int, error) {
+	if r.w != nil {
+		if err := r.w.(*os.File).Sync(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.uploader != nil {
+		return r.uploader.Flush()
+	}
+	return 0, nil
 }
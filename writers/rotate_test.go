@@ -8,13 +8,18 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
 	"github.com/issue9/assert"
 )
 
-var _ io.WriteCloser = &Rotate{}
+var (
+	_ io.WriteCloser = &Rotate{}
+	_ Flusher        = &Rotate{}
+)
 
 // 清空指定目录下的所有内容。
 func clearDir(dir string) error {
@@ -62,3 +67,124 @@ func TestRotate(t *testing.T) {
 	a.NotError(err)
 	a.Equal(len(files), loop*len("1024\n")/w.size)
 }
+
+func TestRotate_SetRotated(t *testing.T) {
+	a := assert.New(t)
+
+	w, err := NewRotate("rotated_", "./testdata", 5)
+	a.NotError(err)
+	clearDir(w.dir)
+
+	rotated := []string{}
+	w.SetRotated(func(path string) {
+		rotated = append(rotated, path)
+	})
+
+	_, err = w.Write([]byte("123456\n"))
+	a.NotError(err)
+	first := w.name
+
+	// 同一秒内不会切换文件
+	if time.Now().Nanosecond() < int(900*time.Millisecond) {
+		_, err = w.Write([]byte("123456\n"))
+		a.NotError(err)
+		a.Empty(rotated)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	_, err = w.Write([]byte("123456\n"))
+	a.NotError(err)
+	a.Equal(rotated, []string{first})
+	a.NotEqual(w.name, first)
+
+	a.NotError(w.Close())
+}
+
+// 记录上传文件的 Uploader
+type rotateTestUploader struct {
+	paths   []string
+	flushed int
+	closed  int
+}
+
+func (u *rotateTestUploader) Rotated(path string) {
+	u.paths = append(u.paths, path)
+}
+
+func (u *rotateTestUploader) Flush() (int, error) {
+	u.flushed++
+	return 0, nil
+}
+
+func (u *rotateTestUploader) Close() error {
+	u.closed++
+	return nil
+}
+
+func TestRotate_SetUploader(t *testing.T) {
+	a := assert.New(t)
+
+	w, err := NewRotate("uploader_", "./testdata", 1024)
+	a.NotError(err)
+	clearDir(w.dir)
+
+	u := &rotateTestUploader{}
+	w.SetUploader(u)
+
+	_, err = w.Write([]byte("123456\n"))
+	a.NotError(err)
+	name := w.name
+
+	_, err = w.Flush()
+	a.NotError(err)
+	a.Empty(u.paths).Equal(u.flushed, 1)
+
+	// 关闭时上传正在写的文件
+	a.NotError(w.Close())
+	a.Equal(u.paths, []string{name}).Equal(u.closed, 1)
+
+	a.NotError(w.Close())
+	a.Equal(u.paths, []string{name}).Equal(u.closed, 2)
+}
+
+func TestRotate_mode(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "logs-rotate")
+	a.NotError(err)
+	defer os.RemoveAll(dir)
+	dir = filepath.Join(dir, "sub")
+
+	// 目录在 NewRotate 中创建，SetDirMode 会修改其权限
+	w, err := NewRotate("mode_", dir, 100)
+	a.NotError(err)
+	_, err = os.Stat(dir)
+	a.NotError(err)
+
+	a.NotError(w.SetDirMode(0700))
+	a.Error(w.SetDirMode(01777))
+	if runtime.GOOS != "windows" {
+		stat, err := os.Stat(dir)
+		a.NotError(err)
+		a.Equal(stat.Mode().Perm(), os.FileMode(0700))
+	}
+
+	_, err = w.Write([]byte("1024\n"))
+	a.NotError(err)
+	a.NotError(w.Close())
+
+	if runtime.GOOS != "windows" {
+		stat, err := os.Stat(dir)
+		a.NotError(err)
+		a.Equal(stat.Mode().Perm(), os.FileMode(0700))
+
+		// 未指定 mode 时与 os.OpenFile 相同，受 umask 的影响
+		ref := filepath.Join(dir, "ref")
+		a.NotError(ioutil.WriteFile(ref, nil, os.ModePerm))
+		refStat, err := os.Stat(ref)
+		a.NotError(err)
+		stat, err = os.Stat(w.name)
+		a.NotError(err)
+		a.Equal(stat.Mode().Perm(), refStat.Mode().Perm())
+	}
+}
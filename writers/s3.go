@@ -0,0 +1,528 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// S3 的默认配置
+const (
+	defaultS3Key      = "{prefix}{host}/{year}/{month}/{day}/{name}"
+	defaultS3PartSize = 16 << 20
+	minS3PartSize     = 5 << 20 // S3 要求除最后一块之外，每块至少 5M
+	defaultS3Timeout  = 5 * time.Minute
+)
+
+// 对象名称模板中可用的占位符
+var s3KeyPlaceholders = []string{"{prefix}", "{host}", "{date}", "{year}", "{month}", "{day}", "{hour}", "{name}"}
+
+// 将 Rotate 写满的文件上传到 S3 兼容的对象存储。
+//
+// 通过 Rotate.SetUploader(s3) 关联，文件被切换或是 Rotate 关闭之后放入队列，
+// 由后台的 goroutine 依次上传，超过 partSize 的文件使用分块上传。
+// 请求使用 AWS 签名 V4，并以 path-style 的形式访问 bucket。
+//
+// 每次请求都带有 Content-MD5，上传完成之后还会比对服务端返回的 ETag，
+// 只有校验通过之后才会根据需要删除本地文件。ETag 不一致(比如使用了 SSE-KMS 加密)
+// 时不会重试，本地文件也会被保留。
+type S3 struct {
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	token     string
+	key       string
+	prefix    string
+	host      string
+	partSize  int64
+	delete    bool
+	client    *http.Client
+
+	size    int
+	retries int
+	backoff time.Duration
+
+	mu    sync.Mutex
+	async *async
+}
+
+// 服务端返回的错误信息
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+// 新建 S3 实例。
+// endpoint 为服务地址，比如 https://s3.us-east-1.amazonaws.com 或是 http://localhost:9000。
+func NewS3(endpoint, region, bucket, accessKey, secretKey string) (*S3, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("无效的endpoint值:[%v]", endpoint)
+	}
+
+	if bucket == "" {
+		return nil, fmt.Errorf("无效的bucket值:[%v]", bucket)
+	}
+
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	host, _ := os.Hostname()
+
+	return &S3{
+		endpoint:  strings.TrimRight(endpoint, "/"),
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		key:       defaultS3Key,
+		host:      host,
+		partSize:  defaultS3PartSize,
+		client:    &http.Client{Timeout: defaultS3Timeout},
+		size:      defaultHTTPQueue,
+		retries:   defaultHTTPRetries,
+		backoff:   defaultHTTPBackoff,
+	}, nil
+}
+
+// 使用临时凭证时需要指定的 session token。
+func (s *S3) SetSessionToken(token string) {
+	s.token = token
+}
+
+// 设置对象名称的模板，默认为 {prefix}{host}/{year}/{month}/{day}/{name}。
+//
+// 可用的占位符有：{prefix}、{host}、{date}(2006-01-02)、{year}、{month}、{day}、
+// {hour} 和 {name}(文件名)，时间为文件最后修改时间的 UTC 时间。
+func (s *S3) SetKey(key string) error {
+	k := key
+	for _, p := range s3KeyPlaceholders {
+		k = strings.Replace(k, p, "", -1)
+	}
+	if strings.ContainsAny(k, "{}") || !strings.Contains(key, "{name}") {
+		return fmt.Errorf("无效的key值:[%v]", key)
+	}
+
+	s.key = key
+	return nil
+}
+
+// 设置模板中 {prefix} 的值，比如 logs/。
+func (s *S3) SetPrefix(prefix string) {
+	s.prefix = prefix
+}
+
+// 设置模板中 {host} 的值，默认为当前主机名。
+func (s *S3) SetHost(host string) {
+	s.host = host
+}
+
+// 设置分块上传时每块的大小，不能小于 5M，超过该大小的文件才会使用分块上传。
+func (s *S3) SetPartSize(size int64) error {
+	if size < minS3PartSize {
+		return fmt.Errorf("无效的size值:[%v]", size)
+	}
+
+	s.partSize = size
+	return nil
+}
+
+// 上传并校验成功之后是否删除本地文件。
+func (s *S3) SetDelete(del bool) {
+	s.delete = del
+}
+
+// 设置每次请求的超时时间，默认为 5 分钟。
+func (s *S3) SetTimeout(timeout time.Duration) {
+	s.client.Timeout = timeout
+}
+
+// 设置 http.Client 使用的 Transport，可用于指定 TLS 等配置。
+func (s *S3) SetTransport(transport http.RoundTripper) {
+	s.client.Transport = transport
+}
+
+// 设置队列的大小以及上传失败之后的重试次数。
+// 第一次重试之前等待 backoff，之后每次翻倍。
+// 只能在第一次调用 Rotated 之前设置。
+func (s *S3) SetQueue(size, retries int, backoff time.Duration) error {
+	if size < 1 {
+		return fmt.Errorf("无效的size值:[%v]", size)
+	}
+
+	s.size = size
+	s.retries = retries
+	s.backoff = backoff
+	return nil
+}
+
+// 将文件放入上传队列。
+func (s *S3) Rotated(path string) {
+	s.mu.Lock()
+	if s.async == nil {
+		s.async = newAsync(s.size, s.retries, s.backoff, func(data []byte) error {
+			return s.Upload(string(data))
+		})
+	}
+	a := s.async
+	s.mu.Unlock()
+
+	// 队列已满时直接丢弃，本地文件依然保留。
+	a.push([]byte(path))
+}
+
+// 上传失败的文件数量，包括队列已满和重试之后依然上传失败的文件。
+func (s *S3) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.async == nil {
+		return 0
+	}
+	return s.async.dropped()
+}
+
+// Flusher.Flush()
+// 等待队列中的文件全部上传完成。
+func (s *S3) Flush() (int, error) {
+	s.mu.Lock()
+	a := s.async
+	s.mu.Unlock()
+
+	if a != nil {
+		a.flush()
+	}
+	return 0, nil
+}
+
+// io.Closer.Close()
+// 上传完队列中的文件之后关闭后台 goroutine。
+func (s *S3) Close() error {
+	s.mu.Lock()
+	a := s.async
+	s.async = nil
+	s.mu.Unlock()
+
+	if a != nil {
+		a.close()
+	}
+	return nil
+}
+
+// 上传文件，校验成功之后根据设置删除本地文件。
+func (s *S3) Upload(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return &noRetryError{err: err}
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return &noRetryError{err: err}
+	}
+	key := s.objectKey(filepath.Base(path), stat.ModTime().UTC())
+
+	if stat.Size() <= s.partSize {
+		err = s.put(f, key)
+	} else {
+		err = s.multipart(f, key)
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.delete {
+		f.Close()
+		return os.Remove(path)
+	}
+	return nil
+}
+
+// 根据模板生成对象名称
+func (s *S3) objectKey(name string, t time.Time) string {
+	r := strings.NewReplacer(
+		"{prefix}", s.prefix,
+		"{host}", s.host,
+		"{date}", t.Format("2006-01-02"),
+		"{year}", t.Format("2006"),
+		"{month}", t.Format("01"),
+		"{day}", t.Format("02"),
+		"{hour}", t.Format("15"),
+		"{name}", name,
+	)
+	return strings.TrimLeft(r.Replace(s.key), "/")
+}
+
+// 通过一次 PUT 请求上传整个文件
+func (s *S3) put(f io.Reader, key string) error {
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return &noRetryError{err: err}
+	}
+
+	sum := md5.Sum(data)
+	resp, _, err := s.do(http.MethodPut, key, nil, data)
+	if err != nil {
+		return err
+	}
+
+	return checkS3ETag(resp.Header.Get("ETag"), hex.EncodeToString(sum[:]))
+}
+
+// 分块上传，失败时会中止本次上传，以释放服务端已经保存的分块。
+func (s *S3) multipart(f io.Reader, key string) error {
+	_, body, err := s.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return err
+	}
+	initResult := &struct {
+		UploadID string `xml:"UploadId"`
+	}{}
+	if err = xml.Unmarshal(body, initResult); err != nil || initResult.UploadID == "" {
+		return fmt.Errorf("无效的返回内容:[%s]", body)
+	}
+	uploadID := initResult.UploadID
+
+	if err = s.uploadParts(f, key, uploadID); err != nil {
+		s.do(http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil)
+	}
+	return err
+}
+
+func (s *S3) uploadParts(f io.Reader, key, uploadID string) error {
+	type part struct {
+		Number int    `xml:"PartNumber"`
+		ETag   string `xml:"ETag"`
+	}
+	parts := make([]*part, 0, 10)
+	sums := make([]byte, 0, 10*md5.Size)
+
+	buf := make([]byte, s.partSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if err == io.EOF {
+			break
+		} else if err != nil && err != io.ErrUnexpectedEOF {
+			return &noRetryError{err: err}
+		}
+
+		num := len(parts) + 1
+		query := url.Values{"partNumber": {strconv.Itoa(num)}, "uploadId": {uploadID}}
+		resp, _, err := s.do(http.MethodPut, key, query, buf[:n])
+		if err != nil {
+			return err
+		}
+
+		sum := md5.Sum(buf[:n])
+		etag := resp.Header.Get("ETag")
+		if err = checkS3ETag(etag, hex.EncodeToString(sum[:])); err != nil {
+			return err
+		}
+		parts = append(parts, &part{Number: num, ETag: etag})
+		sums = append(sums, sum[:]...)
+
+		if n < len(buf) {
+			break
+		}
+	}
+
+	data, err := xml.Marshal(&struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []*part  `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return &noRetryError{err: err}
+	}
+
+	_, body, err := s.do(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, data)
+	if err != nil {
+		return err
+	}
+
+	// 完成请求即使返回 200，内容也可能是错误信息
+	result := &struct {
+		XMLName xml.Name
+		ETag    string `xml:"ETag"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}{}
+	if err = xml.Unmarshal(body, result); err != nil {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("完成分块上传失败:[%v]%v", result.Code, result.Message)
+	}
+
+	// 分块上传的 ETag 为各块 MD5 拼接之后的 MD5，加上块的数量
+	sum := md5.Sum(sums)
+	return checkS3ETag(result.ETag, hex.EncodeToString(sum[:])+"-"+strconv.Itoa(len(parts)))
+}
+
+// 发送请求，返回响应以及响应的内容。
+func (s *S3) do(method, key string, query url.Values, body []byte) (*http.Response, []byte, error) {
+	u := s.endpoint + "/" + s3Escape(s.bucket, false) + "/" + s3Escape(key, true)
+	if len(query) > 0 {
+		u += "?" + s3Query(query)
+	}
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, &noRetryError{err: err}
+	}
+	if body != nil {
+		sum := md5.Sum(body)
+		req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	}
+
+	payload := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(payload[:]), time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil { // 网络错误，需要重试
+		return nil, nil, err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode < 300 {
+		return resp, data, nil
+	}
+
+	e := &s3Error{}
+	xml.Unmarshal(data, e)
+	err = fmt.Errorf("服务端返回错误的状态码:[%v]%v", resp.StatusCode, e.Code)
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, nil, err
+	}
+	return nil, nil, &noRetryError{err: err}
+}
+
+// 使用 AWS 签名 V4 对请求进行签名，所有的报头都会参与签名。
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.token != "" {
+		req.Header.Set("X-Amz-Security-Token", s.token)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonical := new(bytes.Buffer)
+	canonical.WriteString(req.Method + "\n")
+	canonical.WriteString(req.URL.EscapedPath() + "\n")
+	canonical.WriteString(s3Query(req.URL.Query()) + "\n")
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonical.WriteString("\n" + signedHeaders + "\n" + payloadHash)
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	hash := sha256.Sum256(canonical.Bytes())
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := s3HMAC([]byte("AWS4"+s.secretKey), date)
+	key = s3HMAC(key, s.region)
+	key = s3HMAC(key, "s3")
+	key = s3HMAC(key, "aws4_request")
+	signature := hex.EncodeToString(s3HMAC(key, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// 按 AWS 的规则进行编码，除 A-Za-z0-9-_.~ 之外的字符都需要编码，
+// keepSlash 表示是否保留路径中的 /。
+func s3Escape(s string, keepSlash bool) string {
+	buf := new(strings.Builder)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9'),
+			c == '-', c == '_', c == '.', c == '~', c == '/' && keepSlash:
+			buf.WriteByte(c)
+		default:
+			fmt.Fprintf(buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+// 按键名排序之后的查询参数
+func s3Query(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		for _, value := range query[name] {
+			pairs = append(pairs, s3Escape(name, false)+"="+s3Escape(value, false))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// 比对服务端返回的 ETag，不一致时不再重试。
+func checkS3ETag(etag, expected string) error {
+	if strings.Trim(etag, `"`) == expected {
+		return nil
+	}
+	return &noRetryError{err: errors.New("ETag 校验失败:" + etag)}
+}
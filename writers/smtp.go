@@ -6,8 +6,43 @@ package writers
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
 	"net/smtp"
+	"net/textproto"
+	"os"
+	"sort"
 	"strings"
+	"time"
+)
+
+// Smtp 的 TLS 模式
+const (
+	SmtpTLSAuto     = iota // 服务器支持 STARTTLS 时启用，与 smtp.SendMail 的行为相同
+	SmtpTLSStartTLS        // 必须通过 STARTTLS 升级连接，否则发送失败
+	SmtpTLSImplicit        // 直接建立 TLS 连接，一般用于 465 端口
+	SmtpTLSNone            // 不使用 TLS
+)
+
+// Smtp 默认的连接和发送超时时间
+const defaultSmtpTimeout = 30 * time.Second
+
+// Smtp 支持的认证方式
+const (
+	SmtpAuthPlain   = "plain"
+	SmtpAuthLogin   = "login"
+	SmtpAuthCRAMMD5 = "cram-md5"
+	SmtpAuthNone    = "none" // 不认证，一般用于本地的中继服务器
 )
 
 // 实现io.Writer接口的邮件发送。
@@ -17,11 +52,21 @@ type Smtp struct {
 	host     string   // smtp主机，需要带上端口
 	sendTo   []string // 接收者。
 	subject  string   // 邮件主题。
+	from     string   // 邮件头中的发送者，为空表示与 username 相同
+	sender   string   // 信封发送者(MAIL FROM)，为空表示与 from 相同
 
-	// 邮件内容的缓存
-	cache *bytes.Buffer
-	// 邮件头部分的长度
-	headerLen int
+	tlsMode   int
+	tlsConfig *tls.Config
+	authMech  string
+
+	html       bool   // 是否同时附带 HTML 格式的正文
+	attachment string // 附件的文件名，为空表示不以附件的形式发送日志内容
+
+	timeout time.Duration // 连接和发送的超时时间，为 0 表示不限制
+	async   *async        // 异步发送队列，为 nil 表示同步发送
+
+	// 固定不变的邮件头，包括 To、From 和 Subject
+	header []byte
 
 	auth smtp.Auth
 }
@@ -39,64 +84,506 @@ func NewSmtp(username, password, subject, host string, sendTo []string) *Smtp {
 		subject:  subject,
 		host:     host,
 		sendTo:   sendTo,
+		authMech: SmtpAuthPlain,
+		timeout:  defaultSmtpTimeout,
 	}
 	ret.init()
 
 	return ret
 }
 
+// 设置 TLS 模式，mode 的值为 SmtpTLSAuto 等常量。
+// conf 为 nil 时使用默认的配置，ServerName 为空时会自动填充主机名。
+func (s *Smtp) SetTLS(mode int, conf *tls.Config) error {
+	if mode < SmtpTLSAuto || mode > SmtpTLSNone {
+		return fmt.Errorf("无效的 TLS 模式:[%v]", mode)
+	}
+
+	s.tlsMode = mode
+	s.tlsConfig = conf
+	return nil
+}
+
+// 设置认证方式，mechanism 的值为 SmtpAuthPlain 等常量。
+func (s *Smtp) SetAuth(mechanism string) error {
+	switch mechanism {
+	case SmtpAuthPlain, SmtpAuthLogin, SmtpAuthCRAMMD5, SmtpAuthNone:
+	default:
+		return fmt.Errorf("无效的认证方式:[%v]", mechanism)
+	}
+
+	s.authMech = mechanism
+	s.init()
+	return nil
+}
+
+// 设置邮件头中 From 的地址，为空表示与 username 相同。
+func (s *Smtp) SetFrom(from string) {
+	s.from = from
+	s.init()
+}
+
+// 设置信封发送者(MAIL FROM)的地址，为空表示与 From 相同。
+func (s *Smtp) SetSender(sender string) {
+	s.sender = sender
+}
+
+// 设置是否同时发送 HTML 格式的正文。
+// 邮件客户端可以根据自身的能力选择显示纯文本或是 HTML 的内容。
+func (s *Smtp) SetHTML(html bool) {
+	s.html = html
+}
+
+// 设置附件的文件名，日志内容会同时以该文件名作为附件发送，
+// 为空表示不发送附件。
+func (s *Smtp) SetAttachment(filename string) {
+	s.attachment = filename
+}
+
+// 设置连接和发送的超时时间，为 0 表示不限制。
+func (s *Smtp) SetTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+// 启用异步发送。
+//
+// 启用之后，Write 只是将邮件放入大小为 size 的队列中，由后台的 goroutine
+// 负责发送，不会阻塞调用者；队列已满时，新的邮件将被丢弃。
+// 发送失败时最多重试 retries 次，第一次重试之前等待 backoff，之后每次翻倍。
+// size 小于 1 时，表示恢复为同步发送。
+func (s *Smtp) SetAsync(size, retries int, backoff time.Duration) {
+	if s.async != nil {
+		s.async.close()
+		s.async = nil
+	}
+
+	if size > 0 {
+		s.async = newAsync(size, retries, backoff, s.send)
+	}
+}
+
+// 被丢弃的邮件数量，包括队列已满和重试之后依然发送失败的邮件。
+// 仅在异步发送时有效。
+func (s *Smtp) Dropped() uint64 {
+	if s.async == nil {
+		return 0
+	}
+	return s.async.dropped()
+}
+
+// Flusher.Flush()
+// 异步发送时，等待队列中的邮件全部发送完成。
+func (s *Smtp) Flush() (int, error) {
+	if s.async != nil {
+		s.async.flush()
+	}
+	return 0, nil
+}
+
+// io.Closer.Close()
+// 异步发送时，发送完队列中的邮件之后关闭后台 goroutine。
+func (s *Smtp) Close() error {
+	if s.async != nil {
+		s.async.close()
+	}
+	return nil
+}
+
 // 初始化一些基本内容。
 //
-// 像To,From这些内容都是固定的，可以先写入到缓存中，这样
+// 像To,From这些内容都是固定的，可以先构造好，这样
 // 这后就不需要再次构造这些内容。
 func (s *Smtp) init() {
-	s.cache = bytes.NewBufferString("")
-	s.cache.Grow(1024)
+	buf := new(bytes.Buffer)
 
-	// to
-	s.cache.WriteString("To: ")
-	s.cache.WriteString(strings.Join(s.sendTo, ";"))
-	s.cache.WriteString("\r\n")
+	// to，地址较多时折行
+	to := make([]string, 0, len(s.sendTo))
+	for _, addr := range s.sendTo {
+		to = append(to, formatAddress(addr))
+	}
+	sep := ", "
+	if len(strings.Join(to, sep)) > 72 {
+		sep = ",\r\n "
+	}
+	buf.WriteString("To: ")
+	buf.WriteString(strings.Join(to, sep))
+	buf.WriteString("\r\n")
 
 	// from
-	s.cache.WriteString("From: ")
-	s.cache.WriteString(s.username) // <...>有需要吗？
-	s.cache.WriteString("\r\n")
+	buf.WriteString("From: ")
+	buf.WriteString(formatAddress(s.fromAddr()))
+	buf.WriteString("\r\n")
+
+	// subject，非 ASCII 字符按 RFC 2047 编码
+	buf.WriteString("Subject: ")
+	buf.WriteString(mime.BEncoding.Encode("utf-8", s.subject))
+	buf.WriteString("\r\n")
 
-	// subject
-	s.cache.WriteString("Subject: ")
-	s.cache.WriteString(s.subject)
-	s.cache.WriteString("\r\n")
+	s.header = buf.Bytes()
 
-	// mime-version
-	s.cache.WriteString("MIME-Version: ")
-	s.cache.WriteString("1.0\r\n")
+	h := s.hostname()
+	switch s.authMech {
+	case SmtpAuthPlain:
+		s.auth = smtp.PlainAuth("", s.username, s.password, h)
+	case SmtpAuthLogin:
+		s.auth = &loginAuth{username: s.username, password: s.password, host: h}
+	case SmtpAuthCRAMMD5:
+		s.auth = smtp.CRAMMD5Auth(s.username, s.password)
+	default:
+		s.auth = nil
+	}
+}
 
-	// contentType
-	s.cache.WriteString(`Content-Type: text/plain; charset="utf-8"`)
-	s.cache.WriteString("\r\n\r\n")
+func (s *Smtp) fromAddr() string {
+	if s.from != "" {
+		return s.from
+	}
+	return s.username
+}
 
-	s.headerLen = s.cache.Len()
+func (s *Smtp) envelopeSender() string {
+	if s.sender != "" {
+		return s.sender
+	}
+	return s.fromAddr()
+}
 
-	// 去掉端口部分
-	h := strings.Split(s.host, ":")[0]
-	s.auth = smtp.PlainAuth("", s.username, s.password, h)
+// 去掉端口部分的主机名
+func (s *Smtp) hostname() string {
+	if h, _, err := net.SplitHostPort(s.host); err == nil {
+		return h
+	}
+	return s.host
+}
+
+func (s *Smtp) tlsConf() *tls.Config {
+	if s.tlsConfig == nil {
+		return &tls.Config{ServerName: s.hostname()}
+	}
+
+	if s.tlsConfig.ServerName != "" {
+		return s.tlsConfig
+	}
+
+	conf := s.tlsConfig.Clone()
+	conf.ServerName = s.hostname()
+	return conf
 }
 
 // io.Writer
 func (s *Smtp) Write(msg []byte) (int, error) {
-	s.cache.Write(msg)
+	data, err := s.message(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	if s.async != nil {
+		// 队列已满时直接丢弃，由 Dropped() 记录丢弃的数量。
+		s.async.push(data)
+		return len(msg), nil
+	}
+
+	if err = s.send(data); err != nil {
+		return 0, err
+	}
+	return len(msg), nil
+}
+
+// 将 body 构造成一封完整的邮件，符合 RFC 5322 和 RFC 2045 等规范。
+//
+// 正文采用 quoted-printable 编码，以避免单行内容过长；
+// 根据 html 和 attachment 的设置，可能会是 multipart 格式的内容。
+func (s *Smtp) message(body []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.Grow(len(s.header) + len(body)*2 + 512)
+
+	buf.WriteString("Date: ")
+	buf.WriteString(time.Now().Format(time.RFC1123Z))
+	buf.WriteString("\r\n")
+	buf.WriteString("Message-ID: ")
+	buf.WriteString(messageID(s.fromAddr()))
+	buf.WriteString("\r\n")
+	buf.Write(s.header)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if !s.html && s.attachment == "" {
+		writeMIMEHeader(buf, textPartHeader("text/plain"))
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(buf, body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	if s.attachment == "" {
+		mw := multipart.NewWriter(buf)
+		buf.WriteString("Content-Type: multipart/alternative; boundary=" + mw.Boundary() + "\r\n\r\n")
+		if err := writeAlternative(mw, body); err != nil {
+			return nil, err
+		}
+		if err := mw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
 
-	err := smtp.SendMail(
-		s.host,
-		s.auth,
-		s.username,
-		s.sendTo,
-		s.cache.Bytes(),
-	)
-	l := s.cache.Len()
+	mw := multipart.NewWriter(buf)
+	buf.WriteString("Content-Type: multipart/mixed; boundary=" + mw.Boundary() + "\r\n\r\n")
 
-	s.cache.Truncate(s.headerLen)
+	if s.html {
+		// 嵌套的 multipart/alternative，需要在创建子项之前确定其 boundary。
+		boundary := multipart.NewWriter(nil).Boundary()
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", "multipart/alternative; boundary="+boundary)
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+
+		alt := multipart.NewWriter(w)
+		if err = alt.SetBoundary(boundary); err != nil {
+			return nil, err
+		}
+		if err = writeAlternative(alt, body); err != nil {
+			return nil, err
+		}
+		if err = alt.Close(); err != nil {
+			return nil, err
+		}
+	} else {
+		w, err := mw.CreatePart(textPartHeader("text/plain"))
+		if err != nil {
+			return nil, err
+		}
+		if err = writeQuotedPrintable(w, body); err != nil {
+			return nil, err
+		}
+	}
+
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", mime.FormatMediaType("text/plain", map[string]string{
+		"charset": "utf-8",
+		"name":    s.attachment,
+	}))
+	h.Set("Content-Transfer-Encoding", "base64")
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": s.attachment,
+	}))
+	w, err := mw.CreatePart(h)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeBase64(w, body); err != nil {
+		return nil, err
+	}
+	if err = mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// 根据 TLS 模式和认证方式发送一封邮件，功能与 smtp.SendMail 相同。
+func (s *Smtp) send(msg []byte) error {
+	dialer := &net.Dialer{Timeout: s.timeout}
+	var conn net.Conn
+	var err error
+	if s.tlsMode == SmtpTLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.host, s.tlsConf())
+	} else {
+		conn, err = dialer.Dial("tcp", s.host)
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.timeout > 0 {
+		if err = conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	c, err := smtp.NewClient(conn, s.hostname())
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	switch s.tlsMode {
+	case SmtpTLSAuto:
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err = c.StartTLS(s.tlsConf()); err != nil {
+				return err
+			}
+		}
+	case SmtpTLSStartTLS:
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp 服务器不支持 STARTTLS")
+		}
+		if err = c.StartTLS(s.tlsConf()); err != nil {
+			return err
+		}
+	}
+
+	if s.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp 服务器不支持 AUTH")
+		}
+		if err = c.Auth(s.auth); err != nil {
+			return err
+		}
+	}
+
+	if err = c.Mail(s.envelopeSender()); err != nil {
+		return err
+	}
+	for _, addr := range s.sendTo {
+		if err = c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// LOGIN 认证方式，标准库中并未提供。
+type loginAuth struct {
+	username, password, host string
+}
+
+// smtp.Auth.Start()
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// 与 smtp.PlainAuth 相同，只在加密连接或是本机时才发送密码。
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+// smtp.Auth.Next()
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	prompt := strings.ToLower(string(fromServer))
+	switch {
+	case strings.Contains(prompt, "user"):
+		return []byte(a.username), nil
+	case strings.Contains(prompt, "pass"):
+		return []byte(a.password), nil
+	}
+
+	return nil, fmt.Errorf("无法识别的 LOGIN 质询:[%s]", fromServer)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+// 将地址格式化为 RFC 5322 的格式，名称部分包含非 ASCII 字符时会被编码。
+// 无法解析的地址原样返回。
+func formatAddress(addr string) string {
+	a, err := mail.ParseAddress(strings.TrimSpace(addr))
+	if err != nil {
+		return strings.TrimSpace(addr)
+	}
+	return a.String()
+}
+
+// 生成一个唯一的 Message-ID，域名部分取自 from 地址。
+func messageID(from string) string {
+	domain := "localhost"
+	if a, err := mail.ParseAddress(from); err == nil {
+		if index := strings.LastIndexByte(a.Address, '@'); index >= 0 {
+			domain = a.Address[index+1:]
+		}
+	} else if h, err := os.Hostname(); err == nil {
+		domain = h
+	}
+
+	var b [8]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("<%d.%x@%s>", time.Now().UnixNano(), b, domain)
+}
+
+func textPartHeader(contentType string) textproto.MIMEHeader {
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", contentType+`; charset="utf-8"`)
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	return h
+}
+
+func writeMIMEHeader(w io.Writer, h textproto.MIMEHeader) {
+	// 保证输出顺序固定
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(w, "%s: %s\r\n", k, v)
+		}
+	}
+}
+
+// 将 body 以 text/plain 和 text/html 两种格式写入 mw。
+func writeAlternative(mw *multipart.Writer, body []byte) error {
+	w, err := mw.CreatePart(textPartHeader("text/plain"))
+	if err != nil {
+		return err
+	}
+	if err = writeQuotedPrintable(w, body); err != nil {
+		return err
+	}
+
+	w, err = mw.CreatePart(textPartHeader("text/html"))
+	if err != nil {
+		return err
+	}
+	content := "<html><body><pre>" + html.EscapeString(string(body)) + "</pre></body></html>"
+	return writeQuotedPrintable(w, []byte(content))
+}
+
+func writeQuotedPrintable(w io.Writer, body []byte) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write(body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// 以 base64 编码写入 body，每行不超过 76 个字符。
+func writeBase64(w io.Writer, body []byte) error {
+	const lineLen = 76
+
+	enc := base64.StdEncoding.EncodeToString(body)
+	for len(enc) > lineLen {
+		if _, err := io.WriteString(w, enc[:lineLen]+"\r\n"); err != nil {
+			return err
+		}
+		enc = enc[lineLen:]
+	}
 
-	return l, err
+	_, err := io.WriteString(w, enc+"\r\n")
+	return err
 }
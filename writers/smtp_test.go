@@ -5,25 +5,198 @@
 package writers
 
 import (
+	"bytes"
+	"encoding/base64"
 	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/issue9/assert"
+	"github.com/issue9/logs/writers/smtptest"
 )
 
-var _ io.Writer = &Smtp{}
+var _ io.WriteCloser = &Smtp{}
 
-func testSmtp(t *testing.T) {
-	smtp := NewSmtp("test@qq.com", "pwd", "test", "smtp.qq.com:25", []string{"test@gmail.com"})
+var _ WriteFlusher = &Smtp{}
 
-	size, err := smtp.Write([]byte("test"))
-	assert.NotError(t, err)
-	assert.True(t, size > 0)
+var _ smtp.Auth = &loginAuth{}
 
-	time.Sleep(30 * time.Second)
+func TestSmtp_Set(t *testing.T) {
+	a := assert.New(t)
+	s := NewSmtp("test@qq.com", "pwd", "test", "smtp.qq.com:465", []string{"test@gmail.com"})
 
-	size, err = smtp.Write([]byte("test2"))
-	assert.NotError(t, err)
-	assert.True(t, size > 0)
+	a.Error(s.SetAuth("xoauth"))
+	a.NotError(s.SetAuth(SmtpAuthNone))
+	a.Nil(s.auth)
+	a.NotError(s.SetAuth(SmtpAuthLogin))
+	_, ok := s.auth.(*loginAuth)
+	a.True(ok)
+
+	a.Error(s.SetTLS(-1, nil))
+	a.NotError(s.SetTLS(SmtpTLSImplicit, nil))
+	a.Equal(s.tlsConf().ServerName, "smtp.qq.com")
+
+	a.Equal(s.envelopeSender(), "test@qq.com")
+	s.SetFrom("logs@qq.com")
+	a.Equal(s.envelopeSender(), "logs@qq.com")
+	s.SetSender("bounce@qq.com")
+	a.Equal(s.envelopeSender(), "bounce@qq.com")
+}
+
+func TestLoginAuth(t *testing.T) {
+	a := assert.New(t)
+	auth := &loginAuth{username: "user", password: "pwd", host: "localhost"}
+
+	// 非加密连接，且非本机
+	_, _, err := auth.Start(&smtp.ServerInfo{Name: "example.com"})
+	a.Error(err)
+
+	proto, _, err := auth.Start(&smtp.ServerInfo{Name: "localhost"})
+	a.NotError(err).Equal(proto, "LOGIN")
+
+	resp, err := auth.Next([]byte("Username:"), true)
+	a.NotError(err).Equal(resp, []byte("user"))
+	resp, err = auth.Next([]byte("Password:"), true)
+	a.NotError(err).Equal(resp, []byte("pwd"))
+	_, err = auth.Next([]byte("abc"), true)
+	a.Error(err)
+}
+
+func TestSmtp_Write(t *testing.T) {
+	a := assert.New(t)
+
+	srv := smtptest.NewServer()
+	defer srv.Close()
+	srv.SetAuth("test@qq.com", "pwd")
+
+	s := NewSmtp("test@qq.com", "pwd", "test", srv.Addr, []string{"test@gmail.com"})
+	size, err := s.Write([]byte("test"))
+	a.NotError(err).True(size > 0)
+
+	a.NotError(s.SetAuth(SmtpAuthLogin))
+	s.SetSender("bounce@qq.com")
+	size, err = s.Write([]byte("test2"))
+	a.NotError(err).True(size > 0)
+
+	msgs := srv.Messages()
+	a.Equal(len(msgs), 2)
+	a.Equal(msgs[0].From, "test@qq.com").Equal(msgs[0].To, []string{"test@gmail.com"})
+	a.Equal(msgs[1].From, "bounce@qq.com").Equal(msgs[1].Username, "test@qq.com")
+	msg, err := mail.ReadMessage(bytes.NewReader(msgs[1].Data))
+	a.NotError(err)
+	body, err := ioutil.ReadAll(quotedprintable.NewReader(msg.Body))
+	a.NotError(err).Equal(strings.TrimSpace(string(body)), "test2")
+
+	// 认证失败
+	srv.SetAuthFail(true)
+	_, err = s.Write([]byte("test3"))
+	a.Error(err)
+	srv.SetAuthFail(false)
+
+	// 不认证
+	a.NotError(s.SetAuth(SmtpAuthNone))
+	_, err = s.Write([]byte("test4"))
+	a.NotError(err)
+	a.Equal(srv.Messages()[2].Username, "")
+
+	// 响应超时
+	srv.SetDelay(200 * time.Millisecond)
+	s.SetTimeout(100 * time.Millisecond)
+	_, err = s.Write([]byte("test5"))
+	a.Error(err)
+	a.Equal(len(srv.Messages()), 3)
+}
+
+func TestSmtp_message(t *testing.T) {
+	a := assert.New(t)
+	s := NewSmtp("test@qq.com", "pwd", "中文主题", "smtp.qq.com:25", []string{"a@example.com", "b@example.com"})
+	body := []byte(strings.Repeat("日志内容", 50) + "\n")
+
+	read := func(data []byte) *mail.Message {
+		msg, err := mail.ReadMessage(bytes.NewReader(data))
+		a.NotError(err).NotNil(msg)
+
+		subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		a.NotError(err).Equal(subject, "中文主题")
+
+		to, err := msg.Header.AddressList("To")
+		a.NotError(err).Equal(len(to), 2)
+
+		_, err = msg.Header.Date()
+		a.NotError(err)
+		a.True(strings.HasSuffix(msg.Header.Get("Message-ID"), "@qq.com>"))
+		return msg
+	}
+
+	// text/plain
+	data, err := s.message(body)
+	a.NotError(err)
+	for _, line := range strings.Split(string(data), "\r\n") {
+		a.True(len(line) <= 78, line)
+	}
+	msg := read(data)
+	a.Equal(msg.Header.Get("Content-Transfer-Encoding"), "quoted-printable")
+	content, err := ioutil.ReadAll(quotedprintable.NewReader(msg.Body))
+	a.NotError(err).Equal(string(content), strings.Replace(string(body), "\n", "\r\n", -1))
+
+	// html + 附件
+	s.SetHTML(true)
+	s.SetAttachment("error.log")
+	data, err = s.message(body)
+	a.NotError(err)
+	msg = read(data)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	a.NotError(err).Equal(mediaType, "multipart/mixed")
+	r := multipart.NewReader(msg.Body, params["boundary"])
+
+	p, err := r.NextPart()
+	a.NotError(err)
+	mediaType, _, err = mime.ParseMediaType(p.Header.Get("Content-Type"))
+	a.NotError(err).Equal(mediaType, "multipart/alternative")
+
+	p, err = r.NextPart()
+	a.NotError(err).Equal(p.FileName(), "error.log")
+	content, err = ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+	a.NotError(err).Equal(content, body)
+}
+
+func TestSmtp_async(t *testing.T) {
+	a := assert.New(t)
+
+	// 一个无法连接的地址
+	s := NewSmtp("test@qq.com", "pwd", "test", "127.0.0.1:1", []string{"test@gmail.com"})
+	s.SetTimeout(time.Second)
+
+	// 同步发送返回错误
+	size, err := s.Write([]byte("test"))
+	a.Error(err).Equal(size, 0)
+
+	// 异步发送不会返回错误，失败的邮件被丢弃
+	s.SetAsync(1, 0, time.Millisecond)
+	size, err = s.Write([]byte("test"))
+	a.NotError(err).Equal(size, 4)
+	_, err = s.Flush()
+	a.NotError(err)
+	a.Equal(s.Dropped(), 1)
+	a.NotError(s.Close())
+
+	// 异步发送到正常的服务器
+	srv := smtptest.NewServer()
+	defer srv.Close()
+	s = NewSmtp("test@qq.com", "pwd", "test", srv.Addr, []string{"test@gmail.com"})
+	s.SetAsync(10, 0, time.Millisecond)
+	for i := 0; i < 5; i++ {
+		_, err = s.Write([]byte("test"))
+		a.NotError(err)
+	}
+	a.NotError(s.Close())
+	a.Equal(len(srv.Messages()), 5).Equal(s.Dropped(), 0)
 }
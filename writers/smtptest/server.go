@@ -0,0 +1,388 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// smtptest 提供了一个用于测试的本地 SMTP 服务器。
+//
+// 服务器监听在本机的随机端口上，记录收到的所有邮件，
+// 并可以模拟认证失败和响应缓慢等情况：
+//  srv := smtptest.NewServer()
+//  defer srv.Close()
+//
+//  w := writers.NewSmtp("user", "pwd", "subject", srv.Addr, []string{"to@example.com"})
+//  w.Write([]byte("log"))
+//  msgs := srv.Messages()
+package smtptest
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 服务器收到的一封邮件
+type Message struct {
+	Username string   // 认证时使用的账号，未认证则为空
+	From     string   // 信封发送者(MAIL FROM)
+	To       []string // 信封接收者(RCPT TO)
+	Data     []byte   // DATA 部分的内容，包括邮件头
+}
+
+// 用于测试的 SMTP 服务器
+type Server struct {
+	Addr string // 监听地址，包含端口号，可以直接传递给 writers.NewSmtp
+
+	ln    net.Listener
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	msgs  []*Message
+	conns map[net.Conn]struct{}
+
+	username string
+	password string
+	authFail bool
+	delay    time.Duration
+}
+
+// 新建并启动一个监听在 127.0.0.1 随机端口上的 SMTP 服务器，
+// 使用完之后需要调用 Close 关闭。
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("smtptest: 无法监听端口:%v", err))
+	}
+
+	s := &Server{
+		Addr:  ln.Addr().String(),
+		ln:    ln,
+		conns: make(map[net.Conn]struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s
+}
+
+// 设置认证的账号和密码，之后只有匹配的账号才能通过认证。
+// 若未设置，则任意账号都能通过认证。
+func (s *Server) SetAuth(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.username = username
+	s.password = password
+}
+
+// 设置是否模拟认证失败，为 true 时所有的认证请求都将失败。
+func (s *Server) SetAuthFail(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.authFail = fail
+}
+
+// 设置每一条响应之前的延时，用于模拟响应缓慢的服务器。
+func (s *Server) SetDelay(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delay = delay
+}
+
+// 返回已经收到的邮件
+func (s *Server) Messages() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := make([]*Message, len(s.msgs))
+	copy(msgs, s.msgs)
+	return msgs
+}
+
+// 清除已经收到的邮件
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.msgs = s.msgs[:0]
+}
+
+// 关闭服务器及所有未断开的连接。
+func (s *Server) Close() {
+	s.ln.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// 一个连接的会话状态
+type session struct {
+	s    *Server
+	tp   *textproto.Conn
+	user string
+	msg  *Message
+}
+
+func (s *Server) handle(conn net.Conn) {
+	s.mu.Lock()
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+
+	sess := &session{s: s, tp: textproto.NewConn(conn)}
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		sess.tp.Close()
+	}()
+
+	sess.reply(220, "localhost ESMTP smtptest")
+
+	for {
+		line, err := sess.tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd, arg := line, ""
+		if index := strings.IndexByte(line, ' '); index >= 0 {
+			cmd, arg = line[:index], strings.TrimSpace(line[index+1:])
+		}
+
+		if !sess.exec(strings.ToUpper(cmd), arg) {
+			return
+		}
+	}
+}
+
+// 执行一条命令，返回 false 表示需要关闭连接。
+func (sess *session) exec(cmd, arg string) bool {
+	switch cmd {
+	case "EHLO":
+		sess.reply(250, "localhost", "AUTH PLAIN LOGIN CRAM-MD5", "8BITMIME")
+	case "HELO":
+		sess.reply(250, "localhost")
+	case "AUTH":
+		sess.auth(arg)
+	case "MAIL":
+		sess.msg = &Message{Username: sess.user, From: trimPath(arg, "FROM:")}
+		sess.reply(250, "OK")
+	case "RCPT":
+		if sess.msg == nil {
+			sess.reply(503, "need MAIL command")
+			return true
+		}
+		sess.msg.To = append(sess.msg.To, trimPath(arg, "TO:"))
+		sess.reply(250, "OK")
+	case "DATA":
+		sess.data()
+	case "RSET":
+		sess.msg = nil
+		sess.reply(250, "OK")
+	case "NOOP":
+		sess.reply(250, "OK")
+	case "QUIT":
+		sess.reply(221, "bye")
+		return false
+	default:
+		sess.reply(502, "command not implemented")
+	}
+
+	return true
+}
+
+func (sess *session) data() {
+	if sess.msg == nil || len(sess.msg.To) == 0 {
+		sess.reply(503, "need RCPT command")
+		return
+	}
+
+	sess.reply(354, "end data with <CR><LF>.<CR><LF>")
+	data, err := sess.tp.ReadDotBytes()
+	if err != nil {
+		return
+	}
+
+	// ReadDotBytes 会将 \r\n 转换成 \n，此处还原成原始内容。
+	sess.msg.Data = []byte(strings.Replace(string(data), "\n", "\r\n", -1))
+
+	sess.s.mu.Lock()
+	sess.s.msgs = append(sess.s.msgs, sess.msg)
+	sess.s.mu.Unlock()
+
+	sess.msg = nil
+	sess.reply(250, "OK")
+}
+
+func (sess *session) auth(arg string) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		sess.reply(501, "syntax error")
+		return
+	}
+
+	var username, password string
+	var ok bool
+	switch strings.ToUpper(fields[0]) {
+	case "PLAIN":
+		var resp string
+		if len(fields) > 1 {
+			resp = fields[1]
+		} else if resp, ok = sess.challenge(""); !ok {
+			return
+		}
+
+		// authorization-id \0 authentication-id \0 password
+		parts := strings.Split(decode(resp), "\x00")
+		if len(parts) != 3 {
+			sess.reply(501, "syntax error")
+			return
+		}
+		username, password = parts[1], parts[2]
+	case "LOGIN":
+		var resp string
+		if resp, ok = sess.challenge("Username:"); !ok {
+			return
+		}
+		username = decode(resp)
+
+		if resp, ok = sess.challenge("Password:"); !ok {
+			return
+		}
+		password = decode(resp)
+	case "CRAM-MD5":
+		c := fmt.Sprintf("<%d@localhost>", time.Now().UnixNano())
+		resp, ok := sess.challenge(c)
+		if !ok {
+			return
+		}
+
+		parts := strings.Fields(decode(resp))
+		if len(parts) != 2 {
+			sess.reply(501, "syntax error")
+			return
+		}
+		username = parts[0]
+
+		sess.s.mu.Lock()
+		pwd := sess.s.password
+		sess.s.mu.Unlock()
+		h := hmac.New(md5.New, []byte(pwd))
+		h.Write([]byte(c))
+		if hex.EncodeToString(h.Sum(nil)) == parts[1] {
+			password = pwd
+		}
+	default:
+		sess.reply(504, "unrecognized authentication type")
+		return
+	}
+
+	if !sess.s.checkAuth(username, password) {
+		sess.reply(535, "authentication credentials invalid")
+		return
+	}
+
+	sess.user = username
+	sess.reply(235, "authentication successful")
+}
+
+// 发送一条质询，并返回客户端的响应内容。
+func (sess *session) challenge(c string) (string, bool) {
+	sess.reply(334, base64.StdEncoding.EncodeToString([]byte(c)))
+	line, err := sess.tp.ReadLine()
+	if err != nil {
+		return "", false
+	}
+
+	if line == "*" {
+		sess.reply(501, "authentication cancelled")
+		return "", false
+	}
+
+	return line, true
+}
+
+func (s *Server) checkAuth(username, password string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.authFail {
+		return false
+	}
+
+	if s.username == "" && s.password == "" {
+		return true
+	}
+
+	return s.username == username && s.password == password
+}
+
+// 输出一条响应，多行内容时按 RFC 5321 的格式输出。
+func (sess *session) reply(code int, lines ...string) {
+	sess.s.mu.Lock()
+	delay := sess.s.delay
+	sess.s.mu.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	for i, line := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		sess.tp.PrintfLine("%d%s%s", code, sep, line)
+	}
+}
+
+func decode(s string) string {
+	bs, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return ""
+	}
+	return string(bs)
+}
+
+// 从 FROM:<addr> 等格式中提取出地址部分
+func trimPath(arg, prefix string) string {
+	if len(arg) >= len(prefix) && strings.EqualFold(arg[:len(prefix)], prefix) {
+		arg = arg[len(prefix):]
+	}
+
+	// 去掉 SIZE=xx 等参数
+	if index := strings.IndexByte(arg, ' '); index >= 0 {
+		arg = arg[:index]
+	}
+
+	return strings.Trim(arg, "<>")
+}
@@ -0,0 +1,60 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package smtptest
+
+import (
+	"net"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+func TestServer(t *testing.T) {
+	a := assert.New(t)
+
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetAuth("user", "pwd")
+
+	send := func(auth smtp.Auth) error {
+		return smtp.SendMail(srv.Addr, auth, "from@example.com", []string{"to1@example.com", "to2@example.com"}, []byte("Subject: test\r\n\r\nbody\r\n"))
+	}
+
+	host, _, err := net.SplitHostPort(srv.Addr)
+	a.NotError(err)
+
+	a.NotError(send(smtp.PlainAuth("", "user", "pwd", host)))
+	a.NotError(send(smtp.CRAMMD5Auth("user", "pwd")))
+	a.NotError(send(nil))
+
+	msgs := srv.Messages()
+	a.Equal(len(msgs), 3)
+	a.Equal(msgs[0].Username, "user").
+		Equal(msgs[0].From, "from@example.com").
+		Equal(msgs[0].To, []string{"to1@example.com", "to2@example.com"}).
+		Equal(string(msgs[0].Data), "Subject: test\r\n\r\nbody\r\n")
+	a.Equal(msgs[2].Username, "")
+
+	// 错误的密码
+	a.Error(send(smtp.PlainAuth("", "user", "pwd1", host)))
+	a.Error(send(smtp.CRAMMD5Auth("user", "pwd1")))
+
+	// 模拟认证失败
+	srv.SetAuthFail(true)
+	a.Error(send(smtp.PlainAuth("", "user", "pwd", host)))
+	srv.SetAuthFail(false)
+	a.Equal(len(srv.Messages()), 3)
+
+	srv.Reset()
+	a.Equal(len(srv.Messages()), 0)
+
+	// 模拟响应缓慢
+	srv.SetDelay(50 * time.Millisecond)
+	start := time.Now()
+	a.NotError(send(nil))
+	a.True(time.Since(start) > 100*time.Millisecond)
+}
@@ -0,0 +1,105 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import "encoding/binary"
+
+const (
+	snappyBlockSize = 1 << 16 // 每个块的大小，保证偏移量可以用 2 字节表示
+	snappyMinMatch  = 4
+	snappyTableBits = 14
+)
+
+// 以 snappy 的 block 格式压缩 src。
+//
+// 只使用字面量和 2 字节偏移量的复制两种元素，压缩率不如官方实现，
+// 但输出的内容可以被任何 snappy 解码器解码。
+func snappyEncode(src []byte) []byte {
+	dst := make([]byte, binary.MaxVarintLen64, len(src)+len(src)/6+32)
+	dst = dst[:binary.PutUvarint(dst, uint64(len(src)))]
+
+	for len(src) > 0 {
+		block := src
+		if len(block) > snappyBlockSize {
+			block = block[:snappyBlockSize]
+		}
+		src = src[len(block):]
+
+		dst = snappyEncodeBlock(dst, block)
+	}
+
+	return dst
+}
+
+func snappyEncodeBlock(dst, src []byte) []byte {
+	if len(src) < snappyMinMatch*4 {
+		return snappyLiteral(dst, src)
+	}
+
+	var table [1 << snappyTableBits]int32 // 保存的位置加 1，0 表示不存在
+	hash := func(u uint32) uint32 {
+		return (u * 0x1e35a7bd) >> (32 - snappyTableBits)
+	}
+
+	lit := 0 // 还未输出的字面量的起始位置
+	for i := 0; i+snappyMinMatch <= len(src); {
+		u := binary.LittleEndian.Uint32(src[i:])
+		h := hash(u)
+		candidate := int(table[h]) - 1
+		table[h] = int32(i + 1)
+
+		if candidate < 0 || binary.LittleEndian.Uint32(src[candidate:]) != u {
+			i++
+			continue
+		}
+
+		dst = snappyLiteral(dst, src[lit:i])
+
+		length := snappyMinMatch
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+		dst = snappyCopy(dst, i-candidate, length)
+
+		i += length
+		lit = i
+	}
+
+	return snappyLiteral(dst, src[lit:])
+}
+
+// 输出字面量
+func snappyLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+
+	n := len(lit) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2)
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	default:
+		dst = append(dst, 62<<2, byte(n), byte(n>>8), byte(n>>16))
+	}
+	return append(dst, lit...)
+}
+
+// 输出复制元素，每个元素最多复制 64 字节。
+func snappyCopy(dst []byte, offset, length int) []byte {
+	for length > 0 {
+		n := length
+		if n > 64 {
+			n = 64
+		}
+
+		dst = append(dst, byte(n-1)<<2|2, byte(offset), byte(offset>>8))
+		length -= n
+	}
+	return dst
+}
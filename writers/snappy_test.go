@@ -0,0 +1,85 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/issue9/assert"
+)
+
+// snappy 的 block 格式解码，供测试使用
+func snappyDecode(src []byte) ([]byte, error) {
+	size, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, errors.New("无效的长度")
+	}
+	src = src[n:]
+
+	dst := make([]byte, 0, size)
+	for len(src) > 0 {
+		tag := src[0]
+		switch tag & 3 {
+		case 0: // 字面量
+			length := int(tag>>2) + 1
+			src = src[1:]
+			if length > 60 {
+				bs := length - 60
+				length = 1
+				for i := 0; i < bs; i++ {
+					length += int(src[i]) << (8 * uint(i))
+				}
+				src = src[bs:]
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+		case 2: // 2 字节偏移量的复制
+			length := int(tag>>2) + 1
+			offset := int(src[1]) | int(src[2])<<8
+			src = src[3:]
+			if offset == 0 || offset > len(dst) {
+				return nil, errors.New("无效的偏移量")
+			}
+			for i := 0; i < length; i++ {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+		default:
+			return nil, errors.New("不支持的元素")
+		}
+	}
+
+	if uint64(len(dst)) != size {
+		return nil, errors.New("长度不一致")
+	}
+	return dst, nil
+}
+
+func TestSnappyEncode(t *testing.T) {
+	a := assert.New(t)
+
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	for _, src := range [][]byte{
+		nil,
+		[]byte("a"),
+		[]byte("abcdefg"),
+		bytes.Repeat([]byte("a"), 1000),
+		bytes.Repeat([]byte("hello world "), 10000),
+		random,
+	} {
+		data := snappyEncode(src)
+		dst, err := snappyDecode(data)
+		a.NotError(err).Equal(len(dst), len(src)).True(bytes.Equal(dst, src))
+	}
+
+	// 重复的内容需要被压缩
+	src := bytes.Repeat([]byte("hello world "), 10000)
+	a.True(len(snappyEncode(src)) < len(src)/10)
+}
@@ -0,0 +1,271 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HEC 的接口地址
+const (
+	splunkEventPath = "/services/collector/event"
+	splunkAckPath   = "/services/collector/ack"
+)
+
+// 轮询确认状态的间隔
+const defaultSplunkAckInterval = time.Second
+
+// 通过 HTTP Event Collector(HEC) 将日志发送到 Splunk。
+//
+// Write 只是将日志放入队列，由后台的 goroutine 合并发送，
+// 服务端返回 5xx 和 429 时会进行重试。启用确认之后，
+// 每一批日志都会轮询 ack 接口直到被索引，超时之后当作发送失败进行重试。
+type Splunk struct {
+	url        string
+	token      string
+	host       string
+	index      string
+	source     string
+	sourcetype string
+	gzip       bool
+	client     *http.Client
+
+	channel     string        // 启用确认时的 X-Splunk-Request-Channel
+	ackTimeout  time.Duration // 为 0 表示不需要确认
+	ackInterval time.Duration
+
+	batchWriter
+}
+
+// HEC 的事件格式
+type splunkEvent struct {
+	Time       json.Number  `json:"time"`
+	Host       string       `json:"host,omitempty"`
+	Source     string       `json:"source,omitempty"`
+	Sourcetype string       `json:"sourcetype,omitempty"`
+	Index      string       `json:"index,omitempty"`
+	Event      *splunkValue `json:"event"`
+}
+
+type splunkValue struct {
+	Level   string `json:"level,omitempty"`
+	Caller  string `json:"caller,omitempty"`
+	Message string `json:"message"`
+}
+
+// 新建 Splunk 实例。
+// url 为 HEC 的服务地址，比如 https://localhost:8088；token 为 HEC 的令牌。
+func NewSplunk(url, token string) *Splunk {
+	host, _ := os.Hostname()
+
+	s := &Splunk{
+		url:         strings.TrimRight(url, "/"),
+		token:       token,
+		host:        host,
+		client:      &http.Client{Timeout: defaultHTTPTimeout},
+		ackInterval: defaultSplunkAckInterval,
+	}
+	s.batchWriter = newBatchWriter(s.send)
+	return s
+}
+
+// 设置事件的 host 字段，默认为当前主机名。
+func (s *Splunk) SetHost(host string) {
+	s.host = host
+}
+
+// 设置事件写入的索引，为空表示使用令牌的默认索引。
+func (s *Splunk) SetIndex(index string) {
+	s.index = index
+}
+
+// 设置事件的 source 字段。
+func (s *Splunk) SetSource(source string) {
+	s.source = source
+}
+
+// 设置事件的 sourcetype 字段。
+func (s *Splunk) SetSourcetype(sourcetype string) {
+	s.sourcetype = sourcetype
+}
+
+// 是否使用 gzip 压缩请求内容。
+func (s *Splunk) SetGzip(gzip bool) {
+	s.gzip = gzip
+}
+
+// 启用索引确认，timeout 为等待确认的最长时间，为 0 表示不需要确认。
+// 需要在 Splunk 中为该令牌启用 indexer acknowledgement。
+// 只能在第一次调用 Write 之前设置。
+func (s *Splunk) SetAck(timeout time.Duration) error {
+	if timeout <= 0 {
+		s.ackTimeout = 0
+		return nil
+	}
+
+	if s.channel == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		id[6] = id[6]&0x0f | 0x40 // UUID v4
+		id[8] = id[8]&0x3f | 0x80
+		s.channel = fmt.Sprintf("%x-%x-%x-%x-%x", id[:4], id[4:6], id[6:8], id[8:10], id[10:])
+	}
+
+	s.ackTimeout = timeout
+	return nil
+}
+
+// 设置每次请求的超时时间，默认为 30 秒。
+func (s *Splunk) SetTimeout(timeout time.Duration) {
+	s.client.Timeout = timeout
+}
+
+// 设置 http.Client 使用的 Transport，可用于指定 TLS 等配置。
+func (s *Splunk) SetTransport(transport http.RoundTripper) {
+	s.client.Transport = transport
+}
+
+// io.Writer
+func (s *Splunk) Write(msg []byte) (int, error) {
+	r := parseRecord(msg, s.prefix, s.flag)
+
+	t, ok := r.parseTime()
+	if !ok {
+		t = time.Now()
+	}
+
+	// time 为带毫秒的秒数
+	ms := t.UnixNano() / int64(time.Millisecond)
+	data, err := json.Marshal(&splunkEvent{
+		Time:       json.Number(fmt.Sprintf("%d.%03d", ms/1000, ms%1000)),
+		Host:       s.host,
+		Source:     s.source,
+		Sourcetype: s.sourcetype,
+		Index:      s.index,
+		Event: &splunkValue{
+			Level:   s.level,
+			Caller:  string(r.bytes(r.caller)),
+			Message: string(r.bytes(r.message)),
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.push(data)
+	return len(msg), nil
+}
+
+// 多个事件直接拼接在一起发送
+func (s *Splunk) send(items [][]byte) error {
+	body := bytes.Join(items, []byte{'\n'})
+
+	resp := &struct {
+		Text  string `json:"text"`
+		Code  int    `json:"code"`
+		AckID *int64 `json:"ackId"`
+	}{}
+	if err := s.post(s.url+splunkEventPath, body, resp); err != nil {
+		return err
+	}
+
+	// 令牌未启用确认时，不会返回 ackId
+	if s.ackTimeout <= 0 || resp.AckID == nil {
+		return nil
+	}
+	return s.waitAck(*resp.AckID)
+}
+
+// 轮询 ack 接口，直到 id 被确认或是超时。
+func (s *Splunk) waitAck(id int64) error {
+	body, err := json.Marshal(map[string][]int64{"acks": {id}})
+	if err != nil {
+		return &noRetryError{err: err}
+	}
+	key := strconv.FormatInt(id, 10)
+
+	deadline := time.Now().Add(s.ackTimeout)
+	for {
+		time.Sleep(s.ackInterval)
+
+		resp := &struct {
+			Acks map[string]bool `json:"acks"`
+		}{}
+		if err := s.post(s.url+splunkAckPath+"?channel="+s.channel, body, resp); err != nil {
+			return err
+		}
+		if resp.Acks[key] {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("等待确认超时:[%v]", id)
+		}
+	}
+}
+
+// 发送请求并将返回的内容解析到 v 中。
+func (s *Splunk) post(url string, body []byte, v interface{}) error {
+	if s.gzip {
+		buf := new(bytes.Buffer)
+		gw := gzip.NewWriter(buf)
+		gw.Write(body)
+		if err := gw.Close(); err != nil {
+			return &noRetryError{err: err}
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return &noRetryError{err: err}
+	}
+	req.Header.Set("Authorization", "Splunk "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+	if s.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if s.ackTimeout > 0 {
+		req.Header.Set("X-Splunk-Request-Channel", s.channel)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil { // 网络错误，需要重试
+		return err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		err = fmt.Errorf("服务端返回错误的状态码:[%v]:%s", resp.StatusCode, data)
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return err
+		}
+		return &noRetryError{err: err}
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return &noRetryError{err: err}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Leveler        = &Splunk{}
+	_ Flusher        = &Splunk{}
+	_ io.WriteCloser = &Splunk{}
+)
+
+// 模拟 Splunk HEC 的服务端
+type splunkServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	headers []http.Header
+	events  [][]byte // 每次请求的内容，已解压
+	status  []int
+	ackID   int64
+	pending int // 需要查询几次之后才确认
+	polls   int
+}
+
+func newSplunkServer() *splunkServer {
+	s := &splunkServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = gr
+		}
+		data, _ := ioutil.ReadAll(body)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.Header.Get("Authorization") != "Splunk token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case splunkEventPath:
+			s.headers = append(s.headers, r.Header)
+			s.events = append(s.events, data)
+			if len(s.status) > 0 {
+				w.WriteHeader(s.status[0])
+				s.status = s.status[1:]
+				return
+			}
+
+			if r.Header.Get("X-Splunk-Request-Channel") == "" {
+				w.Write([]byte(`{"text":"Success","code":0}`))
+				return
+			}
+			s.ackID++
+			json.NewEncoder(w).Encode(map[string]interface{}{"text": "Success", "code": 0, "ackId": s.ackID})
+		case splunkAckPath:
+			if r.URL.Query().Get("channel") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			s.polls++
+			req := &struct {
+				Acks []int64 `json:"acks"`
+			}{}
+			json.Unmarshal(data, req)
+
+			ack := s.pending <= 0
+			s.pending--
+			acks := map[int64]bool{}
+			for _, id := range req.Acks {
+				acks[id] = ack
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"acks": acks})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return s
+}
+
+func (s *splunkServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+func TestSplunk(t *testing.T) {
+	a := assert.New(t)
+	srv := newSplunkServer()
+	defer srv.Close()
+
+	s := NewSplunk(srv.URL+"/", "token")
+	a.NotError(s.SetBatch(10, time.Hour))
+	s.SetHost("host1")
+	s.SetIndex("main")
+	s.SetSource("app")
+	s.SetSourcetype("_json")
+	s.SetGzip(true)
+	s.SetLevel("info", "[INFO]", 0)
+	s.Write([]byte("[INFO]m1\n"))
+	s.Write([]byte("[INFO]m2\n"))
+	s.Flush()
+	defer s.Close()
+
+	a.Equal(srv.count(), 1)
+	a.Equal(srv.headers[0].Get("Content-Encoding"), "gzip").
+		Empty(srv.headers[0].Get("X-Splunk-Request-Channel"))
+
+	dec := json.NewDecoder(bytes.NewReader(srv.events[0]))
+	events := []map[string]interface{}{}
+	for dec.More() {
+		e := map[string]interface{}{}
+		a.NotError(dec.Decode(&e))
+		events = append(events, e)
+	}
+	a.Equal(len(events), 2)
+
+	e := events[0]
+	a.Equal(e["host"], "host1").
+		Equal(e["index"], "main").
+		Equal(e["source"], "app").
+		Equal(e["sourcetype"], "_json").
+		Equal(e["event"], map[string]interface{}{"level": "info", "message": "m1"})
+	ts, ok := e["time"].(float64)
+	a.True(ok).True(ts > 0)
+	a.Equal(events[1]["event"].(map[string]interface{})["message"], "m2")
+}
+
+func TestSplunk_ack(t *testing.T) {
+	a := assert.New(t)
+	srv := newSplunkServer()
+	defer srv.Close()
+
+	s := NewSplunk(srv.URL, "token")
+	a.NotError(s.SetAck(time.Second))
+	s.ackInterval = 10 * time.Millisecond
+	a.True(strings.Count(s.channel, "-") == 4)
+
+	srv.mu.Lock()
+	srv.pending = 2
+	srv.mu.Unlock()
+	s.Write([]byte("m1\n"))
+	s.Flush()
+	a.Equal(srv.count(), 1).Equal(s.Dropped(), 0)
+	a.Equal(srv.polls, 3)
+	a.Equal(srv.headers[0].Get("X-Splunk-Request-Channel"), s.channel)
+
+	// 确认超时，当作失败进行重试
+	a.NotError(s.Close())
+	a.NotError(s.SetQueue(10, 1, time.Millisecond))
+	a.NotError(s.SetAck(50 * time.Millisecond))
+	srv.mu.Lock()
+	srv.pending = 100
+	srv.mu.Unlock()
+	s.Write([]byte("m2\n"))
+	s.Flush()
+	a.Equal(srv.count(), 3).Equal(s.Dropped(), 1)
+
+	a.NotError(s.Close())
+}
+
+func TestSplunk_retry(t *testing.T) {
+	a := assert.New(t)
+	srv := newSplunkServer()
+	defer srv.Close()
+
+	s := NewSplunk(srv.URL, "token")
+	a.NotError(s.SetQueue(10, 2, time.Millisecond))
+
+	srv.mu.Lock()
+	srv.status = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	srv.mu.Unlock()
+	s.Write([]byte("m1\n"))
+	s.Flush()
+	a.Equal(srv.count(), 3).Equal(s.Dropped(), 0)
+
+	// 400 不重试
+	srv.mu.Lock()
+	srv.status = []int{http.StatusBadRequest}
+	srv.mu.Unlock()
+	s.Write([]byte("m2\n"))
+	s.Flush()
+	a.Equal(srv.count(), 4).Equal(s.Dropped(), 1)
+
+	// 错误的令牌
+	a.NotError(s.Close())
+	s = NewSplunk(srv.URL, "invalid")
+	a.NotError(s.SetQueue(10, 2, time.Millisecond))
+	s.Write([]byte("m3\n"))
+	s.Flush()
+	a.Equal(srv.count(), 4).Equal(s.Dropped(), 1)
+
+	a.NotError(s.Close())
+}
@@ -0,0 +1,270 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Syslog 的消息格式
+const (
+	SyslogRFC3164 = iota // BSD syslog 格式
+	SyslogRFC5424        // 新的 syslog 格式
+)
+
+// syslog 的 severity
+const (
+	SyslogEmerg = iota
+	SyslogAlert
+	SyslogCrit
+	SyslogErr
+	SyslogWarning
+	SyslogNotice
+	SyslogInfo
+	SyslogDebug
+)
+
+// 日志级别与 syslog severity 的对应关系
+var syslogSeverities = map[string]int{
+	"critical": SyslogCrit,
+	"error":    SyslogErr,
+	"warn":     SyslogWarning,
+	"info":     SyslogInfo,
+	"debug":    SyslogDebug,
+	"trace":    SyslogDebug,
+}
+
+// 本地 syslog 服务可能的监听地址
+var syslogLocalAddrs = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// 将日志发送到 syslog 服务。
+//
+// 可以是本地的 unix socket，也可以是远程的 UDP 或 TCP 服务，
+// TCP 还可以使用 TLS 加密。连接在第一次输出时建立，断开之后会自动重连。
+type Syslog struct {
+	network   string // 为空表示本地的 syslog 服务
+	addr      string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+
+	format   int
+	facility int
+	severity int
+	hostname string
+	appName  string
+	procID   string
+
+	prefix string
+	flag   int
+
+	mu     sync.Mutex
+	conn   net.Conn
+	stream bool // 是否为流式的连接，流式连接需要对每条消息进行分帧。
+}
+
+// 新建 Syslog 实例。
+//
+// network 可以是 unix、unixgram、udp 和 tcp，为空表示本地的 syslog 服务，
+// 此时 addr 可以为空，会自动查找 /dev/log 等地址。
+// appName 为应用的名称，为空表示使用程序的文件名。
+func NewSyslog(network, addr, appName string) *Syslog {
+	if appName == "" {
+		appName = filepath.Base(os.Args[0])
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &Syslog{
+		network:  network,
+		addr:     addr,
+		timeout:  30 * time.Second,
+		facility: 1, // user
+		severity: SyslogInfo,
+		hostname: hostname,
+		appName:  appName,
+		procID:   strconv.Itoa(os.Getpid()),
+	}
+}
+
+// 设置消息格式，format 的值为 SyslogRFC3164 或是 SyslogRFC5424。
+func (s *Syslog) SetFormat(format int) error {
+	if format != SyslogRFC3164 && format != SyslogRFC5424 {
+		return fmt.Errorf("无效的消息格式:[%v]", format)
+	}
+
+	s.format = format
+	return nil
+}
+
+// 设置 facility，取值范围为 [0,23]，默认为 1(user)。
+func (s *Syslog) SetFacility(facility int) error {
+	if facility < 0 || facility > 23 {
+		return fmt.Errorf("无效的 facility:[%v]", facility)
+	}
+
+	s.facility = facility
+	return nil
+}
+
+// 设置进程 ID，默认为当前进程的 ID。
+func (s *Syslog) SetProcID(procID string) {
+	s.procID = procID
+}
+
+// 设置 TCP 连接使用 TLS 加密，conf 为 nil 表示不加密。
+func (s *Syslog) SetTLS(conf *tls.Config) {
+	s.tlsConfig = conf
+}
+
+// 设置连接和发送的超时时间，为 0 表示不限制。
+func (s *Syslog) SetTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+// Leveler.SetLevel()
+// 根据日志级别确定 severity，无法识别的级别使用 info。
+func (s *Syslog) SetLevel(level, prefix string, flag int) {
+	severity, found := syslogSeverities[level]
+	if !found {
+		severity = SyslogInfo
+	}
+
+	s.severity = severity
+	s.prefix = prefix
+	s.flag = flag
+}
+
+// io.Writer
+//
+// 发送失败时，会重新连接并再发送一次。
+func (s *Syslog) Write(msg []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for i := 0; i < 2; i++ {
+		if s.conn == nil {
+			if err = s.connect(); err != nil {
+				continue
+			}
+		}
+
+		data := s.message(msg)
+		if s.timeout > 0 {
+			s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+		}
+		if _, err = s.conn.Write(data); err == nil {
+			return len(msg), nil
+		}
+
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	return 0, err
+}
+
+// io.Closer.Close()
+func (s *Syslog) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *Syslog) connect() (err error) {
+	dialer := &net.Dialer{Timeout: s.timeout}
+
+	if s.network != "" {
+		isTCP := strings.HasPrefix(s.network, "tcp")
+		if s.tlsConfig != nil && isTCP {
+			s.conn, err = tls.DialWithDialer(dialer, s.network, s.addr, s.tlsConfig)
+		} else {
+			s.conn, err = dialer.Dial(s.network, s.addr)
+		}
+		s.stream = isTCP || s.network == "unix"
+		return err
+	}
+
+	// 本地服务
+	addrs := syslogLocalAddrs
+	if s.addr != "" {
+		addrs = []string{s.addr}
+	}
+	for _, addr := range addrs {
+		for _, network := range []string{"unixgram", "unix"} {
+			if s.conn, err = dialer.Dial(network, addr); err == nil {
+				s.stream = network == "unix"
+				return nil
+			}
+		}
+	}
+
+	return errors.New("无法连接到本地的 syslog 服务")
+}
+
+// 构造一条 syslog 消息，需要在连接建立之后调用。
+func (s *Syslog) message(msg []byte) []byte {
+	r := parseRecord(msg, s.prefix, s.flag)
+	t, ok := r.parseTime()
+	if !ok {
+		t = time.Now()
+	}
+
+	buf := new(bytes.Buffer)
+	pri := s.facility*8 + s.severity
+	local := s.network == ""
+
+	switch s.format {
+	case SyslogRFC5424:
+		// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+		fmt.Fprintf(buf, "<%d>1 %s %s %s %s - - ", pri, t.Format("2006-01-02T15:04:05.000000Z07:00"),
+			s.hostname, s.appName, s.procID)
+	default:
+		// <PRI>TIMESTAMP HOSTNAME TAG[PID]: MSG，本地服务不需要 HOSTNAME
+		fmt.Fprintf(buf, "<%d>%s ", pri, t.Format(time.Stamp))
+		if !local {
+			buf.WriteString(s.hostname)
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(buf, "%s[%s]: ", s.appName, s.procID)
+	}
+
+	if !r.caller.empty() {
+		buf.Write(r.bytes(r.caller))
+		buf.WriteString(": ")
+	}
+	buf.Write(r.bytes(r.message))
+
+	if !s.stream {
+		return buf.Bytes()
+	}
+
+	// 流式连接，RFC 5424 采用 RFC 6587 中的 octet-counting 分帧，RFC 3164 采用换行符分帧。
+	if s.format == SyslogRFC5424 {
+		return append([]byte(strconv.Itoa(buf.Len())+" "), buf.Bytes()...)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
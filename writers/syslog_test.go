@@ -0,0 +1,138 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package writers
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/issue9/assert"
+)
+
+var (
+	_ Leveler        = &Syslog{}
+	_ io.WriteCloser = &Syslog{}
+)
+
+func TestSyslog_local(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "logs-syslog")
+	a.NotError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	a.NotError(err)
+	defer conn.Close()
+
+	s := NewSyslog("", path, "app")
+	s.SetLevel("error", "[ERROR]", 0)
+	a.NotError(s.SetFacility(16)).Error(s.SetFacility(24))
+	size, err := s.Write([]byte("[ERROR]hello\n"))
+	a.NotError(err).Equal(size, len("[ERROR]hello\n"))
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	a.NotError(err)
+
+	// <16*8+3>Mmm dd hh:mm:ss app[pid]: hello
+	msg := string(buf[:n])
+	a.True(strings.HasPrefix(msg, "<131>"), msg).
+		True(strings.HasSuffix(msg, " app["+strconv.Itoa(os.Getpid())+"]: hello"), msg)
+	a.NotError(s.Close())
+}
+
+func TestSyslog_udp(t *testing.T) {
+	a := assert.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	a.NotError(err)
+	defer conn.Close()
+
+	s := NewSyslog("udp", conn.LocalAddr().String(), "app")
+	a.NotError(s.SetFormat(SyslogRFC5424)).Error(s.SetFormat(-1))
+	s.SetProcID("123")
+	s.SetLevel("warn", "[WARN]", log.LstdFlags|log.Lshortfile)
+	_, err = s.Write([]byte("[WARN]2015/06/20 12:01:02 main.go:12: hello\n"))
+	a.NotError(err)
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	a.NotError(err)
+
+	// <1*8+4>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+	fields := strings.SplitN(string(buf[:n]), " ", 8)
+	a.Equal(len(fields), 8)
+	a.Equal(fields[0], "<12>1").
+		True(strings.HasPrefix(fields[1], "2015-06-20T12:01:02.000000")).
+		Equal(fields[3], "app").
+		Equal(fields[4], "123").
+		Equal(fields[5], "-").
+		Equal(fields[6], "-").
+		Equal(fields[7], "main.go:12: hello")
+	a.NotError(s.Close())
+}
+
+func TestSyslog_tcp(t *testing.T) {
+	a := assert.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NotError(err)
+	defer ln.Close()
+
+	conns := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+
+	s := NewSyslog("tcp", ln.Addr().String(), "app")
+	a.NotError(s.SetFormat(SyslogRFC5424))
+	_, err = s.Write([]byte("hello\n"))
+	a.NotError(err)
+
+	// octet-counting 分帧
+	conn := <-conns
+	r := bufio.NewReader(conn)
+	size, err := r.ReadString(' ')
+	a.NotError(err)
+	l, err := strconv.Atoi(strings.TrimSpace(size))
+	a.NotError(err)
+	frame := make([]byte, l)
+	_, err = io.ReadFull(r, frame)
+	a.NotError(err)
+	a.True(strings.HasSuffix(string(frame), " - - hello"))
+
+	// 服务端断开之后，自动重连
+	conn.Close()
+	var conn2 net.Conn
+	for i := 0; i < 50 && conn2 == nil; i++ {
+		s.Write([]byte("world\n"))
+		select {
+		case conn2 = <-conns:
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	a.NotNil(conn2)
+	conn2.Close()
+	a.NotError(s.Close())
+}
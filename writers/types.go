@@ -47,3 +47,12 @@ type WriteFlushAdder interface {
 	Flusher
 	Adder
 }
+
+// 需要知道所属日志级别的 writer 可以实现此接口。
+//
+// 通过配置文件初始化时，会将该 writer 所在的日志级别名称(如 info、error 等)，
+// 以及该级别 log.Logger 的 prefix 和 flag 参数传递给 SetLevel。
+// writer 可以据此从每条日志中解析出时间、文件名等内容。
+type Leveler interface {
+	SetLevel(level, prefix string, flag int)
+}